@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat WithAccessLog 输出的格式
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon Apache common log format，后面追加耗时、请求 ID 和拒绝原因
+	AccessLogCommon AccessLogFormat = iota
+	// AccessLogJSON 每个请求一行 JSON
+	AccessLogJSON
+)
+
+// RequestIDHeader 访问日志读取请求 ID 的请求头，请求中没有时读取响应头
+const RequestIDHeader = "X-Request-Id"
+
+// AccessEntry 一条访问日志
+type AccessEntry struct {
+	Time       time.Time     `json:"time"`
+	Server     string        `json:"server"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Latency    time.Duration `json:"latency"`
+	RemoteAddr string        `json:"remote_addr"`
+	RequestID  string        `json:"request_id,omitempty"`
+	// Rejected 请求被拒绝的原因，"draining" 表示优雅退出期间被拒绝，"maintenance" 表示维护模式，正常处理时为空
+	Rejected string `json:"rejected,omitempty"`
+	// Fields handler 通过 WithFields 追加的字段，按照 key, value 的顺序排列
+	Fields []any `json:"fields,omitempty"`
+}
+
+// AccessLogSink 访问日志的输出
+type AccessLogSink interface {
+	LogAccess(e AccessEntry)
+}
+
+// WithAccessLog 以 format 格式把服务器的访问日志输出到 w，并发写入时会加锁
+func WithAccessLog(w io.Writer, format AccessLogFormat) ServerOption {
+	return WithAccessLogSink(&writerSink{w: w, format: format})
+}
+
+// WithAccessLogSink 把服务器的访问日志交给 sink。和 AccessLog 中间件不同，
+// 优雅退出和维护模式下被拒绝的请求也会记录，并通过 Rejected 区分，可以据此统计摘流量的影响。
+// 健康检查探针的请求不记录
+func WithAccessLogSink(sink AccessLogSink) ServerOption {
+	return func(s *Server) {
+		s.mux.accessLog = sink
+		s.mux.accessServer = s.name
+	}
+}
+
+// SlogAccessSink 使用 l 以 Info 级别输出访问日志，字段和 AccessEntry 一致
+func SlogAccessSink(l *slog.Logger) AccessLogSink {
+	return slogSink{l: l}
+}
+
+type slogSink struct {
+	l *slog.Logger
+}
+
+func (s slogSink) LogAccess(e AccessEntry) {
+	attrs := []any{"server", e.Server, "method", e.Method, "path", e.Path, "proto", e.Proto,
+		"status", e.Status, "bytes", e.Bytes, "latency", e.Latency, "remote_addr", e.RemoteAddr}
+	if e.RequestID != "" {
+		attrs = append(attrs, "request_id", e.RequestID)
+	}
+	if e.Rejected != "" {
+		attrs = append(attrs, "rejected", e.Rejected)
+	}
+	s.l.Log(context.Background(), slog.LevelInfo, "access", append(attrs, e.Fields...)...)
+}
+
+type writerSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format AccessLogFormat
+}
+
+func (s *writerSink) LogAccess(e AccessEntry) {
+	var line []byte
+	if s.format == AccessLogJSON {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		line = []byte(formatCommon(e))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(line)
+}
+
+// formatCommon 输出 `host - - [time] "GET /path HTTP/1.1" status bytes latency=... request_id=... rejected=...`
+func formatCommon(e AccessEntry) string {
+	host := e.RemoteAddr
+	if i := strings.LastIndexByte(host, ':'); i > 0 {
+		host = strings.Trim(host[:i], "[]")
+	}
+	size := "-"
+	if e.Bytes > 0 {
+		size = fmt.Sprint(e.Bytes)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s - - [%s] %q %d %s latency=%v", host, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+e.Path+" "+e.Proto, e.Status, size, e.Latency)
+	if e.RequestID != "" {
+		sb.WriteString(" request_id=" + e.RequestID)
+	}
+	if e.Rejected != "" {
+		sb.WriteString(" rejected=" + e.Rejected)
+	}
+	for i := 0; i+1 < len(e.Fields); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", e.Fields[i], e.Fields[i+1])
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// accessRecord 记录一个请求的访问日志
+type accessRecord struct {
+	mux      *serverMux
+	w        *ResponseWriter
+	r        *http.Request
+	start    time.Time
+	rejected string
+}
+
+// startAccessLog 开启访问日志时包装 w，并给请求放入请求日志收集 handler 追加的字段
+func (s *serverMux) startAccessLog(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, *accessRecord) {
+	if s.accessLog == nil {
+		return w, r, nil
+	}
+	rec := &accessRecord{mux: s, w: NewResponseWriter(w), r: seedRequestLogger(r, nil), start: time.Now()}
+	return rec.w, rec.r, rec
+}
+
+// reject 标记请求被拒绝
+func (rec *accessRecord) reject(reason string) {
+	if rec != nil {
+		rec.rejected = reason
+	}
+}
+
+func (rec *accessRecord) finish() {
+	if rec == nil {
+		return
+	}
+	status := rec.w.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	id := rec.r.Header.Get(RequestIDHeader)
+	if id == "" {
+		id = rec.w.Header().Get(RequestIDHeader)
+	}
+	rec.mux.accessLog.LogAccess(AccessEntry{
+		Time:       rec.start,
+		Server:     rec.mux.accessServer,
+		Method:     rec.r.Method,
+		Path:       rec.r.URL.RequestURI(),
+		Proto:      rec.r.Proto,
+		Status:     status,
+		Bytes:      rec.w.Size(),
+		Latency:    time.Since(rec.start),
+		RemoteAddr: rec.r.RemoteAddr,
+		RequestID:  id,
+		Rejected:   rec.rejected,
+		Fields:     LoggerFromContext(rec.r.Context()).Fields(),
+	})
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAccessLog_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewServer("api", "localhost:0", WithAccessLog(&buf, AccessLogJSON))
+	s.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		WithFields(r.Context(), "user", "u1")
+		_, _ = w.Write([]byte("hello"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users?page=2", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	s.mux.ServeHTTP(httptest.NewRecorder(), req)
+	s.rejectReq()
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("期望 2 条访问日志，实际 %q", buf.String())
+	}
+	var served, rejected AccessEntry
+	if err := json.Unmarshal([]byte(lines[0]), &served); err != nil {
+		t.Fatal(err)
+	}
+	if served.Server != "api" || served.Path != "/users?page=2" || served.Status != http.StatusOK || served.Bytes != 5 ||
+		served.RequestID != "req-1" || served.Rejected != "" || len(served.Fields) != 2 || served.Fields[1] != "u1" {
+		t.Fatalf("正常请求的访问日志不对 %+v", served)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &rejected); err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Status != http.StatusServiceUnavailable || rejected.Rejected != "draining" {
+		t.Fatalf("优雅退出期间被拒绝的请求应该标记为 draining，实际 %+v", rejected)
+	}
+}
+
+func TestFormatCommon(t *testing.T) {
+	e := AccessEntry{
+		Time:       time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Method:     http.MethodPost,
+		Path:       "/orders",
+		Proto:      "HTTP/1.1",
+		Status:     http.StatusServiceUnavailable,
+		Bytes:      0,
+		Latency:    time.Millisecond,
+		RemoteAddr: "[::1]:52100",
+		Rejected:   "draining",
+	}
+	want := `::1 - - [01/May/2024:08:30:00 +0000] "POST /orders HTTP/1.1" 503 - latency=1ms rejected=draining` + "\n"
+	if got := formatCommon(e); got != want {
+		t.Fatalf("期望 %q，实际 %q", want, got)
+	}
+}
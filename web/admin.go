@@ -0,0 +1,82 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// AdminServerName WithAdminServer 创建的服务器的名称
+const AdminServerName = "admin"
+
+// WithAdminServer 让 App 额外管理一个在 addr 上监听的 admin 服务器，提供：
+//   - /debug/pprof/ 和 /debug/vars：net/http/pprof 和 expvar
+//   - GET /servers：每个服务器的名称、地址、状态（running/draining/stopped）和正在处理的请求数
+//   - GET /inflight：同 InFlightHandler
+//   - POST /shutdown：请求头带上 "Authorization: Bearer <token>" 时开始优雅退出，token 为空时不注册
+//
+// admin 服务器不摘流量，在其他服务器都关闭之后才关闭，优雅退出期间可以一直观察。
+// addr 应该只在内网或者本机可以访问
+func WithAdminServer(addr string, token string) Option {
+	return func(app *App) {
+		s := NewServer(AdminServerName, addr, WithNoDrain())
+		s.HandleFunc("/debug/pprof/", pprof.Index)
+		s.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		s.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		s.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		s.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		s.Handle("/debug/vars", expvar.Handler())
+		s.Handle("GET /servers", app.serversHandler())
+		s.Handle("GET /inflight", app.InFlightHandler())
+		if token != "" {
+			s.Handle("POST /shutdown", app.shutdownHandler(token))
+		}
+		app.servers = append(app.servers, s)
+	}
+}
+
+// serverInfo GET /servers 返回的单个服务器
+type serverInfo struct {
+	Name     string      `json:"name"`
+	Addr     string      `json:"addr,omitempty"`
+	State    ServerState `json:"state"`
+	InFlight int64       `json:"in_flight"`
+	// Listeners 实际监听的地址，包括 HTTP/3 这类非 TCP 的监听
+	Listeners []ListenerInfo `json:"listeners,omitempty"`
+}
+
+func (a *App) serversHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := make([]serverInfo, 0, len(a.servers))
+		for _, s := range a.servers {
+			info := serverInfo{Name: s.Name(), State: a.ServerState(s)}
+			if srv, ok := s.(*Server); ok {
+				info.Addr = srv.Addr()
+				info.Listeners = srv.Listeners()
+			}
+			if c, ok := s.(inFlightCounter); ok {
+				info.InFlight = c.InFlight()
+			}
+			res = append(res, info)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+// shutdownHandler 校验 token 之后开始优雅退出，立刻返回 202，不等待优雅退出完成
+func (a *App) shutdownHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		a.logf("收到 admin 服务器的关闭请求，开始优雅退出")
+		a.triggerShutdown()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithAdminServer(t *testing.T) {
+	api := NewServer("api", "localhost:0")
+	app := NewApp([]*Server{api}, WithWaitTime(0), WithAdminServer("localhost:0", "secret"))
+	admin := app.servers[len(app.servers)-1].(*Server)
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+
+	rec := httptest.NewRecorder()
+	admin.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/servers", nil))
+	var servers []serverInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &servers); err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[0].Name != "api" || servers[0].State != ServerRunning || servers[1].Name != AdminServerName {
+		t.Fatalf("服务器列表错误 %+v", servers)
+	}
+	rec = httptest.NewRecorder()
+	admin.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("应该提供 pprof，实际 %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/shutdown", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	admin.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token 错误时应该拒绝，实际 %d", rec.Code)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	admin.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("token 正确时应该开始优雅退出，实际 %d", rec.Code)
+	}
+	select {
+	case <-app.triggered:
+	case <-time.After(time.Second):
+		t.Fatal("应该触发优雅退出")
+	}
+}
+
+func TestWithAdminServer_StopsLast(t *testing.T) {
+	api := NewServer("api", "localhost:0")
+	app := NewApp([]*Server{api}, WithWaitTime(0), WithAdminServer("localhost:0", ""))
+	admin := app.servers[1]
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var adminStateWhenAPIStopped ServerState
+	app.RegisterCallback("check", PhaseDefault, func(ctx context.Context) {
+		adminStateWhenAPIStopped = app.ServerState(admin)
+	})
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if adminStateWhenAPIStopped != ServerRunning {
+		t.Fatalf("其他服务器关闭时 admin 服务器应该仍在运行，实际 %s", adminStateWhenAPIStopped)
+	}
+	if app.ServerState(api) != ServerStopped || app.ServerState(admin) != ServerStopped {
+		t.Fatal("优雅退出之后所有服务器都应该是 stopped")
+	}
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// WithAggressiveDrain 开始优雅退出之后主动关闭空闲的 keep-alive 连接，
+// 包括已经空闲的连接和处理完请求之后变为空闲的连接，比等到关闭服务器时再处理更快地回收连接。
+// 正在处理请求的连接不会被关闭
+func WithAggressiveDrain() ServerOption {
+	return func(s *Server) {
+		s.idle = &idleConns{conns: make(map[net.Conn]struct{}), draining: &s.mux.reject}
+		prev := s.srv.ConnState
+		s.srv.ConnState = func(c net.Conn, state http.ConnState) {
+			s.idle.track(c, state)
+			if prev != nil {
+				prev(c, state)
+			}
+		}
+	}
+}
+
+// idleConns 记录空闲的连接
+type idleConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	// 服务器的拒绝标记，在锁内读取，保证和 closeAll 之间不会漏掉连接
+	draining *atomic.Bool
+}
+
+// track 记录连接状态的变化，开始优雅退出之后连接一旦空闲就关闭
+func (ic *idleConns) track(c net.Conn, state http.ConnState) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if state != http.StateIdle {
+		delete(ic.conns, c)
+		return
+	}
+	if ic.draining.Load() {
+		_ = c.Close()
+		return
+	}
+	ic.conns[c] = struct{}{}
+}
+
+// closeAll 关闭当前所有空闲的连接
+func (ic *idleConns) closeAll() {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for c := range ic.conns {
+		_ = c.Close()
+		delete(ic.conns, c)
+	}
+}
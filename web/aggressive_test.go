@@ -0,0 +1,48 @@
+package web
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithAggressiveDrain(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithAggressiveDrain())
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.serve() }()
+	defer s.forceClose()
+
+	conn, err := net.Dial("tcp", s.lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	// 等待连接变为空闲
+	time.Sleep(50 * time.Millisecond)
+	s.rejectReq()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := br.ReadByte(); err == nil || isTimeout(err) {
+		t.Fatalf("开始优雅退出之后空闲连接应该被关闭，实际 %v", err)
+	}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"time"
+)
+
+// defaultBarrierPollInterval WithExitBarrier 没有指定检查间隔时使用的间隔
+const defaultBarrierPollInterval = 100 * time.Millisecond
+
+// WithExitBarrier 优雅退出的最后一道关卡：关闭服务器、执行完回调之后，释放资源之前，
+// 每隔 pollInterval 调用一次 safe，直到返回 true 才继续退出，例如等待分布式事务结束、
+// 复制追上进度。最多等到优雅退出的整体超时时间（默认 30 秒）用完，不会因此被强制退出
+func WithExitBarrier(safe func(ctx context.Context) bool, pollInterval time.Duration) Option {
+	return func(app *App) {
+		if pollInterval <= 0 {
+			pollInterval = defaultBarrierPollInterval
+		}
+		app.exitBarrier = safe
+		app.barrierInterval = pollInterval
+	}
+}
+
+// waitExitBarrier 等待可以安全退出，最多等到 shutdownStart + shutdownTimeout
+func (a *App) waitExitBarrier(ctx context.Context) {
+	if a.exitBarrier == nil {
+		return
+	}
+	ctx, cancel := a.withDeadlineCause(ctx, a.shutdownStart.Add(a.shutdownTimeout), nil)
+	defer cancel()
+	start := a.clock.Now()
+	if a.exitBarrier(ctx) {
+		return
+	}
+	a.logf("等待可以安全退出，剩余时间 %v", a.shutdownStart.Add(a.shutdownTimeout).Sub(a.clock.Now()).Round(time.Millisecond))
+	ticker := a.clock.NewTicker(a.barrierInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if a.exitBarrier(ctx) {
+				a.logf("可以安全退出，等待了 %v", a.since(start).Round(time.Millisecond))
+				return
+			}
+		case <-ctx.Done():
+			a.logf("等待可以安全退出超时，已等待 %v", a.since(start).Round(time.Millisecond))
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithExitBarrier(t *testing.T) {
+	var polls atomic.Int32
+	var closed bool
+	app := NewApp(nil, WithWaitTime(0), WithExitBarrier(func(ctx context.Context) bool {
+		return polls.Add(1) >= 3
+	}, 10*time.Millisecond))
+	app.RegisterCloser(closerFunc(func() error {
+		closed = polls.Load() >= 3
+		return nil
+	}))
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Fatal("满足条件之后才应该释放资源")
+	}
+}
+
+func TestWithExitBarrier_Budget(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0), WithExitBarrier(func(ctx context.Context) bool {
+		return false
+	}, 10*time.Millisecond))
+	app.shutdownTimeout = 300 * time.Millisecond
+	start := time.Now()
+	_ = app.Shutdown(context.Background())
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("不应该超过整体超时时间，实际 %v", d)
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WithShutdownBudget 设置优雅退出各个步骤分配时间的权重，key 是 SimulateShutdown 返回的步骤名称，
+// 例如 "drain"、"callbacks"。没有设置的步骤的权重是它最长的耗时（秒），没有上限的步骤
+// （stop_servers、close）按一个回调超时时间计算，所以权重可以直接和秒数比较。
+//
+// 优雅退出开始时创建一个 shutdownTimeout 之后到期的 context，每个步骤开始时按照
+// 自己和后面所有步骤的权重分配剩下的时间：配置加起来不超过 shutdownTimeout 时每个步骤都能拿到配置的时间，
+// 前面的步骤提前结束时省下的时间留给后面的步骤；超过时按比例截断，保证后面的回调也有机会执行
+func WithShutdownBudget(weights map[string]float64) Option {
+	return func(app *App) {
+		app.budgetWeights = weights
+	}
+}
+
+// budgetStepNames WithShutdownBudget 可以设置权重的步骤
+var budgetStepNames = []string{"jitter", "prepare", "deregister", "drain", "stop_servers", "callbacks",
+	"stop_last_servers", "wait_workers", "close"}
+
+// validateBudget 检查 WithShutdownBudget 的步骤名称和权重
+func (a *App) validateBudget() []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(a.budgetWeights)) {
+		if !slices.Contains(budgetStepNames, name) {
+			errs = append(errs, fmt.Errorf("web: WithShutdownBudget 中的步骤%s不存在", name))
+		} else if a.budgetWeights[name] < 0 {
+			errs = append(errs, fmt.Errorf("web: WithShutdownBudget 中步骤%s的权重不能为负数", name))
+		}
+	}
+	return errs
+}
+
+// BudgetStep 优雅退出的一个步骤分配到的时间和实际的耗时
+type BudgetStep struct {
+	Name     string
+	Allotted time.Duration
+	Used     time.Duration
+	// Exhausted 步骤结束时分配的时间已经用完，步骤被截断；Allotted 为 0 时步骤被跳过
+	Exhausted bool
+}
+
+// shutdownBudget 按权重把优雅退出剩下的时间分配给各个步骤
+type shutdownBudget struct {
+	ctx      context.Context
+	deadline time.Time
+	app      *App
+	names    []string
+	weights  []float64
+	started  []bool
+	steps    []BudgetStep
+}
+
+// newShutdownBudget 创建 shutdownTimeout 之后到期的 context，到期的原因是 ErrShutdownTimeout。
+// waitTime 是本次优雅退出等待已有请求的时间
+// 只按照 shutdownTimeout 分配时间，调用方的 ctx 提前到期时和之前一样直接跳过剩下的步骤
+func (a *App) newShutdownBudget(ctx context.Context, waitTime time.Duration) (*shutdownBudget, context.CancelFunc) {
+	deadline := a.clock.Now().Add(a.shutdownTimeout)
+	ctx, cancel := a.withDeadlineCause(ctx, deadline, ErrShutdownTimeout)
+	b := &shutdownBudget{ctx: ctx, deadline: deadline, app: a}
+	for _, step := range a.shutdownSteps(waitTime) {
+		w := step.Max.Seconds()
+		if step.Max == 0 {
+			w = a.cbTimeout.Seconds()
+		}
+		if cw, ok := a.budgetWeights[step.Name]; ok {
+			w = cw
+		}
+		b.names = append(b.names, step.Name)
+		b.weights = append(b.weights, max(w, 0))
+	}
+	b.started = make([]bool, len(b.names))
+	return b, cancel
+}
+
+// start 开始名为 name 的步骤，返回的 context 在分配的时间用完时到期，步骤结束时调用 done。
+// 不在计划中的步骤直接使用整体的 context
+func (b *shutdownBudget) start(name string) (context.Context, func()) {
+	idx := -1
+	for i, n := range b.names {
+		if n == name && !b.started[i] {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return b.ctx, func() {}
+	}
+	var rest float64
+	for i := idx; i < len(b.names); i++ {
+		if !b.started[i] {
+			rest += b.weights[i]
+		}
+	}
+	b.started[idx] = true
+	remaining := b.deadline.Sub(b.app.clock.Now())
+	allotted := max(remaining, 0)
+	if rest > 0 {
+		allotted = time.Duration(float64(allotted) * b.weights[idx] / rest)
+	}
+	ctx, cancel := b.app.withTimeout(b.ctx, allotted)
+	start := b.app.clock.Now()
+	return ctx, func() {
+		b.steps = append(b.steps, BudgetStep{Name: name, Allotted: allotted, Used: b.app.since(start), Exhausted: ctx.Err() != nil})
+		cancel()
+	}
+}
+
+// String 一行的摘要，例如 "drain 1s/10s, callbacks 3s/3s(截断)"
+func (b *shutdownBudget) String() string {
+	parts := make([]string, 0, len(b.steps))
+	for _, s := range b.steps {
+		part := fmt.Sprintf("%s %v/%v", s.Name, s.Used.Round(time.Millisecond), s.Allotted.Round(time.Millisecond))
+		switch {
+		case s.Allotted == 0:
+			part += "(跳过)"
+		case s.Exhausted:
+			part += "(截断)"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
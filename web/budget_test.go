@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithShutdownBudget(t *testing.T) {
+	s := NewServer("slow", "localhost:0")
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	var cbCalled bool
+	// 等待请求的时间超过了整个超时时间，按比例截断之后回调仍然能执行
+	app := NewApp([]*Server{s}, WithLogOutput(io.Discard), WithWaitTime(time.Second),
+		WithShutdownTimeout(300*time.Millisecond), WithCallbackTimeout(100*time.Millisecond),
+		WithShutdownCallbacks(func(ctx context.Context) { cbCalled = true }))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.Get("http://" + s.lis.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	_ = app.Shutdown(context.Background())
+	if !cbCalled {
+		t.Fatal("前面的步骤超过预算时回调仍然应该执行")
+	}
+	steps := make(map[string]BudgetStep)
+	for _, step := range app.Result().Budget {
+		steps[step.Name] = step
+	}
+	drain := steps["drain"]
+	if !drain.Exhausted || drain.Allotted >= 300*time.Millisecond || drain.Allotted < 200*time.Millisecond {
+		t.Fatalf("等待请求应该按比例截断，实际 %+v", drain)
+	}
+	if cb := steps["callbacks"]; cb.Allotted == 0 || cb.Exhausted {
+		t.Fatalf("回调应该分配到时间，实际 %+v", cb)
+	}
+	if total := app.Result().Total; total > 400*time.Millisecond {
+		t.Fatalf("不应该超过 shutdownTimeout，实际 %v", total)
+	}
+}
+
+func TestWithShutdownBudget_Weights(t *testing.T) {
+	app := NewApp(nil, WithLogOutput(io.Discard), WithShutdownTimeout(time.Second), WithCallbackTimeout(time.Second),
+		WithShutdownBudget(map[string]float64{"drain": 3}))
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// drain 的权重是 3，stop_servers 和 close 按回调超时时间各算 1
+	drain := app.Result().Budget[0]
+	if drain.Name != "drain" || drain.Allotted < 550*time.Millisecond || drain.Allotted > 600*time.Millisecond {
+		t.Fatalf("期望 drain 分配到 3/5 的时间，实际 %+v", drain)
+	}
+
+	app = NewApp(nil, WithShutdownBudget(map[string]float64{"drian": 1, "close": -1}))
+	if err := app.Validate(); err == nil {
+		t.Fatal("不存在的步骤和负数权重应该返回错误")
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+)
+
+// BuildInfo 应用的构建信息，HandleBuildInfo 以 JSON 格式返回
+type BuildInfo struct {
+	Name      string `json:"name,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+	// Deps 依赖的模块，来自二进制中的构建信息
+	Deps []Dependency `json:"deps,omitempty"`
+}
+
+// Dependency 依赖的模块
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// ReadBuildInfo 从二进制中的构建信息读取 Go 版本、模块版本、VCS 提交和提交时间以及依赖列表，
+// 读取不到时返回空的 BuildInfo
+func ReadBuildInfo() BuildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+	info := BuildInfo{GoVersion: bi.GoVersion}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		info.Version = v
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.BuildTime = s.Value
+		}
+	}
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		info.Deps = append(info.Deps, Dependency{Path: dep.Path, Version: dep.Version})
+	}
+	return info
+}
+
+// BuildInfo 返回应用的构建信息，名称和版本来自 WithAppName、WithVersion，
+// 其它字段来自 ReadBuildInfo
+func (a *App) BuildInfo() BuildInfo {
+	info := ReadBuildInfo()
+	info.Name = a.name
+	if a.version != "" {
+		info.Version = a.version
+	}
+	return info
+}
+
+// HandleBuildInfo 在 pattern 上注册返回构建信息的接口，例如 "GET /version"。
+// info 中没有设置的字段使用 ReadBuildInfo 读取到的值
+func (s *Server) HandleBuildInfo(pattern string, info BuildInfo) *Server {
+	info = mergeBuildInfo(info, ReadBuildInfo())
+	body, err := json.Marshal(info)
+	return s.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
+
+// mergeBuildInfo 用 def 补全 info 中没有设置的字段
+func mergeBuildInfo(info, def BuildInfo) BuildInfo {
+	if info.Version == "" {
+		info.Version = def.Version
+	}
+	if info.Commit == "" {
+		info.Commit = def.Commit
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = def.BuildTime
+	}
+	if info.GoVersion == "" {
+		info.GoVersion = def.GoVersion
+	}
+	if info.Deps == nil {
+		info.Deps = def.Deps
+	}
+	return info
+}
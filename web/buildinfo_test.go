@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestServer_HandleBuildInfo(t *testing.T) {
+	app := NewApp(nil, WithAppName("order"), WithVersion("v1.2.0"))
+	info := app.BuildInfo()
+	info.Commit = "abc123"
+	s := NewServer("admin", "localhost:0").HandleBuildInfo("GET /version", info)
+
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("期望 JSON，实际 %q", ct)
+	}
+	var got BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "order" || got.Version != "v1.2.0" || got.Commit != "abc123" {
+		t.Fatalf("构建信息错误 %+v", got)
+	}
+	if got.GoVersion != runtime.Version() {
+		t.Fatalf("期望 Go 版本 %s，实际 %s", runtime.Version(), got.GoVersion)
+	}
+
+	s.mux.startReject()
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("拒绝新请求时期望 503，实际 %d", rec.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// certReloadInterval 检查证书文件是否更新的间隔
+const certReloadInterval = 10 * time.Second
+
+// WithCertFile 使用磁盘上的证书和私钥提供 HTTPS 服务。
+// 交给 App 管理时启动之后会定时检查文件的修改时间，证书更新（例如 cert-manager 续期）后
+// 新的连接使用新证书，不需要重启应用；重新加载失败时继续使用旧证书。开始优雅退出时停止检查。
+// 可以和 WithTLSConfig 一起使用，注意 WithTLSConfig 要放在前面。证书读取失败时服务器启动会返回错误
+func WithCertFile(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		r := &certReloader{certFile: certFile, keyFile: keyFile, interval: certReloadInterval}
+		if err := r.load(); err != nil {
+			s.optErr = fmt.Errorf("web: 读取服务器%s的证书失败: %w", s.name, err)
+			return
+		}
+		if s.srv.TLSConfig == nil {
+			s.srv.TLSConfig = &tls.Config{}
+		}
+		s.srv.TLSConfig.GetCertificate = r.getCertificate
+		s.certs = r
+	}
+}
+
+// WithClientCAs 开启双向 TLS，要求客户端提供由 pool 中的 CA 签发的证书，
+// 没有证书或者校验失败的连接在握手阶段就会被拒绝。需要和 WithCertFile 等配置服务器证书的选项一起使用
+func WithClientCAs(pool *x509.CertPool) ServerOption {
+	return func(s *Server) {
+		if s.srv.TLSConfig == nil {
+			s.srv.TLSConfig = &tls.Config{}
+		}
+		s.srv.TLSConfig.ClientCAs = pool
+		s.srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+}
+
+// certReloader 从磁盘加载证书，文件修改之后重新加载
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	cert              atomic.Pointer[tls.Certificate]
+	// 上次加载时证书和私钥文件的修改时间
+	certMod, keyMod time.Time
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// load 读取证书和私钥，失败时保留原来的证书，文件再次修改之前不会重试
+func (r *certReloader) load() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	r.certMod, r.keyMod = certMod, keyMod
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+func (r *certReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return
+	}
+	return ci.ModTime(), ki.ModTime(), nil
+}
+
+// changed 证书或者私钥文件是否在上次加载之后被修改过
+func (r *certReloader) changed() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		// 文件暂时不存在时（例如正在替换）等下次检查
+		return false
+	}
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+// startCertWatch 给使用 WithCertFile 的服务器启动检查证书更新的 worker
+func (a *App) startCertWatch() {
+	for _, s := range a.servers {
+		srv, ok := s.(*Server)
+		if !ok || srv.certs == nil {
+			continue
+		}
+		ctx, done := a.TrackWorker()
+		go func() {
+			defer done()
+			a.watchCerts(ctx, srv)
+		}()
+	}
+}
+
+func (a *App) watchCerts(ctx context.Context, srv *Server) {
+	r := srv.certs
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.load(); err != nil {
+			a.logf("服务器%s重新加载证书失败，继续使用旧证书: %v", srv.Name(), err)
+			continue
+		}
+		a.logf("服务器%s重新加载了证书", srv.Name())
+	}
+}
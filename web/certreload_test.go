@@ -0,0 +1,104 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir, host string, mod time.Time) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM := newTestCertPEM(t, host)
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	for name, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestWithCertFile_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "old.example.com", time.Now().Add(-time.Minute))
+	s := NewServer("tls", "localhost:0", WithCertFile(certFile, keyFile))
+	s.certs.interval = 10 * time.Millisecond
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	addr := s.lis.Addr().String()
+	peer := func() string {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	if got := peer(); got != "old.example.com" {
+		t.Fatalf("期望旧证书，实际 %s", got)
+	}
+	writeTestCert(t, dir, "new.example.com", time.Now())
+	deadline := time.Now().Add(2 * time.Second)
+	for peer() != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("证书文件更新之后应该使用新证书")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := peer(); got != "new.example.com" {
+		t.Fatalf("重新加载失败时应该继续使用旧证书，实际 %s", got)
+	}
+}
+
+func TestWithClientCAs(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "localhost", time.Now())
+	clientPEM, clientKey := newTestCertPEM(t, "client")
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(clientPEM)
+	s := NewServer("mtls", "localhost:0", WithCertFile(certFile, keyFile), WithClientCAs(pool))
+	addr := startTLSServer(t, s)
+
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := client.Get("https://" + addr)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get(); err == nil {
+		t.Fatal("没有客户端证书时应该拒绝连接")
+	}
+	cert, err := tls.X509KeyPair(clientPEM, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(cert); err != nil {
+		t.Fatalf("客户端证书有效时应该正常处理: %v", err)
+	}
+	if err := get(newTestCert(t, "other")); err == nil {
+		t.Fatal("不是受信任 CA 签发的客户端证书应该被拒绝")
+	}
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Clock App 在启动和优雅退出过程中使用的时钟，包括启动超时、服务器重启的退避、健康检查、
+// 等待请求和 Retry-After、回调超时、整体超时和强制退出。
+// 默认使用真实的时间，测试时可以通过 WithClock 换成 webtest.FakeClock，手动推进时间，不用真的等待。
+// Server 内部的计时（例如 WithConnDrain、WithDrainCancel）不受影响
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc d 之后执行 f，返回的 Timer 的 C 为 nil。真实的时钟在单独的 goroutine 中执行 f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer 和 time.Timer 一样
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker 和 time.Ticker 一样
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock 使用 c 代替真实的时间
+func WithClock(c Clock) Option {
+	return func(app *App) {
+		app.clock = c
+	}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return realTimer{time.AfterFunc(d, f)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// since 按照 App 的时钟计算 t 之后经过的时间
+func (a *App) since(t time.Time) time.Duration {
+	return a.clock.Now().Sub(t)
+}
+
+// withTimeout 和 context.WithTimeout 一样，只是按照 App 的时钟计时
+func (a *App) withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return a.withDeadlineCause(ctx, a.clock.Now().Add(d), nil)
+}
+
+// withDeadlineCause 和 context.WithDeadlineCause 一样，只是按照 App 的时钟计时
+func (a *App) withDeadlineCause(ctx context.Context, deadline time.Time, cause error) (context.Context, context.CancelFunc) {
+	if _, ok := a.clock.(realClock); ok {
+		return context.WithDeadlineCause(ctx, deadline, cause)
+	}
+	if cause == nil {
+		cause = context.DeadlineExceeded
+	}
+	inner, cancel := context.WithCancelCause(ctx)
+	c := &clockCtx{Context: inner, parent: ctx, deadline: deadline}
+	d := deadline.Sub(a.clock.Now())
+	if d <= 0 {
+		// 已经到期的 context 返回时就要是取消的状态
+		c.expired.Store(true)
+		cancel(cause)
+		return c, func() {}
+	}
+	timer := a.clock.AfterFunc(d, func() {
+		c.expired.Store(true)
+		cancel(cause)
+	})
+	return c, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// clockCtx 由 Clock 而不是真实时间控制到期的 context
+type clockCtx struct {
+	context.Context
+	parent   context.Context
+	deadline time.Time
+	expired  atomic.Bool
+}
+
+func (c *clockCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockCtx) Err() error {
+	err := c.Context.Err()
+	if err != nil && (c.expired.Load() || errors.Is(c.parent.Err(), context.DeadlineExceeded)) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// after 和 time.After 一样，只是按照 App 的时钟计时
+func (a *App) after(d time.Duration) <-chan time.Time {
+	return a.clock.NewTimer(d).C()
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// closers 优雅退出最后阶段需要释放的资源
+type closers struct {
+	mu sync.Mutex
+	// 普通资源，优先级高的先关闭，优先级相同时按注册顺序的逆序关闭
+	resources []priorityCloser
+	// 日志类资源，在所有资源关闭之后再关闭，保证最后的日志不丢
+	logs []io.Closer
+}
+
+// RegisterCloser 注册需要在应用关闭时释放的资源，例如 DB 连接池。
+// 资源在所有服务器关闭、回调执行完之后按注册顺序的逆序关闭
+func (a *App) RegisterCloser(c io.Closer) {
+	a.RegisterCloserWithPriority(0, c)
+}
+
+// priorityCloser 带优先级的资源
+type priorityCloser struct {
+	priority int
+	io.Closer
+}
+
+// RegisterCloserWithPriority 注册带优先级的资源，优先级高的先关闭。
+// 被依赖的资源优先级应该更低，例如 HTTP 客户端 > DB > 连接池，避免关闭过程中使用已经关闭的资源。
+// RegisterCloser 注册的资源优先级为 0
+func (a *App) RegisterCloserWithPriority(p int, c io.Closer) {
+	a.closers.mu.Lock()
+	defer a.closers.mu.Unlock()
+	a.closers.resources = append(a.closers.resources, priorityCloser{priority: p, Closer: c})
+}
+
+// RegisterLogCloser 注册日志输出（例如访问日志写入的文件、带缓冲的 writer），
+// 它们会在所有请求处理完、服务器关闭、其它资源释放之后才关闭，避免丢失最后的日志
+func (a *App) RegisterLogCloser(c io.Closer) {
+	a.closers.mu.Lock()
+	defer a.closers.mu.Unlock()
+	a.closers.logs = append(a.closers.logs, c)
+}
+
+func (c *closers) close(logf func(format string, args ...any)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// closeAll 按逆序关闭，所以这里按优先级从低到高排列
+	resources := make([]io.Closer, len(c.resources))
+	sorted := append([]priorityCloser(nil), c.resources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].priority < sorted[j].priority
+	})
+	for i, r := range sorted {
+		resources[i] = r.Closer
+	}
+	return errors.Join(closeAll(resources, logf), closeAll(c.logs, logf))
+}
+
+func closeAll(cs []io.Closer, logf func(format string, args ...any)) error {
+	var errs []error
+	for i := len(cs) - 1; i >= 0; i-- {
+		if err := cs[i].Close(); err != nil {
+			logf("释放资源失败 %v", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestApp_CloseOrder(t *testing.T) {
+	var order []string
+	record := func(name string) closerFunc {
+		return func() error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	app := NewApp(nil)
+	app.RegisterLogCloser(record("access-log"))
+	app.RegisterCloser(record("db"))
+	app.RegisterCloser(record("cache"))
+	app.close(context.Background())
+	want := []string{"cache", "db", "access-log"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("期望 %v，实际 %v", want, order)
+	}
+}
+
+func TestApp_RegisterCloserWithPriority(t *testing.T) {
+	var order []string
+	record := func(name string) closerFunc {
+		return func() error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	app := NewApp(nil)
+	app.RegisterCloserWithPriority(-1, record("pool"))
+	app.RegisterCloserWithPriority(10, record("http-client"))
+	app.RegisterCloser(record("db"))
+	app.RegisterCloser(record("cache"))
+	app.close(context.Background())
+	want := []string{"http-client", "cache", "db", "pool"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("期望 %v，实际 %v", want, order)
+	}
+}
+
+func TestApp_DoneAndErr(t *testing.T) {
+	closeErr := errors.New("close failed")
+	app := NewApp(nil, WithWaitTime(0))
+	app.RegisterCloser(closerFunc(func() error { return closeErr }))
+	if app.Err() != nil {
+		t.Fatal("优雅退出结束前 Err 应该返回 nil")
+	}
+	go app.shutdown(context.Background())
+	<-app.Done()
+	if !errors.Is(app.Err(), closeErr) {
+		t.Fatalf("期望释放资源的错误，实际 %v", app.Err())
+	}
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compress 根据 Accept-Encoding 使用 gzip 或者 deflate 压缩响应，
+// 只压缩文本、JSON 这类可压缩的内容，已经压缩过的内容（图片、压缩包等）原样返回。
+// level 取值和 compress/flate 一致，非法值使用默认压缩级别
+func Compress(level int) Middleware {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	gzipPool := &sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, level)
+		return w
+	}}
+	flatePool := &sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(io.Discard, level)
+		return w
+	}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pool := gzipPool
+			if encoding == "deflate" {
+				pool = flatePool
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, pool: pool}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding 优先使用 gzip，其次是 deflate。q 为 0 的编码不使用，
+// "*" 匹配没有单独列出的编码
+func negotiateEncoding(accept string) string {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q, ok := parseQuality(params)
+		if !ok {
+			continue
+		}
+		qs[name] = q
+	}
+	for _, enc := range []string{"gzip", "deflate"} {
+		q, ok := qs[enc]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > 0 {
+			return enc
+		}
+	}
+	return ""
+}
+
+// parseQuality 解析 Accept-Encoding 参数中的 q 值，没有 q 时为 1，格式错误时返回 false
+func parseQuality(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
+
+// compressible 判断内容是否值得压缩
+func compressible(contentType string) bool {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	switch {
+	case strings.HasPrefix(ct, "text/"):
+		return true
+	case strings.HasSuffix(ct, "+json"), strings.HasSuffix(ct, "+xml"):
+		return true
+	}
+	switch ct {
+	case "application/json", "application/javascript", "application/xml",
+		"application/x-www-form-urlencoded", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	// 为 nil 表示不压缩
+	writer      compressor
+	wroteHeader bool
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	// 1xx（例如 103 Early Hints）可以写多次，直接发送，等最终的状态码再决定是否压缩
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		w.writer = w.pool.Get().(compressor)
+		w.writer.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.writer == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.writer.Write(data)
+}
+
+// Flush 先把压缩缓冲区的内容写出去，保证流式响应能及时发送。
+// 还没有写响应头时先决定是否压缩，避免发出没有 Content-Encoding 的响应头
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.writer != nil {
+		_ = w.writer.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) close() {
+	if w.writer == nil {
+		return
+	}
+	_ = w.writer.Close()
+	w.writer.Reset(io.Discard)
+	w.pool.Put(w.writer)
+	w.writer = nil
+}
@@ -0,0 +1,218 @@
+package web
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnvPrefix 覆盖配置文件的环境变量的前缀，例如 WEB_SHUTDOWN_TIMEOUT、WEB_SERVER_API_ADDR
+const ConfigEnvPrefix = "WEB_"
+
+// Config 应用的配置，可以从 YAML 或者 JSON 文件中读取，时间使用 "5s" 这样的格式。
+// 没有设置的时间使用 NewApp 的默认值
+type Config struct {
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"`
+	WaitTime        *time.Duration `yaml:"wait_time"`
+	CallbackTimeout *time.Duration `yaml:"callback_timeout"`
+	StartupTimeout  *time.Duration `yaml:"startup_timeout"`
+	Servers         []ServerConfig `yaml:"servers"`
+}
+
+// ServerConfig 单个服务器的配置
+type ServerConfig struct {
+	Name string `yaml:"name"`
+	Addr string `yaml:"addr"`
+	// CertFile 和 KeyFile 同时设置时提供 HTTPS 服务，见 WithCertFile
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile 设置时开启双向 TLS，见 WithClientCAs
+	ClientCAFile      string         `yaml:"client_ca_file"`
+	ReadTimeout       *time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout *time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      *time.Duration `yaml:"write_timeout"`
+	IdleTimeout       *time.Duration `yaml:"idle_timeout"`
+}
+
+// LoadConfig 读取 path 中的配置，再使用环境变量覆盖，最后检查配置。path 为空时只读取环境变量。
+// 应用的配置对应 WEB_ 加上大写的字段名，例如 WEB_WAIT_TIME=10s；
+// 服务器的配置对应 WEB_SERVER_ 加上大写的服务器名称（"-" 换成 "_"）和字段名，例如 WEB_SERVER_API_ADDR=:9090，
+// 只能覆盖配置文件中已经有的服务器
+func LoadConfig(path string) (*Config, error) {
+	conf := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("web: 读取配置文件失败: %w", err)
+		}
+		// JSON 是 YAML 的子集，两种格式都用 YAML 解析
+		if err = yaml.Unmarshal(data, conf); err != nil {
+			return nil, fmt.Errorf("web: 解析配置文件%s失败: %w", path, err)
+		}
+	}
+	if err := conf.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// NewAppFromConfig 根据 path 中的配置创建应用，见 LoadConfig。opts 在配置之后生效，可以覆盖配置。
+// 服务器的路由通过 App.HTTPServer 拿到服务器之后注册。
+// 除了配置本身的检查，还会调用 App.Validate，例如等待请求的时间加上回调超时时间超过了整个优雅退出的超时时间
+func NewAppFromConfig(path string, opts ...Option) (*App, error) {
+	conf, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := conf.NewServers()
+	if err != nil {
+		return nil, err
+	}
+	app := NewApp(servers, append(conf.Options(), opts...)...)
+	if err = app.Validate(); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Validate 检查服务器配置：名称不能为空或者重复，地址不能为空，证书和私钥要同时设置
+func (c *Config) Validate() error {
+	var errs []error
+	names := make(map[string]struct{}, len(c.Servers))
+	for i, s := range c.Servers {
+		if s.Name == "" {
+			errs = append(errs, fmt.Errorf("web: 第%d个服务器没有名称", i))
+		}
+		if _, ok := names[s.Name]; ok {
+			errs = append(errs, fmt.Errorf("web: 服务器名称%s重复", s.Name))
+		}
+		names[s.Name] = struct{}{}
+		if s.Addr == "" {
+			errs = append(errs, fmt.Errorf("web: 服务器%s没有地址", s.Name))
+		}
+		if (s.CertFile == "") != (s.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("web: 服务器%s的 cert_file 和 key_file 需要同时设置", s.Name))
+		}
+		if s.ClientCAFile != "" && s.CertFile == "" {
+			errs = append(errs, fmt.Errorf("web: 服务器%s设置 client_ca_file 时需要设置证书", s.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options 配置对应的 Option
+func (c *Config) Options() []Option {
+	var opts []Option
+	if c.ShutdownTimeout != nil {
+		opts = append(opts, WithShutdownTimeout(*c.ShutdownTimeout))
+	}
+	if c.WaitTime != nil {
+		opts = append(opts, WithWaitTime(*c.WaitTime))
+	}
+	if c.CallbackTimeout != nil {
+		opts = append(opts, WithCallbackTimeout(*c.CallbackTimeout))
+	}
+	if c.StartupTimeout != nil {
+		opts = append(opts, WithStartupTimeout(*c.StartupTimeout))
+	}
+	return opts
+}
+
+// NewServers 按照配置创建所有服务器，读取 client_ca_file 失败时返回错误
+func (c *Config) NewServers() ([]*Server, error) {
+	servers := make([]*Server, 0, len(c.Servers))
+	for _, sc := range c.Servers {
+		opts, err := sc.Options()
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, NewServer(sc.Name, sc.Addr, opts...))
+	}
+	return servers, nil
+}
+
+// Options 服务器配置对应的 ServerOption
+func (c ServerConfig) Options() ([]ServerOption, error) {
+	var opts []ServerOption
+	if c.CertFile != "" {
+		opts = append(opts, WithCertFile(c.CertFile, c.KeyFile))
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("web: 读取服务器%s的 client_ca_file 失败: %w", c.Name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("web: 服务器%s的 client_ca_file 中没有证书", c.Name)
+		}
+		opts = append(opts, WithClientCAs(pool))
+	}
+	timeouts := []struct {
+		d   *time.Duration
+		opt func(time.Duration) ServerOption
+	}{
+		{c.ReadTimeout, WithReadTimeout},
+		{c.ReadHeaderTimeout, WithReadHeaderTimeout},
+		{c.WriteTimeout, WithWriteTimeout},
+		{c.IdleTimeout, WithIdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d != nil {
+			opts = append(opts, t.opt(*t.d))
+		}
+	}
+	return opts, nil
+}
+
+// applyEnv 使用环境变量覆盖配置
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	if err := applyEnvFields(reflect.ValueOf(c).Elem(), ConfigEnvPrefix, lookup); err != nil {
+		return err
+	}
+	for i := range c.Servers {
+		prefix := ConfigEnvPrefix + "SERVER_" + strings.ToUpper(strings.ReplaceAll(c.Servers[i].Name, "-", "_")) + "_"
+		if err := applyEnvFields(reflect.ValueOf(&c.Servers[i]).Elem(), prefix, lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnvFields 覆盖 v 中的 string 和 *time.Duration 字段，环境变量名为 prefix 加上大写的 yaml 标签
+func applyEnvFields(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == "" || tag == "name" {
+			continue
+		}
+		key := prefix + strings.ToUpper(tag)
+		val, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		switch {
+		case field.Type.Kind() == reflect.String:
+			v.Field(i).SetString(val)
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem() == durationType:
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("web: 环境变量%s不是合法的时间: %w", key, err)
+			}
+			v.Field(i).Set(reflect.ValueOf(&d))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewAppFromConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "yaml", file: "app.yaml", content: `
+shutdown_timeout: 20s
+wait_time: 5s
+servers:
+  - name: api
+    addr: localhost:8080
+    read_timeout: 3s
+  - name: admin-http
+    addr: localhost:9090
+`},
+		{name: "json", file: "app.json", content: `{
+  "shutdown_timeout": "20s",
+  "wait_time": "5s",
+  "servers": [
+    {"name": "api", "addr": "localhost:8080", "read_timeout": "3s"},
+    {"name": "admin-http", "addr": "localhost:9090"}
+  ]
+}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("WEB_WAIT_TIME", "8s")
+			t.Setenv("WEB_SERVER_ADMIN_HTTP_ADDR", "localhost:0")
+			app, err := NewAppFromConfig(writeConfig(t, tc.file, tc.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if app.shutdownTimeout != 20*time.Second || app.waitTime != 8*time.Second {
+				t.Fatalf("超时配置不对 shutdownTimeout=%v waitTime=%v", app.shutdownTimeout, app.waitTime)
+			}
+			api := app.HTTPServer("api")
+			if api == nil || api.srv.Addr != "localhost:8080" || api.srv.ReadTimeout != 3*time.Second {
+				t.Fatalf("服务器 api 的配置不对 %+v", api)
+			}
+			if admin := app.HTTPServer("admin-http"); admin == nil || admin.srv.Addr != "localhost:0" {
+				t.Fatal("环境变量应该覆盖配置文件中的地址")
+			}
+		})
+	}
+}
+
+func TestNewAppFromConfig_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "timeouts", content: "shutdown_timeout: 10s\nwait_time: 30s\n", wantErr: "shutdownTimeout"},
+		{name: "no addr", content: "servers:\n  - name: api\n", wantErr: "没有地址"},
+		{name: "cert without key", content: "servers:\n  - name: api\n    addr: :443\n    cert_file: a.pem\n", wantErr: "key_file"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewAppFromConfig(writeConfig(t, "app.yaml", tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("期望包含 %q 的错误，实际 %v", tc.wantErr, err)
+			}
+		})
+	}
+	t.Setenv("WEB_WAIT_TIME", "soon")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "WEB_WAIT_TIME") {
+		t.Fatalf("环境变量格式不对时应该返回错误，实际 %v", err)
+	}
+}
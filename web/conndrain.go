@@ -0,0 +1,177 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithConnDrain 开始优雅退出时关闭 keep-alive（SetKeepAlivesEnabled(false)），并记录服务器上所有的连接，
+// 包括被 Hijack 的 WebSocket 等长连接。开始优雅退出 deadline 之后仍然没有关闭的连接会被强制断开，
+// 断开的数量通过 CutConns 返回，并记录在 ShutdownResult 中。
+// http.Server.Shutdown 既不等待也不关闭被 Hijack 的连接，开启之后 Stop 会等到它们关闭或者到达 deadline
+func WithConnDrain(deadline time.Duration) ServerOption {
+	return func(s *Server) {
+		s.conns = &connTracker{deadline: deadline, conns: make(map[net.Conn]bool)}
+		prev := s.srv.ConnState
+		s.srv.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateHijacked {
+				s.conns.hijacked(c)
+			}
+			if prev != nil {
+				prev(c, state)
+			}
+		}
+	}
+}
+
+// CutConns 返回优雅退出时被强制断开的连接数，没有开启 WithConnDrain 时返回 0
+func (s *Server) CutConns() int64 {
+	if s.conns == nil {
+		return 0
+	}
+	return s.conns.cut.Load()
+}
+
+// connCutter 可以报告强制断开了多少连接的服务器
+type connCutter interface {
+	CutConns() int64
+}
+
+// connTracker 记录所有打开的连接，值表示连接是否已经被 Hijack
+type connTracker struct {
+	deadline time.Duration
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	timer    *time.Timer
+	cut      atomic.Int64
+}
+
+func (t *connTracker) add(c net.Conn) {
+	t.mu.Lock()
+	t.conns[c] = false
+	t.mu.Unlock()
+}
+
+func (t *connTracker) remove(c net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+}
+
+func (t *connTracker) hijacked(c net.Conn) {
+	// TLS 连接在 ConnState 中是 *tls.Conn，需要找到底层的连接
+	if nc, ok := c.(interface{ NetConn() net.Conn }); ok {
+		c = nc.NetConn()
+	}
+	t.mu.Lock()
+	if _, ok := t.conns[c]; ok {
+		t.conns[c] = true
+	}
+	t.mu.Unlock()
+}
+
+// startDeadline 开始优雅退出，deadline 之后断开所有剩下的连接
+func (t *connTracker) startDeadline() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.deadline, t.cutAll)
+	}
+}
+
+// stopDeadline 恢复接收请求，取消断开连接
+func (t *connTracker) stopDeadline() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+func (t *connTracker) cutAll() {
+	t.mu.Lock()
+	conns := make([]net.Conn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		// 已经被其他地方关闭的连接 Close 会返回错误，不计入强制断开的数量
+		if c.Close() == nil {
+			t.cut.Add(1)
+		}
+	}
+}
+
+// waitHijacked 等待被 Hijack 的连接关闭，ctx 结束时断开这些连接
+func (t *connTracker) waitHijacked(ctx context.Context) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if t.hijackedCount() == 0 {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			t.cutAll()
+			return
+		}
+	}
+}
+
+func (t *connTracker) hijackedCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, h := range t.conns {
+		if h {
+			n++
+		}
+	}
+	return n
+}
+
+// trackListener 记录 Accept 的每个连接
+type trackListener struct {
+	net.Listener
+	t *connTracker
+}
+
+func (l *trackListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc := &trackedConn{Conn: c, t: l.t}
+	l.t.add(tc)
+	return tc, nil
+}
+
+// trackedConn 关闭时从 connTracker 中删除
+type trackedConn struct {
+	net.Conn
+	t    *connTracker
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.t.remove(c)
+	})
+	return err
+}
+
+// CloseWrite 让 net/http 可以半关闭 TCP 连接
+func (c *trackedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithConnDrain_CutsHijacked(t *testing.T) {
+	s := NewServer("ws", "localhost:0", WithConnDrain(200*time.Millisecond))
+	hijacked := make(chan struct{})
+	s.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		c, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = c.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+		close(hijacked)
+		// 模拟一直不结束的 WebSocket 连接，不主动关闭
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", s.lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	<-hijacked
+
+	start := time.Now()
+	if err = app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("应该等到 deadline 才断开被 Hijack 的连接，实际 %v", d)
+	}
+	if s.CutConns() != 1 || app.Result().Servers[0].CutConns != 1 {
+		t.Fatalf("应该记录强制断开的连接数，实际 %d", s.CutConns())
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	// 连接被断开时读到 EOF，没有断开时会读取超时
+	if _, err = io.ReadAll(conn); err != nil {
+		t.Fatalf("连接应该已经被断开: %v", err)
+	}
+}
+
+func TestWithConnDrain_DisablesKeepAlive(t *testing.T) {
+	s := NewServer("api", "localhost:0", WithConnDrain(time.Second))
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.serve()
+	}()
+	defer func() {
+		_ = s.Stop(context.Background())
+	}()
+	s.rejectReq()
+	resp, err := http.Get("http://" + s.lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if !resp.Close {
+		t.Fatal("开始优雅退出之后应该关闭 keep-alive")
+	}
+}
@@ -0,0 +1,147 @@
+// Package consulweb 通过 Consul agent 的 HTTP 接口实现 web.InstanceRegistrar，
+// 只使用标准库，不需要引入 Consul 的客户端
+package consulweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+var _ web.InstanceRegistrar = (*Registrar)(nil)
+
+// Registrar 把服务器注册成 Consul 的服务，服务名就是服务器名称
+type Registrar struct {
+	addr   string
+	client *http.Client
+	token  string
+	tags   []string
+	// 健康检查的路径和间隔，path 为空时不注册健康检查
+	checkPath       string
+	checkInterval   time.Duration
+	checkSkipVerify bool
+}
+
+// Option 配置 Registrar
+type Option func(*Registrar)
+
+// WithToken 设置 ACL token
+func WithToken(token string) Option {
+	return func(r *Registrar) {
+		r.token = token
+	}
+}
+
+// WithHTTPClient 设置访问 Consul 使用的 client，默认为 http.DefaultClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *Registrar) {
+		r.client = c
+	}
+}
+
+// WithTags 设置服务的标签
+func WithTags(tags ...string) Option {
+	return func(r *Registrar) {
+		r.tags = tags
+	}
+}
+
+// WithHTTPCheck 注册 HTTP 健康检查，Consul 每隔 interval 请求实例的 path，
+// 配置了 TLS 的服务器使用 https。配合 web.WithHealthEndpoints 可以使用 web.ReadyzPath，优雅退出时检查失败
+func WithHTTPCheck(path string, interval time.Duration) Option {
+	return func(r *Registrar) {
+		r.checkPath = path
+		r.checkInterval = interval
+	}
+}
+
+// WithCheckTLSSkipVerify 健康检查不校验实例的证书，用于自签名证书
+func WithCheckTLSSkipVerify() Option {
+	return func(r *Registrar) {
+		r.checkSkipVerify = true
+	}
+}
+
+// New 创建注册到 addr（例如 "http://127.0.0.1:8500"）上的 Consul agent 的 Registrar
+func New(addr string, opts ...Option) *Registrar {
+	r := &Registrar{addr: addr, client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+type check struct {
+	HTTP          string `json:"HTTP"`
+	Interval      string `json:"Interval"`
+	TLSSkipVerify bool   `json:"TLSSkipVerify,omitempty"`
+}
+
+type service struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *check            `json:"Check,omitempty"`
+}
+
+// Register 调用 /v1/agent/service/register
+func (r *Registrar) Register(ctx context.Context, inst web.Instance) error {
+	host, portStr, err := net.SplitHostPort(inst.Addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+	svc := service{ID: inst.ID, Name: inst.Name, Address: host, Port: port, Tags: r.tags, Meta: inst.Metadata}
+	if r.checkPath != "" {
+		scheme := inst.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		svc.Check = &check{HTTP: scheme + "://" + inst.Addr + r.checkPath, Interval: r.checkInterval.String(), TLSSkipVerify: r.checkSkipVerify}
+	}
+	body, err := json.Marshal(svc)
+	if err != nil {
+		return err
+	}
+	return r.put(ctx, "/v1/agent/service/register", body)
+}
+
+// Deregister 调用 /v1/agent/service/deregister/:id
+func (r *Registrar) Deregister(ctx context.Context, inst web.Instance) error {
+	return r.put(ctx, "/v1/agent/service/deregister/"+url.PathEscape(inst.ID), nil)
+}
+
+func (r *Registrar) put(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, r.addr+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if r.token != "" {
+		req.Header.Set("X-Consul-Token", r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consulweb: %s 返回 %d: %s", path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
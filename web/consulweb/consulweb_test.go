@@ -0,0 +1,107 @@
+package consulweb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+// fakeConsul 记录注册的服务
+type fakeConsul struct {
+	mu       sync.Mutex
+	services map[string]service
+	tokens   []string
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = append(c.tokens, r.Header.Get("X-Consul-Token"))
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/agent/service/register":
+		var svc service
+		if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.services[svc.ID] = svc
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(c.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRegistrar(t *testing.T) {
+	consul := &fakeConsul{services: make(map[string]service)}
+	ts := httptest.NewServer(consul)
+	defer ts.Close()
+
+	s := web.NewServer("api", "127.0.0.1:0")
+	reg := New(ts.URL, WithToken("secret"), WithTags("v1"), WithHTTPCheck(web.ReadyzPath, 5*time.Second))
+	app := web.NewApp([]*web.Server{s}, web.WithWaitTime(0), web.WithInstanceRegistrar(reg))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.ListenerAddr().String()
+	consul.mu.Lock()
+	svc, ok := consul.services["api-"+addr]
+	consul.mu.Unlock()
+	if !ok {
+		t.Fatalf("启动之后应该注册服务，实际 %v", consul.services)
+	}
+	if svc.Name != "api" || svc.Address != "127.0.0.1" || svc.Tags[0] != "v1" {
+		t.Fatalf("注册的服务不对 %+v", svc)
+	}
+	if svc.Check == nil || svc.Check.HTTP != "http://"+addr+web.ReadyzPath || svc.Check.Interval != "5s" {
+		t.Fatalf("健康检查不对 %+v", svc.Check)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	consul.mu.Lock()
+	defer consul.mu.Unlock()
+	if len(consul.services) != 0 {
+		t.Fatalf("优雅退出之后应该注销服务，实际 %v", consul.services)
+	}
+	for _, token := range consul.tokens {
+		if token != "secret" {
+			t.Fatalf("每个请求都应该带上 token，实际 %q", token)
+		}
+	}
+}
+
+func TestRegistrar_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+	}))
+	defer ts.Close()
+	err := New(ts.URL).Register(context.Background(), web.Instance{ID: "api-1", Name: "api", Addr: "127.0.0.1:80"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Consul 返回错误时应该返回错误，实际 %v", err)
+	}
+}
+
+func TestRegistrar_HTTPSCheck(t *testing.T) {
+	consul := &fakeConsul{services: make(map[string]service)}
+	ts := httptest.NewServer(consul)
+	defer ts.Close()
+	reg := New(ts.URL, WithHTTPCheck(web.ReadyzPath, time.Second), WithCheckTLSSkipVerify())
+	inst := web.Instance{ID: "api-1", Name: "api", Addr: "127.0.0.1:8443", Scheme: "https"}
+	if err := reg.Register(context.Background(), inst); err != nil {
+		t.Fatal(err)
+	}
+	consul.mu.Lock()
+	defer consul.mu.Unlock()
+	svc := consul.services["api-1"]
+	if svc.Check == nil || svc.Check.HTTP != "https://127.0.0.1:8443"+web.ReadyzPath || !svc.Check.TLSSkipVerify {
+		t.Fatalf("配置了 TLS 的实例应该使用 https 检查并且可以跳过证书校验，实际 %+v", svc.Check)
+	}
+}
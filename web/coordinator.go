@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Coordinator 统一监听信号，按照添加的顺序依次关闭多个 App。
+// 同一进程中运行多个 App 时，避免每个 App 各自注册信号处理互相冲突
+type Coordinator struct {
+	apps     []*App
+	signals  []os.Signal
+	notifier SignalNotifier
+}
+
+// NewCoordinator 创建协调器，sigs 为空时使用和 App 相同的默认信号
+func NewCoordinator(sigs ...os.Signal) *Coordinator {
+	if len(sigs) == 0 {
+		sigs = signals
+	}
+	return &Coordinator{signals: sigs, notifier: osSignals{}}
+}
+
+// SetSignalNotifier 和 WithSignalNotifier 一样，使用 n 接收信号，测试时可以换成 webtest.Signals。
+// 需要在 Run 之前调用
+func (c *Coordinator) SetSignalNotifier(n SignalNotifier) {
+	c.notifier = n
+}
+
+// Add 添加需要统一管理的 App，关闭顺序和添加顺序一致
+func (c *Coordinator) Add(app *App) {
+	c.apps = append(c.apps, app)
+}
+
+// Run 启动所有 App，收到信号、ctx 被取消、任意一个 App 的根 context 被取消或者 App 自己触发关闭时
+// （例如 WithSelfShutdownOnUnhealthy、WithPanicShutdown），
+// 按照添加的顺序依次优雅退出。关闭期间再次收到信号会取消剩下的优雅退出。
+// 返回启动失败或者优雅退出过程中的所有错误
+func (c *Coordinator) Run(ctx context.Context) error {
+	for i, app := range c.apps {
+		if err := app.Start(); err != nil {
+			// 关闭已经启动的 App，避免进程处于半启动状态
+			return errors.Join(err, c.shutdown(context.WithoutCancel(ctx), c.apps[:i]))
+		}
+	}
+	ch := make(chan os.Signal, 2)
+	c.notifier.Notify(ch, c.signals...)
+	defer c.notifier.Stop(ch)
+
+	stopped := make(chan struct{}, len(c.apps))
+	for _, app := range c.apps {
+		go func(app *App) {
+			select {
+			case <-app.ctx.Done():
+			case <-app.triggered:
+			}
+			stopped <- struct{}{}
+		}(app)
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	case <-stopped:
+	}
+
+	shutdownCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-done:
+		}
+	}()
+	return c.shutdown(shutdownCtx, c.apps)
+}
+
+// shutdown 依次关闭 apps，每个 App 使用自己的超时时间
+func (c *Coordinator) shutdown(ctx context.Context, apps []*App) error {
+	var errs []error
+	for _, app := range apps {
+		appCtx, cancel := context.WithTimeout(ctx, app.shutdownTimeout)
+		if err := app.Shutdown(appCtx); err != nil {
+			errs = append(errs, fmt.Errorf("web: 关闭应用%s失败: %w", app.name, err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
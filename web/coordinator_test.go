@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCoordinator_Run(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	newApp := func(name string) *App {
+		return NewApp(nil, WithAppName(name), WithWaitTime(0),
+			WithServers(&tcpServer{stopped: make(chan struct{})}),
+			WithShutdownCallbacks(func(ctx context.Context) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			}))
+	}
+	c := NewCoordinator()
+	apps := []*App{newApp("first"), newApp("second")}
+	for _, app := range apps {
+		c.Add(app)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range apps {
+		select {
+		case <-app.Done():
+		default:
+			t.Fatal("所有应用都应该完成优雅退出")
+		}
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("应该按照添加的顺序关闭，实际 %v", order)
+	}
+}
+
+func TestCoordinator_Run_Triggered(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0), WithServers(&tcpServer{stopped: make(chan struct{})}))
+	c := NewCoordinator()
+	c.Add(app)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	if err := app.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	app.triggerShutdown()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("App 自己触发关闭时 Coordinator 应该开始优雅退出")
+	}
+	select {
+	case <-app.Done():
+	default:
+		t.Fatal("应用应该完成优雅退出")
+	}
+}
+
+func TestCoordinator_SetSignalNotifier(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0), WithServers(&tcpServer{stopped: make(chan struct{})}))
+	n := &testNotifier{}
+	c := NewCoordinator()
+	c.SetSignalNotifier(n)
+	c.Add(app)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+	if err := app.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		n.mu.Lock()
+		registered := n.ch != nil
+		n.mu.Unlock()
+		if registered {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	n.send(syscall.SIGTERM)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到模拟的信号时 Coordinator 应该开始优雅退出")
+	}
+}
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// drainPollInterval 等待已有请求时检查正在处理的请求数的间隔
+const drainPollInterval = 100 * time.Millisecond
+
+type drainKey struct{}
+
+// ErrDraining 开启 WithDrainCancel 时，服务器优雅退出取消请求的 context 的原因
+var ErrDraining = errors.New("web: 服务器正在优雅退出")
+
+// IsDraining 判断处理当前请求的服务器是否正在优雅退出（拒绝新请求），
+// handler 可以据此放弃耗时操作、缩短超时时间等。ctx 必须来自 Server 处理的请求
+func IsDraining(ctx context.Context) bool {
+	mux, ok := ctx.Value(drainKey{}).(*serverMux)
+	return ok && mux.reject.Load()
+}
+
+// ShutdownDeadline 开始优雅退出之后，返回服务器等待已有请求的截止时间，超过之后请求可能被强制中断。
+// handler 可以据此判断剩余时间是否足够执行可选的耗时操作。还没有开始优雅退出时返回 false
+func ShutdownDeadline(ctx context.Context) (time.Time, bool) {
+	mux, ok := ctx.Value(drainKey{}).(*serverMux)
+	if !ok || !mux.reject.Load() {
+		return time.Time{}, false
+	}
+	end := mux.drainEnd.Load()
+	if end == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, end), true
+}
+
+// DrainSignal 返回的 channel 在处理请求的服务器开始优雅退出时关闭。
+// SSE、chunked 这类流式响应的 handler 应该监听它并及时结束响应，
+// 否则请求会一直处于处理中，直到等待超时。r 不是 Server 处理的请求时返回 nil
+func DrainSignal(r *http.Request) <-chan struct{} {
+	return ShutdownChannel(r.Context())
+}
+
+// ShutdownChannel 同 DrainSignal，用于只拿得到 ctx 的地方，ctx 不是来自 Server 处理的请求时返回 nil
+func ShutdownChannel(ctx context.Context) <-chan struct{} {
+	mux, ok := ctx.Value(drainKey{}).(*serverMux)
+	if !ok {
+		return nil
+	}
+	mux.drainMu.Lock()
+	defer mux.drainMu.Unlock()
+	return mux.drainCh
+}
+
+// WithDrainCancel 服务器开始优雅退出 grace 之后取消所有正在处理的请求的 context，
+// context.Cause 返回 ErrDraining。不监听 DrainSignal 的 handler 也能通过 ctx 及时结束，
+// 例如数据库查询、下游调用。grace 为 0 时开始优雅退出立即取消；恢复流量时不再取消
+func WithDrainCancel(grace time.Duration) ServerOption {
+	return func(s *Server) {
+		s.mux.cancelOnDrain = true
+		s.mux.drainGrace = grace
+		s.mux.drainCtx, s.mux.drainCancel = context.WithCancelCause(context.Background())
+	}
+}
+
+// withDrainCancel 开启 WithDrainCancel 时给请求的 context 加上优雅退出时的取消
+func (s *serverMux) withDrainCancel(r *http.Request) (*http.Request, func()) {
+	if !s.cancelOnDrain {
+		return r, func() {}
+	}
+	s.drainMu.Lock()
+	drainCtx := s.drainCtx
+	s.drainMu.Unlock()
+	ctx, cancel := context.WithCancelCause(r.Context())
+	stop := context.AfterFunc(drainCtx, func() {
+		cancel(context.Cause(drainCtx))
+	})
+	return r.WithContext(ctx), func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// startReject 开始拒绝新请求，通知流式响应结束
+func (s *serverMux) startReject() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.reject.Swap(true) {
+		return
+	}
+	close(s.drainCh)
+	if s.cancelOnDrain {
+		cancel := s.drainCancel
+		s.drainTimer = time.AfterFunc(s.drainGrace, func() {
+			cancel(ErrDraining)
+		})
+	}
+}
+
+// stopReject 恢复处理新请求
+func (s *serverMux) stopReject() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if !s.reject.Swap(false) {
+		return
+	}
+	s.drainCh = make(chan struct{})
+	if s.drainTimer != nil && !s.drainTimer.Stop() {
+		// 已经取消过了，之后的请求使用新的 context
+		s.drainCtx, s.drainCancel = context.WithCancelCause(context.Background())
+	}
+	s.drainTimer = nil
+}
+
+// IsDraining 应用是否正在优雅退出
+func (a *App) IsDraining() bool {
+	return a.draining.Load()
+}
+
+// WithDrainConnectionClose 开始优雅退出之后，所有响应（包括正在处理的请求）都带上
+// "Connection: close"，让使用 keep-alive 的客户端不要再复用这个连接发送新的请求
+func WithDrainConnectionClose() ServerOption {
+	return func(s *Server) {
+		s.mux.closeOnDrain = true
+	}
+}
+
+// drainWriter 在写响应头时检查是否正在优雅退出
+type drainWriter struct {
+	http.ResponseWriter
+	mux         *serverMux
+	wroteHeader bool
+}
+
+func (w *drainWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.mux.reject.Load() {
+			w.Header().Set("Connection", "close")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *drainWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *drainWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *drainWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WithMinDrainTime 优雅退出时至少等待 d 再关闭服务器，即使已经没有正在处理的请求，
+// 给负载均衡留出感知健康检查失败、停止转发新连接的时间。最多等待 WithWaitTime，默认为 0
+func WithMinDrainTime(d time.Duration) Option {
+	return func(app *App) {
+		app.minDrainTime = d
+	}
+}
+
+// waitMinDrain 请求都处理完之后，等待到 minDrainTime，ctx 被取消时提前返回
+func (a *App) waitMinDrain(ctx context.Context, start time.Time, waitTime time.Duration) bool {
+	remaining := min(a.minDrainTime, waitTime) - a.since(start)
+	if remaining <= 0 {
+		return true
+	}
+	timer := a.clock.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitDrained 等待 servers 正在处理的请求数变为 0，最多等待 waitTime；
+// 超过 waitTime 时如果只剩下长时间运行的请求，继续等待到 WithLongRunningWaitTime。
+// 所有请求都处理完时返回 true
+func (a *App) waitDrained(ctx context.Context, servers []ManagedServer, waitTime time.Duration) bool {
+	start := a.clock.Now()
+	timer := a.clock.NewTimer(waitTime)
+	defer timer.Stop()
+	// 长时间运行的请求最多等待到 longRunningWaitTime
+	longTimer := a.clock.NewTimer(max(waitTime, a.longRunningWaitTime))
+	defer longTimer.Stop()
+	ticker := a.clock.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	expired := false
+	for {
+		n := inFlight(servers)
+		if a.drainProgress != nil {
+			a.drainProgress(int(n), a.since(start))
+		}
+		if n == 0 {
+			return a.waitMinDrain(ctx, start, waitTime)
+		}
+		// 超过 waitTime 之后只继续等待长时间运行的请求
+		if expired && n > longRunningInFlight(servers) {
+			return false
+		}
+		select {
+		case <-ticker.C():
+		case <-timer.C():
+			expired = true
+		case <-longTimer.C():
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"context"
+	"time"
+)
+
+// drainPolicy 服务器自己的关闭策略
+type drainPolicy struct {
+	graceful   time.Duration
+	forceClose time.Duration
+}
+
+// drainPolicer 有自己的关闭策略、不使用应用统一配置的服务器
+type drainPolicer interface {
+	drainPolicy() (drainPolicy, bool)
+}
+
+// WithServerDrainPolicy 给服务器单独设置关闭策略，覆盖应用的 WithForceCloseGrace：
+// 优雅关闭最多等待 graceful，超时之后强制关闭所有连接，再最多等待 forceClose 让还在执行的 handler 返回。
+// 例如 WebSocket 服务器可以给较长的 graceful，REST 服务器快速关闭。graceful 为 0 表示一直等待到应用整体超时
+func WithServerDrainPolicy(graceful, forceClose time.Duration) ServerOption {
+	return func(s *Server) {
+		s.policy = &drainPolicy{graceful: graceful, forceClose: forceClose}
+	}
+}
+
+func (s *Server) drainPolicy() (drainPolicy, bool) {
+	if s.policy == nil {
+		return drainPolicy{}, false
+	}
+	return *s.policy, true
+}
+
+// stopPolicy 返回 srv 的关闭策略，服务器没有单独设置时使用应用的配置
+func (a *App) stopPolicy(srv ManagedServer) drainPolicy {
+	if dp, ok := srv.(drainPolicer); ok {
+		if p, ok := dp.drainPolicy(); ok {
+			return p
+		}
+	}
+	return drainPolicy{graceful: a.forceCloseGrace}
+}
+
+// waitForceClosed 强制关闭之后最多等待 d 让还在执行的 handler 返回
+func (a *App) waitForceClosed(ctx context.Context, srv ManagedServer, d time.Duration) {
+	c, ok := srv.(inFlightCounter)
+	if !ok || d <= 0 {
+		return
+	}
+	ctx, cancel := a.withTimeout(ctx, d)
+	defer cancel()
+	ticker := a.clock.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for c.InFlight() > 0 {
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			a.logf("服务器%s强制关闭之后仍有%d个请求没有结束", srv.Name(), c.InFlight())
+			return
+		}
+	}
+}
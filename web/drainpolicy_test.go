@@ -0,0 +1,45 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithServerDrainPolicy(t *testing.T) {
+	s := NewServer("ws", "localhost:0", WithServerDrainPolicy(50*time.Millisecond, time.Second))
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		// 模拟 handler 感知到连接关闭之后还需要一点时间清理
+		time.Sleep(100 * time.Millisecond)
+	})
+	// 应用统一的配置是一直等待，服务器自己的策略优先
+	app := NewApp([]*Server{s})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.Get("http://" + s.lis.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	start := time.Now()
+	forced, err := app.stopServer(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !forced {
+		t.Fatal("超过服务器的 graceful 之后应该强制关闭")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("关闭耗时过长 %v", d)
+	}
+	if n := s.InFlight(); n != 0 {
+		t.Fatalf("强制关闭之后应该等待 handler 返回，实际还有 %d 个请求", n)
+	}
+}
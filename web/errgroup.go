@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrGroup golang.org/x/sync/errgroup.Group 这类可以启动 goroutine 并收集错误的分组
+type ErrGroup interface {
+	Go(f func() error)
+}
+
+// StartGroup 在 g 中启动所有服务器，ctx 一般是 errgroup.WithContext 返回的 context。
+// 任意一个服务器异常退出都会让 g 返回错误并取消 ctx，ctx 取消或者 App 自己触发关闭后开始优雅退出，
+// 优雅退出的错误同样由 g.Wait 返回。服务器正常关闭不会被当成错误
+func (a *App) StartGroup(ctx context.Context, g ErrGroup) {
+	for _, s := range a.servers {
+		srv := s
+		g.Go(func() error {
+			if err := srv.Start(); err != nil && !a.isServerClosed(err) {
+				return fmt.Errorf("web: 服务器%s异常退出: %w", srv.Name(), err)
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		select {
+		case <-ctx.Done():
+		case <-a.ctx.Done():
+		case <-a.triggered:
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(a.ctx), a.shutdownTimeout)
+		defer cancel()
+		return a.Shutdown(shutdownCtx)
+	})
+}
+
+// serverRunStopTimeout Server.Run 优雅关闭的超时时间，超时后强制关闭所有连接
+const serverRunStopTimeout = 30 * time.Second
+
+// Run 不依赖 App 单独运行服务器，可以直接交给 errgroup：处理请求直到 ctx 被取消，
+// 然后拒绝新请求并优雅关闭，最多等待 30 秒，超时后强制关闭。
+// 正常关闭时返回 nil，监听失败或者服务器异常退出时返回错误
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.listen(); err != nil {
+		return fmt.Errorf("web: 服务器%s监听失败: %w", s.name, err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serve()
+	}()
+	select {
+	case err := <-served:
+		if IsServerClosed(err) {
+			return nil
+		}
+		return fmt.Errorf("web: 服务器%s异常退出: %w", s.name, err)
+	case <-ctx.Done():
+	}
+	s.rejectReq()
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), serverRunStopTimeout)
+	defer cancel()
+	if err := s.Stop(stopCtx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.Join(err, s.forceClose())
+		}
+		return err
+	}
+	return nil
+}
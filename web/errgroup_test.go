@@ -0,0 +1,109 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestApp_StartGroup(t *testing.T) {
+	s := NewServer("ok", "localhost:0")
+	bad := NewServer("bad", "256.0.0.1:80")
+	app := NewApp([]*Server{s, bad}, WithWaitTime(0))
+	g, ctx := errgroup.WithContext(context.Background())
+	app.StartGroup(ctx, g)
+	if err := g.Wait(); err == nil {
+		t.Fatal("期望返回服务器启动失败的错误")
+	}
+	select {
+	case <-app.Done():
+	default:
+		t.Fatal("服务器异常退出之后应该完成优雅退出")
+	}
+}
+
+func TestApp_StartGroup_Triggered(t *testing.T) {
+	app := NewApp([]*Server{NewServer("ok", "localhost:0")}, WithWaitTime(0))
+	g, ctx := errgroup.WithContext(context.Background())
+	app.StartGroup(ctx, g)
+	app.triggerShutdown()
+	done := make(chan error, 1)
+	go func() { done <- g.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("App 自己触发关闭时应该开始优雅退出")
+	}
+	select {
+	case <-app.Done():
+	default:
+		t.Fatal("应用应该完成优雅退出")
+	}
+}
+
+func TestIsServerClosed(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "http.ErrServerClosed", err: http.ErrServerClosed, want: true},
+		{name: "net.ErrClosed", err: fmt.Errorf("accept: %w", net.ErrClosed), want: true},
+		{name: "other", err: errors.New("bind: address already in use"), want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsServerClosed(tc.err); got != tc.want {
+				t.Fatalf("期望 %v，实际 %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWithServerClosedFunc(t *testing.T) {
+	errStopped := errors.New("listener stopped")
+	app := NewApp(nil, WithServerClosedFunc(func(err error) bool {
+		return errors.Is(err, errStopped)
+	}))
+	if !app.isServerClosed(errStopped) || app.isServerClosed(http.ErrServerClosed) {
+		t.Fatal("没有使用自定义的判断函数")
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	addr := freeAddr(t)
+	s := NewServer("api", addr)
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	ctx, cancel := context.WithCancel(context.Background())
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return s.Run(gctx) })
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	cancel()
+	if err := g.Wait(); err != nil {
+		t.Fatalf("ctx 取消之后应该正常关闭，实际 %v", err)
+	}
+
+	if err := NewServer("bad", "256.0.0.1:80").Run(context.Background()); err == nil {
+		t.Fatal("期望返回监听失败的错误")
+	}
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// DefaultETagMaxBytes ETag 默认最多缓冲的响应体大小
+const DefaultETagMaxBytes = 1 << 20
+
+// ETag 给 GET、HEAD 请求的 200 响应生成基于内容哈希的 ETag，
+// 请求的 If-None-Match 匹配时返回 304，不返回响应体。handler 已经设置了 ETag 时直接使用。
+// 需要缓冲整个响应体，超过 maxBytes 或者 handler 调用了 Flush（流式响应）时不再处理，原样返回。
+// maxBytes 小于等于 0 时使用 DefaultETagMaxBytes
+func ETag(maxBytes int64) Middleware {
+	if maxBytes <= 0 {
+		maxBytes = DefaultETagMaxBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{ResponseWriter: w, maxBytes: maxBytes}
+			next.ServeHTTP(ew, r)
+			ew.finish(r)
+		})
+	}
+}
+
+// etagWriter 缓冲 200 响应的响应体，其它响应直接写出去
+type etagWriter struct {
+	http.ResponseWriter
+	maxBytes    int64
+	status      int
+	buf         bytes.Buffer
+	wroteHeader bool
+	// 不再缓冲，直接写到 ResponseWriter
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if code != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough && int64(w.buf.Len()+len(data)) > w.maxBytes {
+		if err := w.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+// Flush 流式响应不生成 ETag，把缓冲的内容写出去之后直接转发
+func (w *etagWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough {
+		_ = w.startPassthrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// startPassthrough 写出响应头和已经缓冲的内容，之后不再缓冲
+func (w *etagWriter) startPassthrough() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// finish 生成 ETag，和 If-None-Match 匹配时返回 304，否则写出缓冲的响应
+func (w *etagWriter) finish(r *http.Request) {
+	if !w.wroteHeader || w.passthrough {
+		return
+	}
+	h := w.Header()
+	etag := h.Get("ETag")
+	if etag == "" && (w.buf.Len() > 0 || r.Method == http.MethodGet) {
+		sum := sha256.Sum256(w.buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+	}
+	if etag != "" && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatch 按照弱比较判断 If-None-Match 是否包含 etag
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -33,3 +33,39 @@ func StoreCacheToDBCallback(ctx context.Context) {
 		log.Printf("缓存被刷新到了 DB")
 	}
 }
+
+// SSEHandler 演示流式响应怎么配合优雅退出：开始优雅退出时结束推送
+func SSEHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-DrainSignal(r):
+			_, _ = w.Write([]byte("event: close\ndata: 服务器关闭中\n\n"))
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		case t := <-ticker.C:
+			_, _ = w.Write([]byte("data: " + t.Format(time.RFC3339) + "\n\n"))
+			flusher.Flush()
+		}
+	}
+}
+
+// ReportHandler 演示根据优雅退出的剩余时间跳过可选的耗时操作
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("report"))
+	// 生成缩略图大约需要 2 秒，剩余时间不够时跳过，不影响主流程
+	if deadline, ok := ShutdownDeadline(r.Context()); ok && time.Until(deadline) < 2*time.Second {
+		log.Printf("服务器即将关闭，跳过生成缩略图")
+		return
+	}
+	time.Sleep(2 * time.Second)
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultFlushWindow 没有设置 WithFinalFlushWindow 时等待 Flusher 的时间
+const defaultFlushWindow = time.Second
+
+// Flusher 需要在进程退出前把缓冲的数据发送出去的组件，例如指标推送、trace 导出
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// WithFinalFlushWindow 设置应用关闭的最后一步等待所有 Flusher 的时间，默认 1 秒
+func WithFinalFlushWindow(d time.Duration) Option {
+	return func(app *App) {
+		app.flushWindow = d
+	}
+}
+
+// RegisterFlusher 注册在应用关闭的最后一步执行的 Flusher。
+// 和 RegisterCloser 不同，Flusher 只负责发送缓冲的数据，所有资源释放之后才执行，
+// 保证关闭过程中产生的最后一批指标、trace 也能发送出去
+func (a *App) RegisterFlusher(f Flusher) {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+	a.flushers = append(a.flushers, f)
+}
+
+// flush 并发执行所有 Flusher，最多等待 flushWindow
+func (a *App) flush(ctx context.Context) error {
+	a.flushMu.Lock()
+	flushers := append([]Flusher(nil), a.flushers...)
+	a.flushMu.Unlock()
+	if len(flushers) == 0 {
+		return nil
+	}
+	window := a.flushWindow
+	if window <= 0 {
+		window = defaultFlushWindow
+	}
+	ctx, cancel := a.withTimeout(ctx, window)
+	defer cancel()
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, f := range flushers {
+		wg.Add(1)
+		go func(f Flusher) {
+			defer wg.Done()
+			if err := f.Flush(ctx); err != nil {
+				a.logf("发送缓冲数据失败 %v", err)
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(f)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		a.logf("等待发送缓冲数据超时")
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
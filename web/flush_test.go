@@ -0,0 +1,49 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type flusherFunc func(ctx context.Context) error
+
+func (f flusherFunc) Flush(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestApp_RegisterFlusher(t *testing.T) {
+	var order []string
+	app := NewApp(nil)
+	app.RegisterFlusher(flusherFunc(func(ctx context.Context) error {
+		order = append(order, "flush")
+		return nil
+	}))
+	app.RegisterCloser(closerFunc(func() error {
+		order = append(order, "close")
+		return nil
+	}))
+	if err := app.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "close" || order[1] != "flush" {
+		t.Fatalf("应该在释放资源之后发送缓冲数据，实际 %v", order)
+	}
+}
+
+func TestWithFinalFlushWindow(t *testing.T) {
+	app := NewApp(nil, WithFinalFlushWindow(50*time.Millisecond))
+	app.RegisterFlusher(flusherFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return ctx.Err()
+	}))
+	start := time.Now()
+	if err := app.close(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望超时错误，实际 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("最多等待 flush 窗口的时间，实际 %v", elapsed)
+	}
+}
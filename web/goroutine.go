@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// goroutineWaitTimeout 应用关闭时等待后台 goroutine 退出的最长时间
+const goroutineWaitTimeout = 5 * time.Second
+
+// goroutines 跟踪通过 App.Go 启动的后台 goroutine
+type goroutines struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Go 在后台 goroutine 中执行 fn，ctx 为应用的根 context，应用关闭时会被取消。
+// 优雅退出会在取消 ctx 之后等待 fn 返回（最多等待 5 秒），再释放注册的资源。
+// 应用已经关闭之后调用不会再执行 fn
+func (a *App) Go(fn func(ctx context.Context)) {
+	g := &a.goroutines
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(a.ctx)
+	}()
+}
+
+// TrackWorker 跟踪一个监听 context 的 worker。返回的 ctx 在开始优雅退出时被取消，
+// worker 退出后调用 done，优雅退出会在释放资源之前等待所有 worker 调用 done（最多等待 5 秒）。
+// 应用已经关闭之后调用时返回的 ctx 已经被取消
+func (a *App) TrackWorker() (ctx context.Context, done func()) {
+	g := &a.workers
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return a.workerCtx, func() {}
+	}
+	g.wg.Add(1)
+	return a.workerCtx, sync.OnceFunc(g.wg.Done)
+}
+
+// wait 等待所有后台 goroutine 退出，超时或者 ctx 被取消时返回 false
+func (g *goroutines) wait(ctx context.Context, clock Clock, timeout time.Duration) bool {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	timer := clock.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C():
+	case <-ctx.Done():
+	}
+	return false
+}
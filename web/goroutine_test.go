@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestApp_Go(t *testing.T) {
+	app := NewApp(nil)
+	exited := make(chan struct{})
+	var closedAfterExit bool
+	app.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		close(exited)
+	})
+	app.RegisterCloser(closerFunc(func() error {
+		select {
+		case <-exited:
+			closedAfterExit = true
+		default:
+		}
+		return nil
+	}))
+	if err := app.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !closedAfterExit {
+		t.Fatal("应该等后台 goroutine 退出之后再释放资源")
+	}
+	var ran bool
+	app.Go(func(ctx context.Context) { ran = true })
+	if app.goroutines.wait(context.Background(), realClock{}, time.Second); ran {
+		t.Fatal("应用关闭之后不应该再执行")
+	}
+}
+
+func TestApp_TrackWorker(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	ctx, done := app.TrackWorker()
+	var cancelledAtDrain, closedAfterDone bool
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		cancelledAtDrain = app.IsDraining()
+		time.Sleep(50 * time.Millisecond)
+		close(stopped)
+		done()
+		done()
+	}()
+	app.RegisterCloser(closerFunc(func() error {
+		select {
+		case <-stopped:
+			closedAfterDone = true
+		default:
+		}
+		return nil
+	}))
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !cancelledAtDrain {
+		t.Fatal("开始优雅退出时应该取消 worker 的 ctx")
+	}
+	if !closedAfterDone {
+		t.Fatal("应该等 worker 退出之后再释放资源")
+	}
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultGracePeriodEnv WithGracePeriodFromEnv 默认读取的环境变量
+const DefaultGracePeriodEnv = "GRACEFUL_SHUTDOWN_SECONDS"
+
+// WithGracePeriodFromEnv 从环境变量 varName 读取编排系统给的退出时间（例如 Kubernetes 的
+// terminationGracePeriodSeconds），值可以是秒数也可以是 "10s" 这样的时间。
+// 整体超时时间超过这个时间时输出警告并缩短到留出余量之后的时间，等待请求和回调的时间按比例缩短，
+// 避免还没有优雅退出完就被 SIGKILL。varName 为空时读取 GRACEFUL_SHUTDOWN_SECONDS，
+// 环境变量没有设置时不做调整。在所有选项之后生效
+func WithGracePeriodFromEnv(varName string) Option {
+	return func(app *App) {
+		if varName == "" {
+			varName = DefaultGracePeriodEnv
+		}
+		app.gracePeriodEnv = varName
+	}
+}
+
+// fitGracePeriod 根据环境变量调整超时时间
+func (a *App) fitGracePeriod() {
+	if a.gracePeriodEnv == "" {
+		return
+	}
+	v := os.Getenv(a.gracePeriodEnv)
+	if v == "" {
+		return
+	}
+	budget, err := parseGracePeriod(v)
+	if err != nil || budget <= 0 {
+		a.logf("环境变量%s的值%q不是合法的时间，忽略", a.gracePeriodEnv, v)
+		return
+	}
+	// 留出余量给进程退出、preStop 之后信号的延迟
+	headroom := max(budget/10, time.Second)
+	if headroom >= budget {
+		headroom = budget / 2
+	}
+	limit := budget - headroom
+	if a.shutdownTimeout <= limit {
+		return
+	}
+	a.logf("优雅退出超时时间 %v 超过了%s设置的 %v，调整为 %v", a.shutdownTimeout, a.gracePeriodEnv, budget, limit)
+	a.shutdownTimeout = limit
+	if total := a.waitTime + a.cbTimeout; total > limit {
+		ratio := float64(limit) / float64(total)
+		a.waitTime = time.Duration(float64(a.waitTime) * ratio)
+		a.cbTimeout = time.Duration(float64(a.cbTimeout) * ratio)
+	}
+}
+
+// parseGracePeriod 解析秒数或者时间
+func parseGracePeriod(v string) (time.Duration, error) {
+	if sec, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+	return time.ParseDuration(v)
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithGracePeriodFromEnv(t *testing.T) {
+	testCases := []struct {
+		name         string
+		env          string
+		wantShutdown time.Duration
+		wantWait     time.Duration
+		wantCb       time.Duration
+	}{
+		{name: "unset", env: "", wantShutdown: 30 * time.Second, wantWait: 10 * time.Second, wantCb: 3 * time.Second},
+		{name: "enough", env: "60", wantShutdown: 30 * time.Second, wantWait: 10 * time.Second, wantCb: 3 * time.Second},
+		{name: "cap timeout", env: "20s", wantShutdown: 18 * time.Second, wantWait: 10 * time.Second, wantCb: 3 * time.Second},
+		{name: "scale phases", env: "10", wantShutdown: 9 * time.Second, wantWait: 9 * time.Second * 10 / 13, wantCb: 9 * time.Second * 3 / 13},
+		{name: "invalid", env: "abc", wantShutdown: 30 * time.Second, wantWait: 10 * time.Second, wantCb: 3 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(DefaultGracePeriodEnv, tc.env)
+			app := NewApp(nil, WithGracePeriodFromEnv(""))
+			if app.shutdownTimeout != tc.wantShutdown || app.waitTime != tc.wantWait || app.cbTimeout != tc.wantCb {
+				t.Fatalf("期望 %v %v %v，实际 %v %v %v", tc.wantShutdown, tc.wantWait, tc.wantCb,
+					app.shutdownTimeout, app.waitTime, app.cbTimeout)
+			}
+		})
+	}
+}
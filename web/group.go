@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Mount 把 handler 挂载到 prefix 下，handler 收到的请求路径去掉了 prefix
+func (s *Server) Mount(prefix string, handler http.Handler) *Server {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return s.Handle(prefix+"/", http.StripPrefix(prefix, handler))
+}
+
+// Group 路由分组，组内注册的路由都带有相同的前缀和中间件
+type Group struct {
+	server *Server
+	prefix string
+	mws    []Middleware
+}
+
+// Group 创建路由分组，mws 只作用于组内的路由
+func (s *Server) Group(prefix string, mws ...Middleware) *Group {
+	return &Group{server: s, prefix: strings.TrimSuffix(prefix, "/"), mws: mws}
+}
+
+// Group 创建子分组，子分组继承当前分组的前缀和中间件
+func (g *Group) Group(prefix string, mws ...Middleware) *Group {
+	sub := &Group{server: g.server, prefix: g.prefix + strings.TrimSuffix(prefix, "/")}
+	sub.mws = append(append(sub.mws, g.mws...), mws...)
+	return sub
+}
+
+// Use 给分组添加中间件，只影响之后注册的路由
+func (g *Group) Use(mws ...Middleware) *Group {
+	g.mws = append(g.mws, mws...)
+	return g
+}
+
+// Handle 注册路由，pattern 可以带方法，例如 "GET /users"
+func (g *Group) Handle(pattern string, handler http.Handler) *Group {
+	g.server.Handle(g.pattern(pattern), chain(handler, g.mws...))
+	return g
+}
+
+// HandleFunc 注册路由，pattern 可以带方法，例如 "GET /users"
+func (g *Group) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) *Group {
+	return g.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// pattern 把前缀加到路径前面，保留 pattern 中的方法
+func (g *Group) pattern(pattern string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return g.prefix + pattern
+	}
+	return method + " " + g.prefix + strings.TrimLeft(path, " ")
+}
+
+// chain 应用中间件，第一个中间件在最外层
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
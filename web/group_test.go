@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Mount(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var path string
+	s.Mount("/api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	if path != "/users" {
+		t.Fatalf("期望 /users，实际 %s", path)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	v1 := s.Group("/v1", mw("v1"))
+	v1.Group("/users", mw("users")).HandleFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, r.PathValue("id"))
+	})
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/42", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望 200，实际 %d", rec.Code)
+	}
+	if len(order) != 3 || order[0] != "v1" || order[1] != "users" || order[2] != "42" {
+		t.Fatalf("中间件执行顺序错误 %v", order)
+	}
+}
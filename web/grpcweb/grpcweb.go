@@ -0,0 +1,108 @@
+// Package grpcweb 让 web.App 管理 gRPC 服务器，和 HTTP 服务器共用超时时间一起优雅退出，
+// 单独成包，不使用 gRPC 的用户不会引入相关依赖
+package grpcweb
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	_ web.ManagedServer = (*Server)(nil)
+	_ web.Rejecter      = (*Server)(nil)
+)
+
+// Server 交给 web.App 管理的 gRPC 服务器
+type Server struct {
+	name string
+	srv  *grpc.Server
+	lis  net.Listener
+	// 开始优雅退出时设置成 NOT_SERVING 的健康检查服务
+	health   *health.Server
+	services []string
+}
+
+// Option 配置 Server
+type Option func(*Server)
+
+// WithHealthServer 开始优雅退出时把 h 中 services 的状态（以及代表整个服务器的 ""）设置成 NOT_SERVING，
+// 使用 gRPC 健康检查的负载均衡器和 Kubernetes 探针会在连接关闭之前停止转发新的 RPC。
+// h 需要已经注册到 gRPC 服务器上
+func WithHealthServer(h *health.Server, services ...string) Option {
+	return func(s *Server) {
+		s.health = h
+		s.services = services
+	}
+}
+
+// NewGRPCServer 创建在 lis 上提供服务的 gRPC 服务器，通过 web.WithServers 交给 App 管理
+func NewGRPCServer(name string, s *grpc.Server, lis net.Listener, opts ...Option) *Server {
+	res := &Server{name: name, srv: s, lis: lis}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// Reject 把健康检查设置成 NOT_SERVING，没有设置 WithHealthServer 时什么都不做。
+// gRPC 没有办法拒绝已经建立的连接上的新 RPC，正在处理的 RPC 由 Stop 等待
+func (s *Server) Reject() {
+	s.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Accept 把健康检查恢复成 SERVING
+func (s *Server) Accept() {
+	s.setServing(healthpb.HealthCheckResponse_SERVING)
+}
+
+func (s *Server) setServing(status healthpb.HealthCheckResponse_ServingStatus) {
+	if s.health == nil {
+		return
+	}
+	s.health.SetServingStatus("", status)
+	for _, svc := range s.services {
+		s.health.SetServingStatus(svc, status)
+	}
+}
+
+// Name 服务器名称，用于日志
+func (s *Server) Name() string {
+	return s.name
+}
+
+// ListenerAddr 监听的地址，web.WithInstanceRegistrar 注册服务时使用
+func (s *Server) ListenerAddr() net.Addr {
+	return s.lis.Addr()
+}
+
+// Start 在 lis 上提供服务，阻塞直到服务器关闭
+func (s *Server) Start() error {
+	err := s.srv.Serve(s.lis)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Stop 调用 GracefulStop 等待正在处理的 RPC 结束，ctx 超时后调用 Stop 强制关闭所有连接
+func (s *Server) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
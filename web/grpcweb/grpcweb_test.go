@@ -0,0 +1,97 @@
+package grpcweb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*Server, healthpb.HealthClient) {
+	return newTestServerWithHealth(t, health.NewServer(), opts...)
+}
+
+func newTestServerWithHealth(t *testing.T, h *health.Server, opts ...Option) (*Server, healthpb.HealthClient) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	healthpb.RegisterHealthServer(gs, h)
+	s := NewGRPCServer("grpc", gs, lis, opts...)
+	go func() {
+		_ = s.Start()
+	}()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return s, healthpb.NewHealthClient(conn)
+}
+
+func TestServer_Stop(t *testing.T) {
+	s, client := newTestServer(t)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("没有正在处理的 RPC 时应该正常关闭: %v", err)
+	}
+}
+
+func TestServer_StopTimeout(t *testing.T) {
+	s, client := newTestServer(t)
+	// Watch 是一直不结束的流式 RPC，GracefulStop 会一直等待
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err = s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望超时错误，实际 %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("超时后应该强制关闭")
+	}
+	if _, err = stream.Recv(); err == nil {
+		t.Fatal("强制关闭后流应该被断开")
+	}
+}
+
+func TestServer_RejectWithApp(t *testing.T) {
+	h := health.NewServer()
+	s, _ := newTestServerWithHealth(t, h, WithHealthServer(h, "orders"))
+	app := web.NewApp(nil, web.WithServers(s), web.WithWaitTime(0))
+	check := func(svc string) healthpb.HealthCheckResponse_ServingStatus {
+		res, err := h.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Status
+	}
+	s.Accept()
+	if check("orders") != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal("Accept 之后应该是 SERVING")
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if check("") != healthpb.HealthCheckResponse_NOT_SERVING || check("orders") != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatal("优雅退出时应该把健康检查设置成 NOT_SERVING")
+	}
+}
@@ -0,0 +1,17 @@
+package web
+
+import "net/http"
+
+// WithH2C 在不开启 TLS 的情况下同时支持 HTTP/1.1 和 HTTP/2 cleartext（h2c，prior knowledge 方式），
+// 例如内部的 gRPC-gateway、只在集群内部转发的代理。h2c 连接由 http.Server 自己管理，
+// Stop 时和 HTTPS 上的 HTTP/2 一样发送 GOAWAY，已经在处理的 stream 会继续执行完
+func WithH2C() ServerOption {
+	return func(s *Server) {
+		if s.srv.Protocols == nil {
+			s.srv.Protocols = new(http.Protocols)
+			s.srv.Protocols.SetHTTP1(true)
+			s.srv.Protocols.SetHTTP2(true)
+		}
+		s.srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+}
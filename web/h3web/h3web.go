@@ -0,0 +1,126 @@
+// Package h3web 给 web.Server 加上 HTTP/3（QUIC）监听，和 TCP listener 共用路由、中间件，一起优雅退出，
+// 单独成包，不使用 HTTP/3 的用户不会引入 quic-go
+package h3web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var _ web.ProtocolServer = (*server)(nil)
+
+// errNoTLSConfig 没有传入 TLS 配置，HTTP/3 必须使用 TLS
+var errNoTLSConfig = errors.New("h3web: HTTP/3 需要 TLS 配置")
+
+// Option 配置 HTTP/3 监听
+type Option func(*server)
+
+// WithQUICConfig 设置 QUIC 的配置，例如 MaxIncomingStreams、KeepAlivePeriod
+func WithQUICConfig(conf *quic.Config) Option {
+	return func(s *server) {
+		s.quic = conf
+	}
+}
+
+// WithHTTP3 服务器同时在 UDP 地址 addr 上提供 HTTP/3，conf 是 TLS 配置，ALPN 会自动设置成 h3。
+// 请求经过和 TCP listener 一样的路由和中间件，计入 InFlight；优雅退出时先发送 GOAWAY 不再接受新的 stream，
+// 等待正在处理的请求结束，超过关闭超时之后强制关闭连接。
+// TCP 上的响应会带上 Alt-Svc 头，客户端据此切换到 HTTP/3
+func WithHTTP3(addr string, conf *tls.Config, opts ...Option) web.ServerOption {
+	return func(s *web.Server) {
+		p := &server{addr: addr, tls: conf}
+		for _, opt := range opts {
+			opt(p)
+		}
+		web.WithProtocolServer(p)(s)
+		s.Use(p.altSvc)
+	}
+}
+
+// server 实现 web.ProtocolServer，每次 Listen 创建新的 http3.Server，关闭之后可以重新监听
+type server struct {
+	addr string
+	tls  *tls.Config
+	quic *quic.Config
+
+	mu   sync.Mutex
+	conn net.PacketConn
+	srv  *http3.Server
+}
+
+func (s *server) Listen() (net.Addr, error) {
+	if s.tls == nil {
+		return nil, errNoTLSConfig
+	}
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.srv = &http3.Server{TLSConfig: s.tls, QUICConfig: s.quic}
+	s.mu.Unlock()
+	return conn.LocalAddr(), nil
+}
+
+func (s *server) Serve(h http.Handler) error {
+	srv, conn := s.current()
+	if srv == nil {
+		return http.ErrServerClosed
+	}
+	srv.Handler = h
+	return srv.Serve(conn)
+}
+
+func (s *server) Shutdown(ctx context.Context) error {
+	srv, conn := s.current()
+	if srv == nil {
+		return nil
+	}
+	err := srv.Shutdown(ctx)
+	// http3.Server 不会关闭传给 Serve 的 conn
+	return errors.Join(err, closeConn(conn))
+}
+
+func (s *server) Close() error {
+	srv, conn := s.current()
+	if srv == nil {
+		return nil
+	}
+	return errors.Join(srv.Close(), closeConn(conn))
+}
+
+func (s *server) current() (*http3.Server, net.PacketConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.srv, s.conn
+}
+
+// altSvc 在不是 HTTP/3 的响应上加上 Alt-Svc 头
+func (s *server) altSvc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			if srv, _ := s.current(); srv != nil {
+				// 还没有开始 Serve 时拿不到端口，不影响请求
+				_ = srv.SetQUICHeaders(w.Header())
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func closeConn(conn net.PacketConn) error {
+	err := conn.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package h3web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func newTestTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestWithHTTP3(t *testing.T) {
+	conf, pool := newTestTLS(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := web.NewServer("api", "127.0.0.1:0", WithHTTP3("127.0.0.1:0", conf))
+	s.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	})
+	s.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+	app := web.NewApp([]*web.Server{s}, web.WithWaitTime(0), web.WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var udp string
+	for _, l := range s.Listeners() {
+		if l.Network == "udp" {
+			udp = l.Addr
+		}
+	}
+	if udp == "" {
+		t.Fatalf("Listeners 应该包含 HTTP/3 的 UDP 地址，实际 %v", s.Listeners())
+	}
+
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer func() { _ = tr.Close() }()
+	client := &http.Client{Transport: tr, Timeout: 5 * time.Second}
+	resp, err := client.Get("https://" + udp + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "HTTP/3.0" {
+		t.Fatalf("期望通过 HTTP/3 处理请求，实际 %q", body)
+	}
+
+	resp, err = http.Get("http://" + s.ListenerAddr().String() + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if _, port, _ := net.SplitHostPort(udp); !strings.Contains(resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`) {
+		t.Fatalf("TCP 上的响应应该通过 Alt-Svc 声明 HTTP/3，实际 %q", resp.Header.Get("Alt-Svc"))
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := client.Get("https://" + udp + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		slow <- string(body)
+	}()
+	<-started
+	if n := s.InFlight(); n != 1 {
+		t.Fatalf("HTTP/3 请求应该计入 InFlight，实际 %d", n)
+	}
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- app.Shutdown(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if got := <-slow; got != "done" {
+		t.Fatalf("优雅退出时正在处理的 HTTP/3 请求应该正常结束，实际 %q", got)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	client.Timeout = 500 * time.Millisecond
+	if _, err := client.Get("https://" + udp + "/hello"); err == nil {
+		t.Fatal("关闭之后 HTTP/3 不应该再接受请求")
+	}
+}
+
+func TestWithHTTP3_NoTLS(t *testing.T) {
+	s := web.NewServer("api", "127.0.0.1:0", WithHTTP3("127.0.0.1:0", nil))
+	if err := web.NewApp([]*web.Server{s}, web.WithLogOutput(io.Discard)).Start(); err == nil {
+		t.Fatal("没有 TLS 配置时启动应该失败")
+	}
+}
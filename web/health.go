@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// healthCheck 注册的健康检查
+type healthCheck struct {
+	name  string
+	check HealthChecker
+}
+
+// AddHealthCheck 注册健康检查，例如数据库、下游服务是否可用。
+// 需要在 Start 之前调用
+// 开启 WithHealthEndpoints 时检查结果会汇总到 /readyz 的响应中
+func (a *App) AddHealthCheck(name string, check HealthChecker) {
+	a.healthChecks = append(a.healthChecks, healthCheck{name: name, check: check})
+}
+
+// CheckHealth 执行所有健康检查，返回的错误包含所有失败的检查
+func (a *App) CheckHealth(ctx context.Context) error {
+	var errs []error
+	for _, hc := range a.healthChecks {
+		if err := hc.check(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("web: 健康检查%s失败: %w", hc.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithSelfShutdownOnUnhealthy 启动之后每隔 checkInterval 执行一次 AddHealthCheck 注册的健康检查，
+// 持续失败超过 failureWindow 时 StartAndServe 开始优雅退出，让编排系统重新拉起实例。
+// 用于实例卡死但是存活探针还没有发现的场景
+func WithSelfShutdownOnUnhealthy(checkInterval, failureWindow time.Duration) Option {
+	return func(app *App) {
+		app.healthInterval = checkInterval
+		app.unhealthyWindow = failureWindow
+	}
+}
+
+// startHealthWatch 启动健康检查的 worker，开始优雅退出时停止
+func (a *App) startHealthWatch() {
+	if a.healthInterval <= 0 || len(a.healthChecks) == 0 {
+		return
+	}
+	ctx, done := a.TrackWorker()
+	go func() {
+		defer done()
+		a.watchHealth(ctx)
+	}()
+}
+
+// watchHealth 定时执行健康检查，持续失败超过 unhealthyWindow 时关闭应用
+func (a *App) watchHealth(ctx context.Context) {
+	ticker := a.clock.NewTicker(a.healthInterval)
+	defer ticker.Stop()
+	var failingSince time.Time
+	for {
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return
+		}
+		checkCtx, cancel := a.withTimeout(ctx, a.healthInterval)
+		err := a.CheckHealth(checkCtx)
+		cancel()
+		if err == nil {
+			failingSince = time.Time{}
+			continue
+		}
+		if failingSince.IsZero() {
+			failingSince = a.clock.Now()
+		}
+		if d := a.since(failingSince); d >= a.unhealthyWindow {
+			a.logf("健康检查持续失败 %v，关闭应用: %v", d, err)
+			a.triggerShutdown()
+			return
+		}
+		a.logf("健康检查失败 %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithSelfShutdownOnUnhealthy(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	s := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{s}, WithSignals(), WithWaitTime(0),
+		WithSelfShutdownOnUnhealthy(10*time.Millisecond, 100*time.Millisecond))
+	app.AddHealthCheck("db", func(ctx context.Context) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("db unreachable")
+	})
+	done := make(chan struct{})
+	go func() {
+		app.StartAndServe()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("健康时不应该关闭应用")
+	case <-time.After(200 * time.Millisecond):
+	}
+	healthy.Store(false)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("健康检查持续失败之后应该关闭应用")
+	}
+}
+
+func TestApp_CheckHealth(t *testing.T) {
+	app := NewApp(nil)
+	errDB := errors.New("db unreachable")
+	app.AddHealthCheck("db", func(ctx context.Context) error { return errDB })
+	app.AddHealthCheck("cache", func(ctx context.Context) error { return nil })
+	if err := app.CheckHealth(context.Background()); !errors.Is(err, errDB) {
+		t.Fatalf("期望健康检查失败，实际 %v", err)
+	}
+}
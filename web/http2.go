@@ -0,0 +1,25 @@
+package web
+
+import (
+	"golang.org/x/net/http2"
+)
+
+// WithHTTP2 显式配置 HTTP/2。
+// Stop 时 http.Server.Shutdown 会给客户端发送 GOAWAY，已经在处理的 stream 会继续执行完
+func WithHTTP2(conf *http2.Server) ServerOption {
+	return func(s *Server) {
+		s.http2 = conf
+	}
+}
+
+// ActiveStreams 返回当前正在处理的 HTTP/2 和 HTTP/3 stream 数量
+func (s *Server) ActiveStreams() int64 {
+	return s.mux.streams.Load()
+}
+
+func (s *Server) configureHTTP2() error {
+	if s.http2 == nil {
+		return nil
+	}
+	return http2.ConfigureServer(s.srv, s.http2)
+}
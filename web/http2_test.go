@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTP2_StreamDrain(t *testing.T) {
+	s := NewServer("h2", "localhost:0")
+	started := make(chan struct{})
+	s.Handle("/stream", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	ts := httptest.NewUnstartedServer(s.mux)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := ts.Client().Get(ts.URL + "/stream")
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("期望 HTTP/2，实际 %s", resp.Proto)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resCh <- result{body: string(body), err: err}
+	}()
+	<-started
+	if n := s.ActiveStreams(); n != 1 {
+		t.Fatalf("期望 1 个活跃 stream，实际 %d", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ts.Config.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	res := <-resCh
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("stream 被提前中断: %v %q", res.err, res.body)
+	}
+	if n := s.ActiveStreams(); n != 0 {
+		t.Fatalf("期望 0 个活跃 stream，实际 %d", n)
+	}
+}
+
+func TestWithH2C(t *testing.T) {
+	s := NewServer("h2c", "127.0.0.1:0", WithH2C())
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Shutdown(context.Background()) }()
+	url := "http://" + s.ListenerAddr().String() + "/"
+
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	defer tr.CloseIdleConnections()
+	for _, c := range []struct {
+		client *http.Client
+		want   string
+	}{
+		{&http.Client{Transport: tr}, "HTTP/2.0"},
+		{http.DefaultClient, "HTTP/1.1"},
+	} {
+		resp, err := c.client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != c.want {
+			t.Fatalf("期望 %s，实际 %q", c.want, body)
+		}
+	}
+}
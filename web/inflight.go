@@ -0,0 +1,124 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InFlightRequest 正在处理的请求
+type InFlightRequest struct {
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Start  time.Time `json:"start"`
+}
+
+// WithInFlightDebug 记录每个正在处理的请求，优雅退出等待超时时打印出还没有完成的请求，
+// 方便排查是哪个请求拖慢了退出。会给每个请求增加少量开销，默认关闭
+func WithInFlightDebug() ServerOption {
+	return func(s *Server) {
+		s.mux.registry = &inFlightRegistry{reqs: make(map[uint64]InFlightRequest)}
+	}
+}
+
+// InFlightRequests 返回正在处理的请求，按开始时间排序。
+// 没有开启 WithInFlightDebug 时返回 nil
+func (s *Server) InFlightRequests() []InFlightRequest {
+	if s.mux.registry == nil {
+		return nil
+	}
+	return s.mux.registry.list()
+}
+
+type inFlightRegistry struct {
+	mu   sync.Mutex
+	id   atomic.Uint64
+	reqs map[uint64]InFlightRequest
+}
+
+// add 登记请求，返回的函数在请求处理完之后调用
+func (r *inFlightRegistry) add(req *http.Request) func() {
+	id := r.id.Add(1)
+	r.mu.Lock()
+	r.reqs[id] = InFlightRequest{Method: req.Method, Path: req.URL.Path, Start: time.Now()}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.reqs, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *inFlightRegistry) list() []InFlightRequest {
+	r.mu.Lock()
+	res := make([]InFlightRequest, 0, len(r.reqs))
+	for _, req := range r.reqs {
+		res = append(res, req)
+	}
+	r.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+	return res
+}
+
+// inFlightLister 可以列出正在处理的请求的服务器
+type inFlightLister interface {
+	InFlightRequests() []InFlightRequest
+}
+
+// logStragglers 打印等待超时之后仍然没有完成的请求
+func (a *App) logStragglers(servers []ManagedServer) {
+	now := time.Now()
+	for _, s := range servers {
+		l, ok := s.(inFlightLister)
+		if !ok {
+			continue
+		}
+		for _, req := range l.InFlightRequests() {
+			a.logf("服务器%s仍有请求未完成: %s %s 已执行 %s", s.Name(), req.Method, req.Path, now.Sub(req.Start).Round(time.Millisecond))
+		}
+	}
+}
+
+// serverInFlight InFlightHandler 返回的单个服务器的正在处理的请求
+type serverInFlight struct {
+	Name     string          `json:"name"`
+	InFlight int64           `json:"in_flight"`
+	Requests []inFlightEntry `json:"requests,omitempty"`
+}
+
+type inFlightEntry struct {
+	InFlightRequest
+	Age string `json:"age"`
+}
+
+// InFlightHandler 以 JSON 格式返回每个服务器正在处理的请求数，开启了 WithInFlightDebug 的服务器
+// 还会列出每个请求的方法、路径和已经执行的时间。注册在 WithNoDrain 的 admin 服务器上，
+// 优雅退出等待请求时可以实时查看还有哪些请求没有完成，决定继续等待还是强制退出
+func (a *App) InFlightHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		res := struct {
+			Draining bool             `json:"draining"`
+			Servers  []serverInFlight `json:"servers"`
+		}{Draining: a.IsDraining(), Servers: make([]serverInFlight, 0, len(a.servers))}
+		for _, s := range a.servers {
+			si := serverInFlight{Name: s.Name()}
+			if c, ok := s.(inFlightCounter); ok {
+				si.InFlight = c.InFlight()
+			}
+			if l, ok := s.(inFlightLister); ok {
+				for _, req := range l.InFlightRequests() {
+					si.Requests = append(si.Requests, inFlightEntry{InFlightRequest: req, Age: now.Sub(req.Start).Round(time.Millisecond).String()})
+				}
+			}
+			res.Servers = append(res.Servers, si)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
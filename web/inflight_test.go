@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithInFlightDebug(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithInFlightDebug())
+	var inHandler []InFlightRequest
+	s.HandleFunc("/slow-report", func(w http.ResponseWriter, r *http.Request) {
+		inHandler = s.InFlightRequests()
+	})
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/slow-report", nil))
+	if len(inHandler) != 1 || inHandler[0].Method != http.MethodPost || inHandler[0].Path != "/slow-report" {
+		t.Fatalf("没有记录正在处理的请求: %v", inHandler)
+	}
+	if n := len(s.InFlightRequests()); n != 0 {
+		t.Fatalf("请求处理完之后应该移除，实际还有 %d 个", n)
+	}
+	if NewServer("test", "localhost:0").InFlightRequests() != nil {
+		t.Fatal("没有开启时应该返回 nil")
+	}
+}
+
+func TestApp_InFlightHandler(t *testing.T) {
+	api := NewServer("api", "localhost:0", WithInFlightDebug())
+	admin := NewServer("admin", "localhost:0", WithNoDrain())
+	app := NewApp([]*Server{api, admin})
+	admin.Handle("/debug/inflight", app.InFlightHandler())
+
+	var body string
+	api.HandleFunc("/slow-report", func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		admin.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/inflight", nil))
+		body = rec.Body.String()
+	})
+	api.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/slow-report", nil))
+
+	var got struct {
+		Draining bool `json:"draining"`
+		Servers  []struct {
+			Name     string `json:"name"`
+			InFlight int64  `json:"in_flight"`
+			Requests []struct {
+				Method string `json:"method"`
+				Path   string `json:"path"`
+				Age    string `json:"age"`
+			} `json:"requests"`
+		} `json:"servers"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Servers) != 2 || got.Servers[0].Name != "api" || got.Servers[0].InFlight != 1 {
+		t.Fatalf("服务器的请求数错误: %s", body)
+	}
+	reqs := got.Servers[0].Requests
+	if len(reqs) != 1 || reqs[0].Method != http.MethodPost || reqs[0].Path != "/slow-report" || reqs[0].Age == "" {
+		t.Fatalf("应该列出正在处理的请求: %s", body)
+	}
+	if got.Servers[1].InFlight != 1 || got.Servers[1].Requests != nil {
+		t.Fatalf("admin 服务器没有开启 WithInFlightDebug，只返回请求数: %s", body)
+	}
+}
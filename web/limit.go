@@ -0,0 +1,111 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithMaxConcurrentRequests 限制服务器同时处理的请求数，超过 n 个时直接返回 503 和 Retry-After，不会排队。
+// 和 Concurrency 中间件不同，计数和优雅退出等待的正在处理的请求数是同一个，
+// 优雅退出期间新请求先被拒绝，已经在处理的请求正常结束、释放名额
+func WithMaxConcurrentRequests(n int) ServerOption {
+	return func(s *Server) {
+		s.mux.maxConcurrent = int64(n)
+	}
+}
+
+// WithRateLimit 使用令牌桶限制服务器每秒处理的请求数，平均 rps 个，最多允许 burst 个突发请求，
+// 超过时返回 429 和 Retry-After。优雅退出期间被拒绝的请求不消耗令牌
+func WithRateLimit(rps float64, burst int) ServerOption {
+	return func(s *Server) {
+		s.mux.rateLimit = newTokenBucket(rps, burst)
+	}
+}
+
+// LimiterStats 服务器限流的状态，没有开启的限制为 0
+type LimiterStats struct {
+	// InFlight 正在处理的请求数，MaxConcurrent 是 WithMaxConcurrentRequests 设置的上限
+	InFlight      int64
+	MaxConcurrent int64
+	// Rate 和 Burst 是 WithRateLimit 的配置
+	Rate  float64
+	Burst int
+	// ConcurrencyLimited 和 RateLimited 分别是因为并发数和速率被拒绝的请求数
+	ConcurrencyLimited int64
+	RateLimited        int64
+}
+
+// LimiterStats 返回服务器限流的状态，promweb 会把它们导出为指标
+func (s *Server) LimiterStats() LimiterStats {
+	res := LimiterStats{
+		InFlight:           s.mux.inFlight.Load(),
+		MaxConcurrent:      s.mux.maxConcurrent,
+		ConcurrencyLimited: s.mux.concurrencyLimited.Load(),
+		RateLimited:        s.mux.rateLimited.Load(),
+	}
+	if tb := s.mux.rateLimit; tb != nil {
+		res.Rate, res.Burst = tb.rate, int(tb.burst)
+	}
+	return res
+}
+
+// admit 检查限流，不通过时已经写好了响应；通过时已经把请求计入 inFlight
+func (s *serverMux) admit(w http.ResponseWriter, access *accessRecord) bool {
+	if s.rateLimit != nil {
+		if wait, ok := s.rateLimit.take(s.clock.Now()); !ok {
+			s.rateLimited.Add(1)
+			access.reject("rate_limit")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return false
+		}
+	}
+	if n := s.inFlight.Add(1); s.maxConcurrent > 0 && n > s.maxConcurrent {
+		s.inFlight.Add(-1)
+		s.concurrencyLimited.Add(1)
+		access.reject("concurrency")
+		w.Header().Set("Retry-After", concurrencyRetryAfter)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	// last 上次补充令牌的时间，为零值时还没有取过令牌
+	last time.Time
+}
+
+// newTokenBucket 创建装满令牌的令牌桶。时间由调用 take 的一方传入，
+// 这样 NewApp 替换服务器的 Clock 之后也能生效
+func newTokenBucket(rps float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rps, burst: float64(burst), tokens: float64(burst)}
+}
+
+// take 在 now 时取一个令牌，没有令牌时返回需要等待的时间
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.last = now
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if b.rate <= 0 {
+		return time.Second, false
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrentRequests(t *testing.T) {
+	s := NewServer("api", "localhost:0", WithMaxConcurrentRequests(1))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	first := httptest.NewRecorder()
+	go func() {
+		defer wg.Done()
+		s.mux.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("超过并发上限时期望 503 和 Retry-After，实际 %d %v", rec.Code, rec.Header())
+	}
+	// 优雅退出期间新请求直接被拒绝，不计入限流；已经在处理的请求正常结束
+	s.rejectReq()
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	close(release)
+	wg.Wait()
+	if first.Code != http.StatusOK {
+		t.Fatalf("正在处理的请求应该正常结束，实际 %d", first.Code)
+	}
+	st := s.LimiterStats()
+	if st.InFlight != 0 || st.MaxConcurrent != 1 || st.ConcurrencyLimited != 1 || s.RejectedCount() != 1 {
+		t.Fatalf("限流状态不对 %+v，拒绝数 %d", st, s.RejectedCount())
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	s := NewServer("api", "localhost:0", WithRateLimit(10, 2))
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	codes := make([]int, 3)
+	for i := range codes {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("突发 2 个之后应该返回 429，实际 %v", codes)
+	}
+	time.Sleep(150 * time.Millisecond)
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("补充令牌之后应该恢复处理，实际 %d", rec.Code)
+	}
+	if st := s.LimiterStats(); st.RateLimited != 1 || st.Rate != 10 || st.Burst != 2 {
+		t.Fatalf("限流状态不对 %+v", st)
+	}
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithListenerConfig 创建监听之后调用 fn 配置 TCP listener，fn 返回错误时启动失败。
+// 监听的不是 TCP 时不会调用
+func WithListenerConfig(fn func(*net.TCPListener) error) ServerOption {
+	return func(s *Server) {
+		s.listenerHooks = append(s.listenerHooks, fn)
+	}
+}
+
+// WithTCPKeepAlive 设置接受的连接的 TCP keep-alive 间隔，负数表示关闭 keep-alive，
+// 默认使用 net 包的默认值
+func WithTCPKeepAlive(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.listenConfig.KeepAlive = d
+	}
+}
+
+// WithTCPLinger 设置接受的连接关闭时的 SO_LINGER，语义同 net.TCPConn.SetLinger。
+// 设置为 0 时关闭连接直接丢弃未发送的数据并发送 RST，优雅退出强制关闭连接时能立刻释放
+func WithTCPLinger(sec int) ServerOption {
+	return func(s *Server) {
+		s.connHooks = append(s.connHooks, func(c *net.TCPConn) error {
+			return c.SetLinger(sec)
+		})
+	}
+}
+
+// newListener 按照配置创建 listener
+func (s *Server) newListener(addr string) (net.Listener, error) {
+	// 平滑升级时优先使用父进程传过来的 listener
+	lis, err := inheritedListener(s.name, addr)
+	if lis == nil && err == nil {
+		lis, err = s.listenConfig.Listen(context.Background(), "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tl, ok := lis.(*net.TCPListener)
+	if !ok {
+		return lis, nil
+	}
+	s.rawListeners = append(s.rawListeners, boundListener{addr: addr, lis: tl})
+	for _, fn := range s.listenerHooks {
+		if err = fn(tl); err != nil {
+			_ = lis.Close()
+			return nil, err
+		}
+	}
+	return s.wrapListener(lis), nil
+}
+
+// wrapListener 按照配置包装 listener，TCP、Unix socket 和注入的 listener 都会经过这里
+func (s *Server) wrapListener(lis net.Listener) net.Listener {
+	if len(s.connHooks) > 0 {
+		lis = &hookListener{Listener: lis, hooks: s.connHooks}
+	}
+	if s.conns != nil {
+		lis = &trackListener{Listener: lis, t: s.conns}
+	}
+	return lis
+}
+
+// hookListener 接受连接之后按照配置设置 TCP 连接，设置失败时关闭这个连接
+type hookListener struct {
+	net.Listener
+	hooks []func(*net.TCPConn) error
+}
+
+func (l *hookListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		tc, ok := c.(*net.TCPConn)
+		if !ok {
+			return c, nil
+		}
+		if err = l.configure(tc); err != nil {
+			_ = c.Close()
+			continue
+		}
+		return c, nil
+	}
+}
+
+func (l *hookListener) configure(c *net.TCPConn) error {
+	for _, fn := range l.hooks {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithListenerConfig(t *testing.T) {
+	errConfig := errors.New("config failed")
+	var called bool
+	s := NewServer("test", "localhost:0", WithListenerConfig(func(l *net.TCPListener) error {
+		called = true
+		return errConfig
+	}))
+	if err := s.listen(); !errors.Is(err, errConfig) {
+		t.Fatalf("期望配置 listener 返回的错误，实际 %v", err)
+	}
+	if !called {
+		t.Fatal("应该调用配置函数")
+	}
+}
+
+func TestWithTCPLinger(t *testing.T) {
+	addr := freeAddr(t)
+	var configured atomic.Int32
+	s := NewServer("test", addr, WithTCPKeepAlive(time.Minute), WithTCPLinger(0), func(s *Server) {
+		s.connHooks = append(s.connHooks, func(c *net.TCPConn) error {
+			configured.Add(1)
+			return nil
+		})
+	})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "ok" {
+		t.Fatalf("期望 ok，实际 %q", body)
+	}
+	if configured.Load() != 1 {
+		t.Fatalf("接受的连接应该被配置一次，实际 %d", configured.Load())
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WithLogOutput 把应用的生命周期日志输出到 w，例如文件或者测试中的 buffer，默认输出到标准库 log
+func WithLogOutput(w io.Writer) Option {
+	return func(app *App) {
+		app.logger = log.New(w, "", log.LstdFlags)
+	}
+}
+
+// WithLogger 使用 l 输出结构化的生命周期日志，设置之后 WithLogOutput 不再生效。
+// 应用名称、版本、元数据作为 app、version 和元数据的 key 输出，
+// 关键的生命周期事件还会带上 event（例如 event=shutdown_start）以及 server、duration 等字段，方便检索
+func WithLogger(l *slog.Logger) Option {
+	return func(app *App) {
+		app.slogger = l
+	}
+}
+
+// WithServerLogger 使用 l 输出 http.Server 内部的错误日志，例如 TLS 握手失败
+func WithServerLogger(l *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.srv.ErrorLog = slog.NewLogLogger(l.Handler(), slog.LevelError)
+	}
+}
+
+// logf 输出应用的生命周期日志，设置了应用名称、版本、元数据时带上 "[name version k=v] " 前缀
+func (a *App) logf(format string, args ...any) {
+	a.logEvent("", nil, format, args...)
+}
+
+// logEvent 输出生命周期事件，没有设置 WithLogger 时和 logf 的输出一样，
+// 否则 event 和 kv（按照 key, value 成对传入）作为结构化的字段输出
+func (a *App) logEvent(event string, kv []any, format string, args ...any) {
+	if a.slogger != nil {
+		attrs := a.logAttrs()
+		if event != "" {
+			attrs = append(attrs, "event", event)
+		}
+		a.slogger.Log(context.Background(), slog.LevelInfo, fmt.Sprintf(format, args...), append(attrs, kv...)...)
+		return
+	}
+	msg := a.logPrefix() + fmt.Sprintf(format, args...)
+	if a.logger != nil {
+		a.logger.Print(msg)
+		return
+	}
+	log.Print(msg)
+}
+
+// logAttrs 结构化日志中代替前缀输出的应用名称、版本、元数据
+func (a *App) logAttrs() []any {
+	var attrs []any
+	if a.name != "" {
+		attrs = append(attrs, "app", a.name)
+	}
+	if a.version != "" {
+		attrs = append(attrs, "version", a.version)
+	}
+	for _, k := range slices.Sorted(maps.Keys(a.metadata)) {
+		attrs = append(attrs, k, a.metadata[k])
+	}
+	return attrs
+}
+
+func (a *App) logPrefix() string {
+	var parts []string
+	for _, p := range []string{a.name, a.version, formatMetadata(a.metadata)} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " ") + "] "
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestApp_LogPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	out, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}()
+	NewApp(nil, WithAppName("order"), WithVersion("v1.2.0")).logf("应用关闭")
+	if got := strings.TrimSpace(buf.String()); got != "[order v1.2.0] 应用关闭" {
+		t.Fatalf("日志前缀错误: %q", got)
+	}
+}
+
+func TestApp_LogStopDuration(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+	app := NewApp(nil, WithServers(&tcpServer{stopped: make(chan struct{})}))
+	if err := app.stopServers(context.Background(), app.servers); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "服务器tcp关闭耗时") {
+		t.Fatalf("应该输出服务器关闭耗时: %q", buf.String())
+	}
+}
+
+func TestWithLogOutput(t *testing.T) {
+	var buf bytes.Buffer
+	NewApp(nil, WithLogOutput(&buf), WithAppName("order")).logf("应用关闭")
+	if !strings.HasSuffix(strings.TrimSpace(buf.String()), "[order] 应用关闭") {
+		t.Fatalf("日志应该输出到指定的 writer: %q", buf.String())
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, nil))
+	app := NewApp(nil, WithLogger(l), WithAppName("order"), WithServers(&tcpServer{stopped: make(chan struct{})}))
+	if err := app.stopServers(context.Background(), app.servers); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"event=server_stopped", "server=tcp", "app=order", "duration="} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("结构化日志缺少 %s: %q", want, out)
+		}
+	}
+	if strings.Contains(out, "[order]") {
+		t.Fatalf("结构化日志不应该带前缀: %q", out)
+	}
+}
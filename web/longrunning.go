@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithLongRunning 用 pred 标记长时间运行的请求，例如文件上传下载：
+//
+//	WithLongRunning(func(r *http.Request) bool {
+//		return strings.HasPrefix(r.URL.Path, "/upload/")
+//	})
+//
+// 优雅退出时普通请求处理完之后，这些请求可以继续处理到 WithLongRunningWaitTime
+func WithLongRunning(pred func(r *http.Request) bool) ServerOption {
+	return func(s *Server) {
+		s.mux.longRunning = pred
+	}
+}
+
+// WithLongRunningWaitTime 设置优雅退出时等待长时间运行的请求的时间，从开始等待已有请求时算起。
+// 小于 WithWaitTime 时不会额外等待
+func WithLongRunningWaitTime(d time.Duration) Option {
+	return func(app *App) {
+		app.longRunningWaitTime = d
+	}
+}
+
+// longRunningCounter 可以统计正在处理的长时间运行请求数的服务器
+type longRunningCounter interface {
+	LongRunningInFlight() int64
+}
+
+// LongRunningInFlight 正在处理的长时间运行的请求数
+func (s *Server) LongRunningInFlight() int64 {
+	return s.mux.longInFlight.Load()
+}
+
+// longRunningInFlight 服务器正在处理的长时间运行的请求数之和
+func longRunningInFlight(servers []ManagedServer) int64 {
+	var n int64
+	for _, s := range uniqueCounters(servers) {
+		if c, ok := s.(longRunningCounter); ok {
+			n += c.LongRunningInFlight()
+		}
+	}
+	return n
+}
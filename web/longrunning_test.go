@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLongRunning(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithLongRunning(func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, "/upload/")
+	}))
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	for _, path := range []string{"/upload/a", "/api"} {
+		go s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	<-started
+	<-started
+	if n := s.LongRunningInFlight(); n != 1 {
+		t.Fatalf("期望 1 个长时间运行的请求，实际 %d", n)
+	}
+
+	app := NewApp([]*Server{s}, WithLongRunningWaitTime(time.Minute))
+	// 普通请求没有在 waitTime 内处理完，不再等待
+	start := time.Now()
+	if app.waitDrained(context.Background(), app.servers, 50*time.Millisecond) {
+		t.Fatal("普通请求还没有处理完")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("普通请求超时之后不应该继续等待")
+	}
+	close(release)
+	for s.InFlight() > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWithLongRunningWaitTime(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithLongRunning(func(r *http.Request) bool { return true }))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	go s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/upload", nil))
+	<-started
+	app := NewApp([]*Server{s}, WithLongRunningWaitTime(time.Minute))
+	time.AfterFunc(200*time.Millisecond, func() { close(release) })
+	// 只剩下长时间运行的请求时，超过 waitTime 之后继续等待
+	if !app.waitDrained(context.Background(), app.servers, 50*time.Millisecond) {
+		t.Fatal("应该等待长时间运行的请求处理完")
+	}
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RejectResponse 维护模式下返回给客户端的响应
+type RejectResponse struct {
+	// StatusCode 默认 503
+	StatusCode int
+	// ContentType 默认 "text/plain; charset=utf-8"
+	ContentType string
+	// Body 默认 "服务维护中"
+	Body string
+	// RetryAfter 大于 0 时设置 Retry-After 头
+	RetryAfter time.Duration
+	// ExemptPaths 维护期间仍然正常处理的路径前缀，例如 "/healthz"、"/admin/"
+	ExemptPaths []string
+}
+
+const maintenanceMsg = "服务维护中"
+
+// maintainer 支持维护模式的服务器
+type maintainer interface {
+	enterMaintenance(resp RejectResponse)
+	exitMaintenance()
+}
+
+// EnterMaintenance 所有服务器进入维护模式，除了 ExemptPaths 之外的请求都返回 resp，
+// 直到调用 ExitMaintenance。和优雅退出互相独立：不会等待已有请求、也不会关闭服务器
+func (a *App) EnterMaintenance(resp RejectResponse) {
+	for _, s := range a.servers {
+		if m, ok := s.(maintainer); ok {
+			m.enterMaintenance(resp)
+		}
+	}
+	a.logf("进入维护模式")
+}
+
+// ExitMaintenance 退出维护模式，恢复正常处理请求
+func (a *App) ExitMaintenance() {
+	for _, s := range a.servers {
+		if m, ok := s.(maintainer); ok {
+			m.exitMaintenance()
+		}
+	}
+	a.logf("退出维护模式")
+}
+
+func (s *Server) enterMaintenance(resp RejectResponse) {
+	s.mux.maintenance.Store(&resp)
+}
+
+func (s *Server) exitMaintenance() {
+	s.mux.maintenance.Store(nil)
+}
+
+// exempt 请求是否不受维护模式影响
+func (resp *RejectResponse) exempt(r *http.Request) bool {
+	for _, p := range resp.ExemptPaths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (resp *RejectResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code, contentType, body := resp.StatusCode, resp.ContentType, resp.Body
+	if code == 0 {
+		code = http.StatusServiceUnavailable
+	}
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	if body == "" {
+		body = maintenanceMsg
+	}
+	w.Header().Set("Content-Type", contentType)
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(resp.RetryAfter.Seconds())))
+	}
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(body))
+}
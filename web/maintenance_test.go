@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApp_Maintenance(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app := NewApp([]*Server{s})
+	app.EnterMaintenance(RejectResponse{RetryAfter: time.Minute, ExemptPaths: []string{"/healthz"}})
+
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("维护模式期望 503 和 Retry-After，实际 %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("豁免的路径应该正常处理，实际 %d", rec.Code)
+	}
+	if app.IsDraining() || s.RejectedCount() != 0 {
+		t.Fatal("维护模式不应该影响优雅退出的状态")
+	}
+
+	app.ExitMaintenance()
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("退出维护模式之后应该正常处理，实际 %d", rec.Code)
+	}
+}
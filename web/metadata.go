@@ -0,0 +1,61 @@
+package web
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WithMetadata 给应用添加元数据，例如租户、地域、实例，会带在所有生命周期日志的前缀中，
+// 也会作为 App 管理的每个 *Server 的默认元数据。可以多次调用，相同的 key 后设置的生效
+func WithMetadata(md map[string]string) Option {
+	return func(app *App) {
+		if app.metadata == nil {
+			app.metadata = make(map[string]string, len(md))
+		}
+		maps.Copy(app.metadata, md)
+	}
+}
+
+// WithServerMetadata 给服务器添加元数据，和应用的元数据有相同的 key 时以服务器的为准。
+// 元数据会被 promweb 这类集成用作指标的标签，只应该放取值很少的内容，不要放用户 ID 这类值
+func WithServerMetadata(md map[string]string) ServerOption {
+	return func(s *Server) {
+		if s.metadata == nil {
+			s.metadata = make(map[string]string, len(md))
+		}
+		maps.Copy(s.metadata, md)
+	}
+}
+
+// Metadata 返回应用的元数据
+func (a *App) Metadata() map[string]string {
+	return maps.Clone(a.metadata)
+}
+
+// Metadata 返回服务器的元数据，交给 App 管理之后包括应用的元数据
+func (s *Server) Metadata() map[string]string {
+	return maps.Clone(s.metadata)
+}
+
+// inheritMetadata 把应用的元数据作为服务器的默认元数据
+func (s *Server) inheritMetadata(md map[string]string) {
+	for k, v := range md {
+		if _, ok := s.metadata[k]; ok {
+			continue
+		}
+		if s.metadata == nil {
+			s.metadata = make(map[string]string, len(md))
+		}
+		s.metadata[k] = v
+	}
+}
+
+// formatMetadata 按 key 排序输出为 "k1=v1 k2=v2"
+func formatMetadata(md map[string]string) string {
+	parts := make([]string, 0, len(md))
+	for _, k := range slices.Sorted(maps.Keys(md)) {
+		parts = append(parts, k+"="+md[k])
+	}
+	return strings.Join(parts, " ")
+}
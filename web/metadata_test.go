@@ -0,0 +1,25 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWithMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	api := NewServer("api", "localhost:0", WithServerMetadata(map[string]string{"tenant": "b"}))
+	admin := NewServer("admin", "localhost:0")
+	app := NewApp([]*Server{api, admin}, WithAppName("order"), WithLogOutput(&buf),
+		WithMetadata(map[string]string{"tenant": "a", "region": "cn"}))
+	app.logf("应用关闭")
+	if got := buf.String(); !strings.Contains(got, "[order region=cn tenant=a] 应用关闭") {
+		t.Fatalf("日志前缀应该带上元数据: %q", got)
+	}
+	if md := api.Metadata(); md["tenant"] != "b" || md["region"] != "cn" {
+		t.Fatalf("服务器的元数据优先，并继承应用的元数据: %v", md)
+	}
+	if md := admin.Metadata(); md["tenant"] != "a" || md["region"] != "cn" {
+		t.Fatalf("服务器应该继承应用的元数据: %v", md)
+	}
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware 中间件，包装一个 http.Handler
+type Middleware func(next http.Handler) http.Handler
+
+// Use 给整个服务器添加中间件，作用于所有路由，第一个中间件在最外层。
+// 后调用 Use 添加的中间件在之前添加的中间件外面
+func (s *Server) Use(mws ...Middleware) *Server {
+	for i := len(mws) - 1; i >= 0; i-- {
+		s.mux.wrap(mws[i])
+	}
+	return s
+}
+
+// WithServerMiddleware 给 App 管理的每个 *Server 添加 fn 返回的中间件，
+// fn 可以根据服务器区分，例如用服务器名称作为指标的标签
+func WithServerMiddleware(fn func(s *Server) Middleware) Option {
+	return func(app *App) {
+		app.serverMiddlewares = append(app.serverMiddlewares, fn)
+	}
+}
+
+// TimeoutMiddleware 给请求的 context 加上超时时间。
+// 和 http.TimeoutHandler 不同，这里会真正取消 context，下游的 DB、HTTP 调用都能感知到
+func TimeoutMiddleware(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// MaxBodyBytes 限制请求体的大小，超过 n 字节时 handler 读取请求体会返回 *http.MaxBytesError。
+// Content-Length 已经超过限制的请求直接返回 413；
+// handler 因为读取超限的请求体而没有写响应时，也会返回 413
+func MaxBodyBytes(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, n)}
+			r.Body = body
+			lw := NewResponseWriter(w)
+			next.ServeHTTP(lw, r)
+			if body.exceeded && !lw.Written() {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
+
+// DefaultDeadlineHeader RequestDeadline 默认读取的请求头
+const DefaultDeadlineHeader = "X-Request-Deadline"
+
+// RequestDeadline 根据上游传过来的请求头设置请求 context 的截止时间，header 为空时使用 X-Request-Deadline。
+// 请求头的值可以是 unix 时间戳（秒，可以带小数），也可以是剩余时间，例如 "1.5s"。
+// 服务器自己的请求超时时间更短时以更短的为准，请求头格式错误时忽略
+func RequestDeadline(header string) Middleware {
+	if header == "" {
+		header = DefaultDeadlineHeader
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 不使用 App 的 Clock：这是独立的中间件拿不到 App，而且 context.WithDeadline 的计时器总是使用真实时间，
+			// 按假的当前时间换算出来的截止时间反而不对
+			deadline, ok := parseDeadline(r.Header.Get(header), time.Now())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// 父 context 的截止时间更早时 WithDeadline 会保留父 context 的
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// parseDeadline 解析 unix 时间戳或者剩余时间，剩余时间从 now 开始计算
+func parseDeadline(v string, now time.Time) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(d), true
+	}
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(sec*float64(time.Second))), true
+}
+
+// concurrencyRetryAfter 并发数达到上限时建议客户端重试的间隔，单位秒
+const concurrencyRetryAfter = "1"
+
+// statusClientClosedRequest 客户端已经断开时记录的状态码，和 nginx 的 499 一致
+const statusClientClosedRequest = 499
+
+// Concurrency 限制 handler 同时处理的请求数，超过 n 个时直接返回 503 和 Retry-After，
+// 不会排队等待。和限制连接数不同，可以通过 Group.Use 只保护个别开销大的接口。
+// 客户端已经断开的请求不执行 handler，返回 499。n 必须大于 0，否则 panic
+func Concurrency(n int) Middleware {
+	if n <= 0 {
+		panic("web: Concurrency 的 n 必须大于 0，实际 " + strconv.Itoa(n))
+	}
+	sem := make(chan struct{}, n)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 客户端已经断开的请求不需要再占用名额，写一个状态码让访问日志和指标能区分出来
+			if r.Context().Err() != nil {
+				w.WriteHeader(statusClientClosedRequest)
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+			default:
+				w.Header().Set("Retry-After", concurrencyRetryAfter)
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			// handler panic 时也要释放名额
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
@@ -0,0 +1,458 @@
+package web
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	var deadlineSet bool
+	var ctxErr error
+	h := TimeoutMiddleware(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadlineSet = r.Context().Deadline()
+		select {
+		case <-r.Context().Done():
+			ctxErr = r.Context().Err()
+		case <-time.After(time.Second):
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !deadlineSet {
+		t.Fatal("请求 context 没有设置 deadline")
+	}
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Fatalf("期望 context.DeadlineExceeded，实际 %v", ctxErr)
+	}
+}
+
+func TestWithRequestTimeout(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithRequestTimeout(time.Second))
+	var deadline time.Time
+	s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}))
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if deadline.IsZero() || time.Until(deadline) > time.Second {
+		t.Fatalf("deadline 设置错误: %v", deadline)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		wantEncoding   string
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", contentType: "application/json", wantEncoding: "gzip"},
+		{name: "deflate", acceptEncoding: "deflate", contentType: "application/json", wantEncoding: "deflate"},
+		{name: "not accepted", acceptEncoding: "", contentType: "application/json"},
+		{name: "q=0", acceptEncoding: "gzip;q=0", contentType: "application/json"},
+		{name: "q=0.0", acceptEncoding: "gzip;q=0.0, deflate; q=0.000", contentType: "application/json"},
+		{name: "q=0 falls back", acceptEncoding: "gzip;q=0, deflate;q=0.5", contentType: "application/json", wantEncoding: "deflate"},
+		{name: "wildcard", acceptEncoding: "*", contentType: "application/json", wantEncoding: "gzip"},
+		{name: "wildcard without gzip", acceptEncoding: "gzip;q=0, *;q=0.1", contentType: "application/json", wantEncoding: "deflate"},
+		{name: "wildcard refused", acceptEncoding: "*;q=0", contentType: "application/json"},
+		{name: "already compressed", acceptEncoding: "gzip", contentType: "image/png"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Compress(gzip.BestSpeed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write([]byte(body))
+				w.(http.Flusher).Flush()
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Fatalf("期望 Content-Encoding %q，实际 %q", tc.wantEncoding, got)
+			}
+			if rec.Header().Get("Vary") != "Accept-Encoding" {
+				t.Fatal("没有设置 Vary")
+			}
+			var reader io.Reader = rec.Body
+			switch tc.wantEncoding {
+			case "gzip":
+				gr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = gr
+			case "deflate":
+				reader = flate.NewReader(rec.Body)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body {
+				t.Fatal("响应内容不一致")
+			}
+		})
+	}
+}
+
+func TestCompress_EarlyHints(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+	h := Compress(gzip.BestSpeed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("103 之后的响应应该是压缩过的 200，实际 %d %q", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(gr); string(got) != body {
+		t.Fatal("响应内容不一致")
+	}
+}
+
+func TestCompress_FlushBeforeWrite(t *testing.T) {
+	h := Compress(gzip.BestSpeed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("写之前 Flush 发出的响应头也应该带上 Content-Encoding，实际 %q", got)
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(gr); string(got) != "data: hello\n\n" {
+		t.Fatalf("响应内容不一致 %q", got)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	h := MaxBodyBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	testCases := []struct {
+		name     string
+		req      func() *http.Request
+		wantCode int
+	}{
+		{
+			name:     "within limit",
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("1234")) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "content length too large",
+			req:      func() *http.Request { return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345")) },
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "chunked body too large",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("12345")))
+				req.ContentLength = -1
+				return req
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tc.req())
+			if rec.Code != tc.wantCode {
+				t.Fatalf("期望 %d，实际 %d", tc.wantCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Concurrency(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("mode") {
+		case "panic":
+			panic("handler panic")
+		case "block":
+			close(started)
+			<-release
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?mode=block", nil))
+		close(done)
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("超过并发数期望 503 和 Retry-After，实际 %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(release)
+	<-done
+
+	func() {
+		defer func() { _ = recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?mode=panic", nil))
+	}()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "ok" {
+		t.Fatalf("handler panic 之后应该释放名额，实际 %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rec.Body.Len() != 0 || rec.Code != statusClientClosedRequest {
+		t.Fatalf("客户端已经断开的请求不应该再执行 handler，应该返回 499，实际 %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestConcurrency_InvalidLimit(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("n=%d 时应该 panic", n)
+				}
+			}()
+			Concurrency(n)
+		}()
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := SecurityHeaders(SecurityHeaderOptions{
+		FrameOptions:          "SAMEORIGIN",
+		ContentSecurityPolicy: "-",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	want := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Content-Security-Policy":   "",
+		"Strict-Transport-Security": "",
+	}
+	for name, v := range want {
+		if got := rec.Header().Get(name); got != v {
+			t.Fatalf("%s 期望 %q，实际 %q", name, v, got)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	if rec.Header().Get("Strict-Transport-Security") == "" {
+		t.Fatal("TLS 连接应该输出 HSTS")
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name    string
+		header  string
+		timeout time.Duration
+		want    time.Time
+	}{
+		{name: "duration", header: "2s", want: now.Add(2 * time.Second)},
+		{name: "unix timestamp", header: strconv.FormatInt(now.Add(time.Minute).Unix(), 10), want: now.Add(time.Minute).Truncate(time.Second)},
+		{name: "server timeout tighter", header: "1m", timeout: time.Second, want: now.Add(time.Second)},
+		{name: "invalid", header: "soon"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				got time.Time
+				ok  bool
+			)
+			var h http.Handler = RequestDeadline("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok = r.Context().Deadline()
+			}))
+			if tc.timeout > 0 {
+				h = TimeoutMiddleware(tc.timeout)(h)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(DefaultDeadlineHeader, tc.header)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if tc.want.IsZero() {
+				if ok {
+					t.Fatalf("请求头无效时不应该设置截止时间，实际 %v", got)
+				}
+				return
+			}
+			if diff := got.Sub(tc.want); !ok || diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+				t.Fatalf("期望截止时间 %v，实际 %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	if got, ok := parseDeadline("1.5s", now); !ok || !got.Equal(now.Add(1500*time.Millisecond)) {
+		t.Fatalf("剩余时间应该从传入的 now 开始计算，实际 %v %v", got, ok)
+	}
+	if got, ok := parseDeadline("1700000060.5", now); !ok || !got.Equal(now.Add(60500*time.Millisecond)) {
+		t.Fatalf("unix 时间戳解析错误，实际 %v %v", got, ok)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	if NewResponseWriter(w) != w {
+		t.Fatal("重复包装应该返回同一个 ResponseWriter")
+	}
+	if w.Written() || w.Status() != 0 {
+		t.Fatal("还没有写响应头")
+	}
+	_, _ = w.Write([]byte("hello"))
+	w.Flush()
+	if w.Status() != http.StatusOK || w.Size() != 5 || !rec.Flushed {
+		t.Fatalf("状态码 %d，大小 %d，flushed %v", w.Status(), w.Size(), rec.Flushed)
+	}
+	if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("不支持 Hijack 时期望 http.ErrNotSupported，实际 %v", err)
+	}
+	if err := w.Push("/style.css", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("不支持 Push 时期望 http.ErrNotSupported，实际 %v", err)
+	}
+}
+
+func TestServer_Use(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	var pattern string
+	s.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	s.Use(mw("a"), mw("b"))
+	NewApp([]*Server{s}, WithServerMiddleware(func(srv *Server) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pattern = srv.Pattern(r)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}))
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if got := strings.Join(order, ","); got != "a,b" {
+		t.Fatalf("中间件顺序错误 %s", got)
+	}
+	if pattern != "GET /users/{id}" {
+		t.Fatalf("期望匹配到的路由，实际 %q", pattern)
+	}
+}
+
+func TestETag(t *testing.T) {
+	h := ETag(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("a", 32)))
+		case "/stream":
+			_, _ = w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+		case "/error":
+			http.Error(w, "bad", http.StatusBadRequest)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	serve := func(method, path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" || etag == "" {
+		t.Fatalf("第一次请求应该返回响应体和 ETag，实际 %d %q %q", rec.Code, rec.Body.String(), etag)
+	}
+
+	testCases := []struct {
+		name        string
+		method      string
+		path        string
+		ifNoneMatch string
+		wantCode    int
+		wantBody    string
+		wantETag    bool
+	}{
+		{name: "match", method: http.MethodGet, path: "/", ifNoneMatch: etag, wantCode: http.StatusNotModified, wantETag: true},
+		{name: "weak match in list", method: http.MethodGet, path: "/", ifNoneMatch: `"other", W/` + etag, wantCode: http.StatusNotModified, wantETag: true},
+		{name: "no match", method: http.MethodGet, path: "/", ifNoneMatch: `"other"`, wantCode: http.StatusOK, wantBody: "hello", wantETag: true},
+		{name: "too large", method: http.MethodGet, path: "/large", ifNoneMatch: "*", wantCode: http.StatusOK, wantBody: strings.Repeat("a", 32)},
+		{name: "stream", method: http.MethodGet, path: "/stream", ifNoneMatch: "*", wantCode: http.StatusOK, wantBody: "a"},
+		{name: "error", method: http.MethodGet, path: "/error", ifNoneMatch: "*", wantCode: http.StatusBadRequest, wantBody: "bad\n"},
+		{name: "post", method: http.MethodPost, path: "/", ifNoneMatch: "*", wantCode: http.StatusOK, wantBody: "hello"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(tc.method, tc.path, tc.ifNoneMatch)
+			if rec.Code != tc.wantCode || rec.Body.String() != tc.wantBody {
+				t.Fatalf("期望 %d %q，实际 %d %q", tc.wantCode, tc.wantBody, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("ETag") != ""; got != tc.wantETag {
+				t.Fatalf("是否有 ETag 期望 %v，实际 %v", tc.wantETag, got)
+			}
+		})
+	}
+}
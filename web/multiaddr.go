@@ -0,0 +1,105 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// NewMultiAddrServer 创建同时监听多个地址的服务器，例如分别显式监听 "0.0.0.0:8080" 和 "[::1]:8080"，
+// 避免依赖不同平台上 ":8080" 是否双栈的行为。
+// 所有地址共用 h 和拒绝标记，优雅退出时一起摘流量、一起关闭。h 为 nil 时可以之后再通过 Handle 注册路由
+func NewMultiAddrServer(name string, addrs []string, h http.Handler, opts ...ServerOption) *Server {
+	var addr string
+	if len(addrs) > 0 {
+		addr = addrs[0]
+	}
+	s := NewServer(name, addr, opts...)
+	if len(addrs) > 1 {
+		s.extraAddrs = addrs[1:]
+	}
+	if h != nil {
+		s.Handle("/", h)
+	}
+	return s
+}
+
+// Addrs 服务器配置的所有监听地址，Unix socket 的地址为 "unix:" 加上路径。
+// 地址为空、只监听 Unix socket 或者注入的 listener 时不包括空的 TCP 地址
+func (s *Server) Addrs() []string {
+	var addrs []string
+	if s.srv.Addr != "" || !s.hasExtraListeners() {
+		addrs = append(addrs, s.srv.Addr)
+	}
+	addrs = append(addrs, s.extraAddrs...)
+	for _, u := range s.unixSockets {
+		addrs = append(addrs, "unix:"+u.path)
+	}
+	return addrs
+}
+
+// listenExtra 监听除了 srv.Addr 之外的地址，失败时关闭所有已经打开的 listener
+func (s *Server) listenExtra() error {
+	s.extraLis = s.extraLis[:0]
+	for _, addr := range s.extraAddrs {
+		lis, err := s.newListener(addr)
+		if err != nil {
+			s.closeAll()
+			return err
+		}
+		s.extraLis = append(s.extraLis, lis)
+	}
+	for _, u := range s.unixSockets {
+		lis, err := s.newUnixListener(u)
+		if err != nil {
+			s.closeAll()
+			return err
+		}
+		s.extraLis = append(s.extraLis, lis)
+	}
+	for _, lis := range s.injected {
+		s.extraLis = append(s.extraLis, s.wrapListener(lis))
+	}
+	return nil
+}
+
+// closeAll 关闭已经打开的所有 listener
+func (s *Server) closeAll() {
+	for _, l := range s.extraLis {
+		_ = l.Close()
+	}
+	if s.lis != nil {
+		_ = s.lis.Close()
+	}
+	s.extraLis = nil
+}
+
+// serveAll 在所有 listener 和 WithProtocolServer 上处理请求，任意一个返回时返回它的错误。
+// 关闭服务器时所有 listener 都会被关闭；其中一个异常退出时先关闭其他的 listener，
+// 等它们都返回之后再返回，重启时不会叠加在还开着的 listener 上
+func (s *Server) serveAll() error {
+	listeners := append([]net.Listener{s.lis}, s.extraLis...)
+	errs := make(chan error, len(listeners)+len(s.protocols))
+	for _, lis := range listeners {
+		go func(lis net.Listener) {
+			errs <- s.serveListener(lis)
+		}(lis)
+	}
+	for _, p := range s.protocols {
+		go func(p ProtocolServer) {
+			errs <- p.Serve(s.mux)
+		}(p)
+	}
+	err := <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	for _, lis := range listeners {
+		_ = lis.Close()
+	}
+	_ = s.closeProtocols()
+	for range len(listeners) + len(s.protocols) - 1 {
+		<-errs
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewMultiAddrServer(t *testing.T) {
+	addrs := []string{freeAddr(t), freeAddr(t)}
+	s := NewMultiAddrServer("dual", addrs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	if got := s.Addrs(); !slices.Equal(got, addrs) {
+		t.Fatalf("Addrs 应该返回所有地址 %q，实际 %q", addrs, got)
+	}
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != "ok" {
+			t.Fatalf("%s 期望 ok，实际 %q", addr, body)
+		}
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if resp, err := http.Get("http://" + addr); err == nil {
+			_ = resp.Body.Close()
+			t.Fatalf("%s 关闭之后不应该再处理请求", addr)
+		}
+	}
+}
+
+// failingListener Accept 直接返回错误，模拟异常退出的 listener
+type failingListener struct{}
+
+var errAcceptFailed = errors.New("accept failed")
+
+func (failingListener) Accept() (net.Conn, error) { return nil, errAcceptFailed }
+func (failingListener) Close() error              { return nil }
+func (failingListener) Addr() net.Addr            { return &net.UnixAddr{Name: "failing", Net: "unix"} }
+
+func TestNewMultiAddrServer_ListenerFailure(t *testing.T) {
+	addr := freeAddr(t)
+	s := NewMultiAddrServer("dual", []string{addr}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithListener(failingListener{}))
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.serve() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errAcceptFailed) {
+			t.Fatalf("应该返回异常退出的 listener 的错误，实际 %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("一个 listener 异常退出时 serve 应该返回")
+	}
+	// 返回之前其他地址也要关闭，重启时才能重新监听
+	if resp, err := http.Get("http://" + addr); err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("serve 返回之后其他 listener 不应该继续处理请求")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("应该可以重新监听 %s: %v", addr, err)
+	}
+	_ = lis.Close()
+}
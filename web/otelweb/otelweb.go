@@ -0,0 +1,110 @@
+// Package otelweb 为 web 包提供 OpenTelemetry 链路追踪支持，
+// 单独成包，不使用 OpenTelemetry 的用户不会引入相关依赖
+package otelweb
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Tuanzi-bug/component-base/web/otelweb"
+
+// NewTracer 使用 tp 创建优雅退出的 Tracer，
+// 整个优雅退出过程是一个 span，每个阶段、服务器、回调是它的子 span
+func NewTracer(tp trace.TracerProvider) web.Tracer {
+	return &tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// WithShutdownTracing 开启优雅退出的链路追踪
+func WithShutdownTracing(tp trace.TracerProvider) web.Option {
+	return web.WithTracer(NewTracer(tp))
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Option 配置请求的链路追踪
+type Option func(*config)
+
+type config struct {
+	propagator propagation.TextMapPropagator
+}
+
+// WithPropagator 设置从请求头中读取上游 trace 的方式，默认为 W3C traceparent
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithTracerProvider 同时开启请求和优雅退出的链路追踪：App 管理的每个 *web.Server 处理请求时
+// 创建一个 server span，并且继承请求头中的上游 trace；优雅退出的每个阶段、服务器、回调是一个 span
+func WithTracerProvider(tp trace.TracerProvider, opts ...Option) web.Option {
+	shutdown := WithShutdownTracing(tp)
+	requests := web.WithServerMiddleware(func(s *web.Server) web.Middleware {
+		return Middleware(tp, s, opts...)
+	})
+	return func(app *web.App) {
+		shutdown(app)
+		requests(app)
+	}
+}
+
+// Middleware 给 s 处理的每个请求创建一个 server span，名称为 "方法 路由"，例如 "GET /users/{id}"
+func Middleware(tp trace.TracerProvider, s *web.Server, opts ...Option) web.Middleware {
+	c := config{propagator: propagation.TraceContext{}}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	tr := tp.Tracer(instrumentationName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := c.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			// 带方法注册的路由形如 "GET /users/{id}"，http.route 只保留路径部分
+			route := s.Pattern(r)
+			if _, path, ok := strings.Cut(route, " "); ok {
+				route = path
+			}
+			name := r.Method
+			if route != "" {
+				name += " " + route
+			}
+			ctx, span := tr.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+				attribute.String("server.name", s.Name()),
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("http.route", route),
+			))
+			defer span.End()
+			rw := web.NewResponseWriter(w)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
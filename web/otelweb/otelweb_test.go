@@ -0,0 +1,90 @@
+package otelweb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tr := NewTracer(tp)
+
+	ctx, end := tr.Start(context.Background(), "shutdown")
+	_, endChild := tr.Start(ctx, "shutdown.stop_server.api")
+	endChild(errors.New("stop failed"))
+	end(nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("期望 2 个 span，实际 %d", len(spans))
+	}
+	child, root := spans[0], spans[1]
+	if child.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Fatal("子 span 的 parent 不正确")
+	}
+	if child.Status.Code != codes.Error || len(child.Events) == 0 {
+		t.Fatal("没有记录错误")
+	}
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s := web.NewServer("api", "localhost:0")
+	s.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanFromContext(r.Context()).SpanContext().IsValid() {
+			t.Error("handler 的 context 中应该有 span")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	app := web.NewApp([]*web.Server{s}, WithTracerProvider(tp), web.WithWaitTime(0))
+	app.RegisterShutdownFunc("close-db", web.PhaseResources, func(ctx context.Context) error { return nil })
+
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://"+s.ListenerAddr().String()+"/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("期望 1 个请求的 span，实际 %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /users/{id}" || span.SpanKind != trace.SpanKindServer {
+		t.Fatalf("span 名称或者类型不对 %s %v", span.Name, span.SpanKind)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatal("应该继承请求头中的 traceparent")
+	}
+	if span.Status.Code != codes.Error {
+		t.Fatal("5xx 响应应该标记为错误")
+	}
+
+	exporter.Reset()
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, sp := range exporter.GetSpans() {
+		names[sp.Name] = true
+	}
+	for _, want := range []string{"shutdown", "shutdown.drain", "shutdown.callback.close-db"} {
+		if !names[want] {
+			t.Fatalf("缺少优雅退出的 span %s，实际 %v", want, names)
+		}
+	}
+}
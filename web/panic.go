@@ -0,0 +1,77 @@
+package web
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicHook handler panic 之后执行的钩子，例如上报到 Sentry。
+// ctx 是请求的 context，recovered 是 recover 的返回值，stack 是 panic 时的调用栈
+type PanicHook func(ctx context.Context, recovered any, stack []byte)
+
+// OnPanic 注册 handler panic 之后执行的钩子，按照注册顺序依次在处理请求的 goroutine 里执行，
+// 钩子本身 panic 不会影响后面的钩子。需要在 Start 之前调用
+func (a *App) OnPanic(fn PanicHook) {
+	a.panicHooks = append(a.panicHooks, fn)
+}
+
+// WithPanicShutdown 所有服务器加起来 panic 达到 n 次之后开始优雅退出，
+// 避免进程带着被破坏的状态继续处理请求。n <= 0 时不会因为 panic 退出
+func WithPanicShutdown(n int) Option {
+	return func(app *App) {
+		app.panicShutdown = int64(n)
+	}
+}
+
+// PanicCount 返回 handler panic 的次数
+func (s *Server) PanicCount() int64 {
+	return s.mux.panics.Load()
+}
+
+// recoverPanic 在 ServeHTTP 里 defer 调用，恢复 handler 的 panic 并返回 500。
+// 已经写了响应头时没法再改状态码，改为 panic(http.ErrAbortHandler) 让 net/http 直接断开连接，
+// 客户端不会把写了一半的响应当成完整的响应
+func (s *serverMux) recoverPanic(w *ResponseWriter, r *http.Request) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+	if rec == http.ErrAbortHandler {
+		panic(rec)
+	}
+	stack := debug.Stack()
+	s.panics.Add(1)
+	if s.onPanic != nil {
+		s.onPanic(r, rec, stack)
+	} else {
+		log.Printf("web: 处理请求 %s %s 时 panic: %v\n%s", r.Method, r.URL.Path, rec, stack)
+	}
+	if w.Written() {
+		panic(http.ErrAbortHandler)
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// handlePanic 记录 srv 的 handler panic，执行 OnPanic 注册的钩子，达到 WithPanicShutdown 的次数时开始优雅退出
+func (a *App) handlePanic(srv *Server, r *http.Request, rec any, stack []byte) {
+	a.logEvent("handler_panic", []any{"server", srv.Name(), "method", r.Method, "path", r.URL.Path, "panic", rec},
+		"服务器%s处理请求 %s %s 时 panic: %v\n%s", srv.Name(), r.Method, r.URL.Path, rec, stack)
+	for i, fn := range a.panicHooks {
+		a.runPanicHook(i, fn, r.Context(), rec, stack)
+	}
+	if n := a.panics.Add(1); a.panicShutdown > 0 && n == a.panicShutdown {
+		a.logf("handler 已经 panic %d 次，开始优雅退出", n)
+		a.triggerShutdown()
+	}
+}
+
+func (a *App) runPanicHook(i int, fn PanicHook, ctx context.Context, rec any, stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.logf("panic 钩子%d panic: %v", i, r)
+		}
+	}()
+	fn(ctx, rec, stack)
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestServer_RecoverPanic(t *testing.T) {
+	srv := NewServer("api", ":0")
+	srv.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	})
+	srv.HandleFunc("/partial", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("half"))
+		panic("after write")
+	})
+	app := NewApp([]*Server{srv}, WithLogOutput(io.Discard), WithPanicShutdown(2))
+	var mu sync.Mutex
+	var got []any
+	app.OnPanic(func(ctx context.Context, recovered any, stack []byte) {
+		if len(stack) == 0 {
+			t.Error("钩子应该拿到调用栈")
+		}
+		mu.Lock()
+		got = append(got, recovered)
+		mu.Unlock()
+	})
+	app.OnPanic(func(ctx context.Context, recovered any, stack []byte) {
+		panic("hook panic")
+	})
+
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("handler panic 应该返回 500，实际 %d", rec.Code)
+	}
+	if n := srv.InFlight(); n != 0 {
+		t.Fatalf("handler panic 之后应该释放正在处理的请求数，实际 %d", n)
+	}
+	select {
+	case <-app.triggered:
+		t.Fatal("没有达到 WithPanicShutdown 的次数不应该退出")
+	default:
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("已经写了响应再 panic 应该断开连接，实际 %v", r)
+			}
+		}()
+		srv.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/partial", nil))
+	}()
+	if srv.PanicCount() != 2 || len(got) != 2 || got[0] != "nil map" {
+		t.Fatalf("期望记录 2 次 panic，实际 %d %v", srv.PanicCount(), got)
+	}
+	select {
+	case <-app.triggered:
+	default:
+		t.Fatal("达到 WithPanicShutdown 的次数应该开始优雅退出")
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Phase 回调所在的阶段，数值小的阶段先执行，同一个阶段的回调并发执行
+type Phase int
+
+const (
+	// PhaseDefault WithShutdownCallbacks 注册的回调所在的阶段，最先执行
+	PhaseDefault Phase = 0
+	// PhaseFlush 发送缓冲的数据，例如指标、日志
+	PhaseFlush Phase = 100
+	// PhaseConsumers 关闭消息队列的消费者
+	PhaseConsumers Phase = 200
+	// PhaseResources 关闭数据库连接池这类底层资源
+	PhaseResources Phase = 300
+)
+
+// callbackSpan 执行单个回调的步骤名称前缀，后面是回调的名称，没有名称时是下标
+const callbackSpan = "shutdown.callback."
+
+// ErrCallbackTimeout 回调超过了 WithCallbackTimeout 设置的超时时间，已经不再等待它返回
+var ErrCallbackTimeout = errors.New("web: 回调超时")
+
+// ShutdownFunc 可以返回错误的优雅退出回调
+type ShutdownFunc func(ctx context.Context) error
+
+// callback 带名称和阶段的回调
+type callback struct {
+	name  string
+	phase Phase
+	fn    ShutdownFunc
+}
+
+// ignoreErr 把 ShutdownCallback 转换为 ShutdownFunc，cb 为 nil 时返回 nil
+func ignoreErr(cb ShutdownCallback) ShutdownFunc {
+	if cb == nil {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		cb(ctx)
+		return nil
+	}
+}
+
+// RegisterCallback 注册名为 name 的回调，按照阶段依次执行：前一个阶段的回调都返回（或者超时）之后
+// 才开始下一个阶段，同一个阶段的回调并发执行，每个回调的超时时间和 WithShutdownCallbacks 一致。
+// 例如先在 PhaseFlush 发送指标，再在 PhaseConsumers 关闭消费者，最后在 PhaseResources 关闭数据库。
+// 每个回调的耗时、是否超时、panic 记录在 ShutdownResult.CallbackResults 中
+func (a *App) RegisterCallback(name string, phase Phase, cb ShutdownCallback) {
+	a.phased = append(a.phased, callback{name: name, phase: phase, fn: ignoreErr(cb)})
+}
+
+// RegisterShutdownFunc 和 RegisterCallback 一样，fn 返回的错误会记录在 CallbackResult.Err 中，
+// 并且和超时、panic 的回调一起通过 errors.Join 汇总到 Shutdown 和 Run 的返回值里。
+// 一个回调失败不影响其他回调和后面的阶段
+func (a *App) RegisterShutdownFunc(name string, phase Phase, fn ShutdownFunc) {
+	a.phased = append(a.phased, callback{name: name, phase: phase, fn: fn})
+}
+
+// callbacks 返回所有回调，WithShutdownCallbacks 注册的在前面
+func (a *App) callbacks() []callback {
+	res := make([]callback, 0, len(a.cbs)+len(a.phased))
+	for _, cb := range a.cbs {
+		res = append(res, callback{phase: PhaseDefault, fn: ignoreErr(cb)})
+	}
+	return append(res, a.phased...)
+}
+
+// callbackPhases 按照阶段分组，返回每个阶段的回调在 cbs 中的下标
+func callbackPhases(cbs []callback) [][]int {
+	var phases []Phase
+	groups := make(map[Phase][]int)
+	for i, cb := range cbs {
+		if _, ok := groups[cb.phase]; !ok {
+			phases = append(phases, cb.phase)
+		}
+		groups[cb.phase] = append(groups[cb.phase], i)
+	}
+	slices.Sort(phases)
+	res := make([][]int, 0, len(phases))
+	for _, p := range phases {
+		res = append(res, groups[p])
+	}
+	return res
+}
+
+// runCallback 执行一个回调，超时之后不再等待它返回，panic 记录为错误
+func (a *App) runCallback(ctx context.Context, idx int, cb callback) (res CallbackResult) {
+	// 每个回调一个步骤，名称带上回调的名称，慢的回调在链路中可以直接看出来
+	cbCtx, endCb := a.tracer.Start(ctx, callbackSpan+cb.label(idx))
+	defer func() {
+		if res.Err == nil && res.TimedOut {
+			endCb(ErrCallbackTimeout)
+			return
+		}
+		endCb(res.Err)
+	}()
+	// 控制回调超时
+	cbCtx, cancel := a.withTimeout(cbCtx, a.cbTimeout)
+	defer cancel()
+	start := a.clock.Now()
+	res = CallbackResult{Index: idx, Name: cb.name, Phase: cb.phase}
+	// 回调超时之后不再等待它返回，避免一个卡住的回调拖住整个优雅退出
+	finished := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				finished <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		finished <- cb.fn(cbCtx)
+	}()
+	select {
+	case res.Err = <-finished:
+	case <-cbCtx.Done():
+		select {
+		case res.Err = <-finished:
+		default:
+			a.logf("回调%s执行超时，不再等待", cb.label(idx))
+		}
+	}
+	if res.Err != nil {
+		res.Err = fmt.Errorf("web: 回调%s失败: %w", cb.label(idx), res.Err)
+		a.logf("%v", res.Err)
+	}
+	res.Duration = a.since(start)
+	res.TimedOut = errors.Is(cbCtx.Err(), context.DeadlineExceeded)
+	return res
+}
+
+// label 日志中使用的回调名称，没有名称时使用下标
+func (cb callback) label(idx int) string {
+	if cb.name != "" {
+		return cb.name
+	}
+	return strconv.Itoa(idx)
+}
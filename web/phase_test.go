@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApp_RegisterCallback(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) ShutdownCallback {
+		return func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+	app := NewApp(nil, WithWaitTime(0), WithShutdownCallbacks(record("legacy")))
+	app.RegisterCallback("db", PhaseResources, record("db"))
+	app.RegisterCallback("consumer", PhaseConsumers, record("consumer"))
+	app.RegisterCallback("metrics", PhaseFlush, record("metrics"))
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"legacy", "metrics", "consumer", "db"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("回调应该按阶段执行，期望 %v，实际 %v", want, order)
+	}
+	res := app.Result().CallbackResults
+	if len(res) != 4 || res[1].Name != "db" || res[1].Phase != PhaseResources {
+		t.Fatalf("回调结果不正确 %+v", res)
+	}
+}
+
+func TestApp_RegisterCallback_PanicAndTimeout(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0), WithCallbackTimeout(50*time.Millisecond))
+	app.RegisterCallback("bad", PhaseFlush, func(ctx context.Context) {
+		panic("boom")
+	})
+	app.RegisterCallback("slow", PhaseFlush, func(ctx context.Context) {
+		time.Sleep(time.Second)
+	})
+	var ran bool
+	app.RegisterCallback("next", PhaseResources, func(ctx context.Context) {
+		ran = true
+	})
+	_ = app.Shutdown(context.Background())
+	if !ran {
+		t.Fatal("前一个阶段出错之后应该继续执行下一个阶段")
+	}
+	res := app.Result()
+	if res.CallbackResults[0].Err == nil {
+		t.Fatal("panic 应该记录为错误")
+	}
+	if !res.CallbackResults[1].TimedOut {
+		t.Fatal("应该记录超时")
+	}
+	if res.Clean() {
+		t.Fatalf("不应该是正常退出 %s", res)
+	}
+}
+
+func TestApp_RegisterShutdownFunc(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	errFlush := errors.New("发送指标失败")
+	app.RegisterShutdownFunc("metrics", PhaseFlush, func(ctx context.Context) error {
+		return errFlush
+	})
+	var closed bool
+	app.RegisterShutdownFunc("db", PhaseResources, func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+	err := app.Shutdown(context.Background())
+	if !errors.Is(err, errFlush) {
+		t.Fatalf("Shutdown 应该返回回调的错误，实际 %v", err)
+	}
+	if !closed {
+		t.Fatal("一个回调失败不应该影响后面的阶段")
+	}
+	if res := app.Result().CallbackResults; !errors.Is(res[0].Err, errFlush) || res[1].Err != nil {
+		t.Fatalf("回调结果错误 %+v", res)
+	}
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"sync"
+)
+
+// runTasks 用最多 limit 个 goroutine 执行 tasks，等待全部执行完毕后返回。
+// limit <= 0 表示每个任务一个 goroutine
+func runTasks(limit int, tasks []func()) {
+	if len(tasks) == 0 {
+		return
+	}
+	if limit <= 0 || limit > len(tasks) {
+		limit = len(tasks)
+	}
+	ch := make(chan func())
+	var wg sync.WaitGroup
+	wg.Add(limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			defer wg.Done()
+			for task := range ch {
+				task()
+			}
+		}()
+	}
+	for _, task := range tasks {
+		ch <- task
+	}
+	close(ch)
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package web
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunTasks(t *testing.T) {
+	var running, maxRunning, finished atomic.Int32
+	tasks := make([]func(), 10)
+	for i := range tasks {
+		tasks[i] = func() {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			finished.Add(1)
+		}
+	}
+	runTasks(3, tasks)
+	if finished.Load() != 10 {
+		t.Fatalf("期望执行 10 个任务，实际 %d", finished.Load())
+	}
+	if maxRunning.Load() > 3 {
+		t.Fatalf("并发数超过限制: %d", maxRunning.Load())
+	}
+}
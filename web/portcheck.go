@@ -0,0 +1,63 @@
+package web
+
+import (
+	"net"
+	"strings"
+)
+
+// addrLister 可以列出监听地址的服务器
+type addrLister interface {
+	listenAddrs() []string
+}
+
+// WithPortReleaseCheck 关闭应用时，在所有服务器关闭之后尝试重新监听每个服务器的地址，
+// 仍然被占用时输出警告。用于排查 listener 泄露导致重启时 "address already in use" 的问题
+func WithPortReleaseCheck() Option {
+	return func(app *App) {
+		app.portReleaseCheck = true
+	}
+}
+
+// listenAddrs 实际监听的地址，监听端口 0 时可以拿到系统分配的端口
+func (s *Server) listenAddrs() []string {
+	var addrs []string
+	if s.lis == nil {
+		for _, addr := range s.Addrs() {
+			if !strings.HasPrefix(addr, "unix:") {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs
+	}
+	for _, l := range append([]net.Listener{s.lis}, s.extraLis...) {
+		// Unix socket 和注入的 listener 不检查
+		if l.Addr().Network() == "tcp" {
+			addrs = append(addrs, l.Addr().String())
+		}
+	}
+	return addrs
+}
+
+// checkPortsReleased 检查服务器的地址是否已经释放
+func (a *App) checkPortsReleased() {
+	if !a.portReleaseCheck {
+		return
+	}
+	for _, s := range a.servers {
+		al, ok := s.(addrLister)
+		if !ok {
+			continue
+		}
+		for _, addr := range al.listenAddrs() {
+			if addr == "" || strings.HasSuffix(addr, ":0") {
+				continue
+			}
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				a.logf("服务器%s的地址%s没有释放: %v", s.Name(), addr, err)
+				continue
+			}
+			_ = lis.Close()
+		}
+	}
+}
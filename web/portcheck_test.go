@@ -0,0 +1,35 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWithPortReleaseCheck(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var buf bytes.Buffer
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithPortReleaseCheck(), WithLogOutput(&buf))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = app.Shutdown(context.Background())
+	if strings.Contains(buf.String(), "没有释放") {
+		t.Fatalf("正常关闭之后端口应该已经释放: %s", buf.String())
+	}
+
+	// 模拟泄露的 listener
+	leaked, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaked.Close()
+	buf.Reset()
+	app = NewApp(nil, WithServers(NewServer("leak", leaked.Addr().String())), WithPortReleaseCheck(), WithLogOutput(&buf))
+	app.checkPortsReleased()
+	if !strings.Contains(buf.String(), "服务器leak的地址"+leaked.Addr().String()+"没有释放") {
+		t.Fatalf("应该警告端口没有释放: %s", buf.String())
+	}
+}
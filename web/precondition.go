@@ -0,0 +1,31 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// precondition 启动前必须满足的条件
+type precondition struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// AddPrecondition 注册启动前必须满足的条件，例如数据库可以连接、必需的环境变量已经设置。
+// Start 在执行 WithBeforeStart 的钩子和监听端口之前检查所有条件，
+// 任意一个不满足都会拒绝启动，返回的错误包含所有不满足的条件。需要在 Start 之前调用
+func (a *App) AddPrecondition(name string, check func(ctx context.Context) error) {
+	a.preconditions = append(a.preconditions, precondition{name: name, check: check})
+}
+
+// checkPreconditions 检查所有启动条件，汇总所有失败
+func (a *App) checkPreconditions() error {
+	var errs []error
+	for _, p := range a.preconditions {
+		if err := p.check(a.ctx); err != nil {
+			errs = append(errs, fmt.Errorf("web: 启动条件%s不满足: %w", p.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApp_AddPrecondition(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var hookCalled bool
+	app := NewApp([]*Server{s}, WithBeforeStart(func(ctx context.Context) error {
+		hookCalled = true
+		return nil
+	}))
+	errDB, errEnv := errors.New("db unreachable"), errors.New("DB_DSN not set")
+	app.AddPrecondition("db", func(ctx context.Context) error { return errDB })
+	app.AddPrecondition("ok", func(ctx context.Context) error { return nil })
+	app.AddPrecondition("env", func(ctx context.Context) error { return errEnv })
+
+	err := app.Start()
+	if !errors.Is(err, errDB) || !errors.Is(err, errEnv) {
+		t.Fatalf("应该汇总所有不满足的条件，实际 %v", err)
+	}
+	if hookCalled {
+		t.Fatal("条件不满足时不应该执行启动前钩子")
+	}
+	if s.lis != nil {
+		t.Fatal("条件不满足时不应该监听端口")
+	}
+}
@@ -0,0 +1,29 @@
+package web
+
+import "net/http"
+
+// preDrainer 支持在开始拒绝新请求之前的阶段对请求做额外处理的服务器
+type preDrainer interface {
+	startPreDrain()
+}
+
+// WithPreDrainMiddleware 开始优雅退出之后、拒绝新请求之前（随机等待、准备关闭、服务注销期间），
+// 新请求会额外经过 m，例如给响应加上 "X-Draining-Soon: true" 或者记录日志。
+// 开始拒绝新请求或者 ResumeTraffic 之后不再生效
+func WithPreDrainMiddleware(m Middleware) ServerOption {
+	return func(s *Server) {
+		s.mux.preDrainMiddleware = m
+	}
+}
+
+func (s *Server) startPreDrain() {
+	s.mux.preDrain.Store(true)
+}
+
+// preDrainHandler 返回在拒绝新请求之前的阶段处理请求的 handler
+func (s *serverMux) preDrainHandler(h http.Handler) http.Handler {
+	if s.preDrainMiddleware == nil || !s.preDrain.Load() {
+		return h
+	}
+	return s.preDrainMiddleware(h)
+}
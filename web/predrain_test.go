@@ -0,0 +1,41 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithPreDrainMiddleware(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithPreDrainMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Draining-Soon", "true")
+			next.ServeHTTP(w, r)
+		})
+	}))
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec
+	}
+
+	if got := serve().Header().Get("X-Draining-Soon"); got != "" {
+		t.Fatalf("开始优雅退出之前不应该经过中间件，实际 %q", got)
+	}
+	var rec *httptest.ResponseRecorder
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithPrepareShutdown(func(ctx context.Context) error {
+		rec = serve()
+		return nil
+	}))
+	app.shutdown(context.Background())
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Draining-Soon") != "true" {
+		t.Fatalf("拒绝新请求之前应该正常处理并经过中间件，实际 %d %v", rec.Code, rec.Header())
+	}
+
+	s.acceptReq()
+	if got := serve().Header().Get("X-Draining-Soon"); got != "" {
+		t.Fatalf("恢复接收请求之后不应该经过中间件，实际 %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// HealthzPath 存活探针的路径，进程还在处理请求就返回 200
+	HealthzPath = "/healthz"
+	// ReadyzPath 就绪探针的路径，开始优雅退出之后返回 503
+	ReadyzPath = "/readyz"
+
+	// probeCheckTimeout 就绪探针执行健康检查的超时时间
+	probeCheckTimeout = 2 * time.Second
+)
+
+// HealthChecker 健康检查，例如 ping 数据库、缓存，返回 nil 表示正常
+type HealthChecker func(ctx context.Context) error
+
+// WithHealthEndpoints 给每个 *Server 注册 HealthzPath 和 ReadyzPath 两个探针。
+// /healthz 只要进程还在处理请求就返回 200，优雅退出期间也一样，避免编排系统在摘流量时杀掉进程；
+// /readyz 在开始优雅退出（拒绝新请求）、维护模式或者 AddHealthCheck 注册的检查失败时返回 503，
+// 让 Kubernetes 在断开连接之前就不再把流量转发过来。
+// 探针不经过中间件，也不计入正在处理的请求数
+func WithHealthEndpoints() Option {
+	return func(app *App) {
+		app.healthEndpoints = true
+	}
+}
+
+// installProbes 给所有 *Server 注册探针
+func (a *App) installProbes() {
+	if !a.healthEndpoints {
+		return
+	}
+	for _, s := range a.servers {
+		srv, ok := s.(*Server)
+		// NewServerSharingHandler 共用的路由只注册一次
+		if !ok || srv.mux.probes != nil {
+			continue
+		}
+		srv.mux.probes = map[string]http.Handler{
+			HealthzPath: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeProbe(w, http.StatusOK, probeResult{Status: "ok"})
+			}),
+			ReadyzPath: a.readyzHandler(srv),
+		}
+	}
+}
+
+// probeResult 探针返回的 JSON
+type probeResult struct {
+	Status string `json:"status"`
+	// Reason 不可用的原因
+	Reason string `json:"reason,omitempty"`
+	// Checks 每个健康检查的结果，正常为 "ok"，否则是错误信息
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (a *App) readyzHandler(srv *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := probeResult{Status: "ok"}
+		switch {
+		case srv.mux.reject.Load() || a.IsDraining():
+			res.Status, res.Reason = "unavailable", "draining"
+		case srv.mux.maintenance.Load() != nil:
+			res.Status, res.Reason = "unavailable", "maintenance"
+		}
+		if len(a.healthChecks) > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), probeCheckTimeout)
+			res.Checks = make(map[string]string, len(a.healthChecks))
+			for _, hc := range a.healthChecks {
+				if err := hc.check(ctx); err != nil {
+					res.Checks[hc.name] = err.Error()
+					if res.Reason == "" {
+						res.Status, res.Reason = "unavailable", "check failed"
+					}
+					continue
+				}
+				res.Checks[hc.name] = "ok"
+			}
+			cancel()
+		}
+		code := http.StatusOK
+		if res.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		writeProbe(w, code, res)
+	})
+}
+
+func writeProbe(w http.ResponseWriter, code int, res probeResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithHealthEndpoints(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{s}, WithHealthEndpoints())
+	var dbErr error
+	app.AddHealthCheck("db", func(ctx context.Context) error { return dbErr })
+
+	probe := func(path string) (int, probeResult) {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var res probeResult
+		_ = json.Unmarshal(rec.Body.Bytes(), &res)
+		return rec.Code, res
+	}
+	if code, res := probe(ReadyzPath); code != http.StatusOK || res.Checks["db"] != "ok" {
+		t.Fatalf("健康检查正常时应该就绪，实际 %d %+v", code, res)
+	}
+	dbErr = errors.New("连接失败")
+	if code, res := probe(ReadyzPath); code != http.StatusServiceUnavailable || res.Checks["db"] != dbErr.Error() {
+		t.Fatalf("健康检查失败时应该返回 503，实际 %d %+v", code, res)
+	}
+	dbErr = nil
+
+	s.rejectReq()
+	if code, res := probe(ReadyzPath); code != http.StatusServiceUnavailable || res.Reason != "draining" {
+		t.Fatalf("拒绝新请求之后应该返回 503，实际 %d %+v", code, res)
+	}
+	if code, _ := probe(HealthzPath); code != http.StatusOK {
+		t.Fatalf("优雅退出期间存活探针应该返回 200，实际 %d", code)
+	}
+	if s.RejectedCount() != 0 {
+		t.Fatal("探针不应该计入被拒绝的请求")
+	}
+}
@@ -0,0 +1,128 @@
+package promweb
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// stopServerSpan 关闭单个服务器的步骤名称前缀，后面是服务器名称
+	stopServerSpan = "shutdown.stop_server."
+	// callbackSpan 执行单个回调的步骤名称前缀，后面是回调名称，不作为标签避免产生太多时间序列
+	callbackSpan = "shutdown.callback."
+)
+
+var (
+	rejectedDesc = prometheus.NewDesc("web_http_rejected_requests_total",
+		"优雅退出期间被拒绝（返回 503）的请求数", []string{"server"}, nil)
+	drainingDesc = prometheus.NewDesc("web_shutdown_draining",
+		"是否已经开始优雅退出", nil, nil)
+	concurrencyDesc = prometheus.NewDesc("web_http_concurrency_current",
+		"服务器正在处理的请求数", []string{"server"}, nil)
+	concurrencyLimitDesc = prometheus.NewDesc("web_http_concurrency_limit",
+		"web.WithMaxConcurrentRequests 设置的并发上限，没有设置的服务器不导出", []string{"server"}, nil)
+	limitedDesc = prometheus.NewDesc("web_http_limited_requests_total",
+		"因为并发数（reason=concurrency）或者速率（reason=rate_limit）被拒绝的请求数", []string{"server", "reason"}, nil)
+	listenersDesc = prometheus.NewDesc("web_server_listeners",
+		"服务器监听的地址数，network 是 tcp、unix 或者 HTTP/3 使用的 udp", []string{"server", "network"}, nil)
+)
+
+// lifecycleCollector 抓取时读取 App 的状态，服务器在 App 创建之后才确定，所以不能提前注册
+type lifecycleCollector struct {
+	app *web.App
+}
+
+func (c lifecycleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rejectedDesc
+	ch <- drainingDesc
+	ch <- concurrencyDesc
+	ch <- concurrencyLimitDesc
+	ch <- limitedDesc
+	ch <- listenersDesc
+}
+
+func (c lifecycleCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.app.Servers() {
+		if srv, ok := s.(*web.Server); ok {
+			ch <- prometheus.MustNewConstMetric(rejectedDesc, prometheus.CounterValue, float64(srv.RejectedCount()), srv.Name())
+			st := srv.LimiterStats()
+			ch <- prometheus.MustNewConstMetric(concurrencyDesc, prometheus.GaugeValue, float64(st.InFlight), srv.Name())
+			if st.MaxConcurrent > 0 {
+				ch <- prometheus.MustNewConstMetric(concurrencyLimitDesc, prometheus.GaugeValue, float64(st.MaxConcurrent), srv.Name())
+			}
+			ch <- prometheus.MustNewConstMetric(limitedDesc, prometheus.CounterValue, float64(st.ConcurrencyLimited), srv.Name(), "concurrency")
+			ch <- prometheus.MustNewConstMetric(limitedDesc, prometheus.CounterValue, float64(st.RateLimited), srv.Name(), "rate_limit")
+			networks := map[string]int{}
+			for _, l := range srv.Listeners() {
+				networks[l.Network]++
+			}
+			for network, n := range networks {
+				ch <- prometheus.MustNewConstMetric(listenersDesc, prometheus.GaugeValue, float64(n), srv.Name(), network)
+			}
+		}
+	}
+	var draining float64
+	if c.app.IsDraining() {
+		draining = 1
+	}
+	ch <- prometheus.MustNewConstMetric(drainingDesc, prometheus.GaugeValue, draining)
+}
+
+// phaseTracer 把优雅退出每个步骤的耗时记录到直方图，phase 标签是去掉 "shutdown." 前缀的步骤名称，
+// 整个优雅退出为 "total"，关闭单个服务器为 "stop_server" 并带上 server 标签
+type phaseTracer struct {
+	duration *prometheus.HistogramVec
+}
+
+func (t phaseTracer) Start(ctx context.Context, name string) (context.Context, func(err error)) {
+	start := time.Now()
+	phase, server := "total", ""
+	switch {
+	case strings.HasPrefix(name, stopServerSpan):
+		phase, server = "stop_server", strings.TrimPrefix(name, stopServerSpan)
+	case strings.HasPrefix(name, callbackSpan):
+		phase = "callback"
+	case name != "shutdown":
+		phase = strings.TrimPrefix(name, "shutdown.")
+	}
+	return ctx, func(error) {
+		t.duration.WithLabelValues(phase, server).Observe(time.Since(start).Seconds())
+	}
+}
+
+// WithLifecycleMetrics 把优雅退出相关的指标注册到 reg：每个 *web.Server 被拒绝的请求数、
+// 正在处理的请求数和并发上限、被限流的请求数，是否正在优雅退出，以及 drain、stop_servers、callbacks 等每个步骤的耗时。
+// 步骤耗时通过 web.WithTracer 记录，可以和 otelweb 一起使用。
+// 优雅退出的指标只在进程退出前短暂存在，需要通过 web.RegisterFlusher 推送到 Pushgateway 等才能保留
+func WithLifecycleMetrics(reg prometheus.Registerer) web.Option {
+	return func(app *web.App) {
+		if err := reg.Register(lifecycleCollector{app: app}); err != nil {
+			panic(err)
+		}
+		tracer := phaseTracer{duration: register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "web_shutdown_phase_duration_seconds",
+			Help:    "优雅退出每个步骤的耗时",
+			Buckets: []float64{.01, .05, .1, .5, 1, 2, 5, 10, 30, 60},
+		}, []string{"phase", "server"}))}
+		web.WithTracer(tracer)(app)
+	}
+}
+
+// WithMetricsServer 让 App 额外管理一个在 addr 上提供 /metrics 的服务器，指标来自 g。
+// 这个服务器不摘流量（web.WithNoDrain），优雅退出期间仍然可以抓取指标
+func WithMetricsServer(addr string, g prometheus.Gatherer) web.Option {
+	s := web.NewServer("metrics", addr, web.WithNoDrain())
+	s.Handle("/metrics", Handler(g))
+	return web.WithServers(s)
+}
+
+// Handler 返回以 Prometheus 格式输出 g 中指标的 handler，可以注册到已有的 admin 服务器上
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,94 @@
+package promweb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type countTracer struct {
+	spans int
+}
+
+func (t *countTracer) Start(ctx context.Context, _ string) (context.Context, func(err error)) {
+	t.spans++
+	return ctx, func(error) {}
+}
+
+func TestWithLifecycleMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := web.NewServer("api", "localhost:0", web.WithMaxConcurrentRequests(5))
+	other := &countTracer{}
+	app := web.NewApp([]*web.Server{s}, web.WithTracer(other), WithLifecycleMetrics(reg), web.WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if other.spans == 0 {
+		t.Fatal("已经设置的 Tracer 应该继续收到优雅退出的步骤")
+	}
+	want := `
+# HELP web_shutdown_draining 是否已经开始优雅退出
+# TYPE web_shutdown_draining gauge
+web_shutdown_draining 1
+# HELP web_http_rejected_requests_total 优雅退出期间被拒绝（返回 503）的请求数
+# TYPE web_http_rejected_requests_total counter
+web_http_rejected_requests_total{server="api"} 0
+# HELP web_http_concurrency_limit web.WithMaxConcurrentRequests 设置的并发上限，没有设置的服务器不导出
+# TYPE web_http_concurrency_limit gauge
+web_http_concurrency_limit{server="api"} 5
+# HELP web_http_limited_requests_total 因为并发数（reason=concurrency）或者速率（reason=rate_limit）被拒绝的请求数
+# TYPE web_http_limited_requests_total counter
+web_http_limited_requests_total{reason="concurrency",server="api"} 0
+web_http_limited_requests_total{reason="rate_limit",server="api"} 0
+# HELP web_server_listeners 服务器监听的地址数，network 是 tcp、unix 或者 HTTP/3 使用的 udp
+# TYPE web_server_listeners gauge
+web_server_listeners{network="tcp",server="api"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "web_shutdown_draining", "web_http_rejected_requests_total",
+		"web_http_concurrency_limit", "web_http_limited_requests_total", "web_server_listeners"); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases := make(map[string]bool)
+	for _, mf := range mfs {
+		if mf.GetName() != "web_shutdown_phase_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "phase" {
+					phases[l.GetValue()] = true
+				}
+			}
+		}
+	}
+	for _, p := range []string{"total", "drain", "stop_servers", "stop_server", "callbacks"} {
+		if !phases[p] {
+			t.Fatalf("缺少步骤 %s 的耗时，实际 %v", p, phases)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	NewMetrics(reg).requests.WithLabelValues("api", "/", "GET", "200").Inc()
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `web_http_requests_total{code="200",method="GET",route="/",server="api"} 1`) {
+		t.Fatalf("应该输出注册的指标: %s", body)
+	}
+}
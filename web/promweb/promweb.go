@@ -0,0 +1,102 @@
+// Package promweb 为 web 包提供 Prometheus 请求指标和优雅退出指标，
+// 单独成包，不使用 Prometheus 的用户不会引入相关依赖
+package promweb
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute 没有匹配到路由的请求使用的 route 标签
+const unmatchedRoute = "unmatched"
+
+// Metrics 按照服务器名称和路由统计的请求指标
+type Metrics struct {
+	// 作为标签的服务器元数据的 key
+	metadataKeys []string
+	requests     *prometheus.CounterVec
+	inFlight     *prometheus.GaugeVec
+	duration     *prometheus.HistogramVec
+}
+
+// NewMetrics 创建请求指标并注册到 reg，reg 中已经注册过同名指标时复用已有的指标。
+// metadataKeys 中的服务器元数据（web.WithServerMetadata、web.WithMetadata）会作为额外的标签，
+// 服务器没有设置时为空字符串。这些元数据的取值应该很少，否则会产生大量的时间序列
+func NewMetrics(reg prometheus.Registerer, metadataKeys ...string) *Metrics {
+	labels := func(names ...string) []string {
+		return append(names, metadataKeys...)
+	}
+	return &Metrics{
+		metadataKeys: metadataKeys,
+		requests: register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "web_http_requests_total",
+			Help: "处理的请求数",
+		}, labels("server", "route", "method", "code"))),
+		inFlight: register(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "web_http_requests_in_flight",
+			Help: "正在处理的请求数",
+		}, labels("server"))),
+		duration: register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "web_http_request_duration_seconds",
+			Help:    "请求的处理耗时",
+			Buckets: prometheus.DefBuckets,
+		}, labels("server", "route", "method"))),
+	}
+}
+
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Middleware 返回统计 s 的请求指标的中间件，route 标签使用匹配到的路由而不是原始路径
+func (m *Metrics) Middleware(s *web.Server) web.Middleware {
+	name := s.Name()
+	md := s.Metadata()
+	extra := make([]string, 0, len(m.metadataKeys))
+	for _, k := range m.metadataKeys {
+		extra = append(extra, md[k])
+	}
+	values := func(vs ...string) []string {
+		return append(vs, extra...)
+	}
+	inFlight := m.inFlight.WithLabelValues(values(name)...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			route := s.Pattern(r)
+			if route == "" {
+				route = unmatchedRoute
+			}
+			inFlight.Inc()
+			defer inFlight.Dec()
+			rw := web.NewResponseWriter(w)
+			next.ServeHTTP(rw, r)
+			code := rw.Status()
+			if code == 0 {
+				code = http.StatusOK
+			}
+			m.requests.WithLabelValues(values(name, route, r.Method, strconv.Itoa(code))...).Inc()
+			m.duration.WithLabelValues(values(name, route, r.Method)...).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// WithRequestMetrics 给 App 管理的每个 *web.Server 添加请求指标的中间件，指标注册到 reg，
+// metadataKeys 同 NewMetrics
+func WithRequestMetrics(reg prometheus.Registerer, metadataKeys ...string) web.Option {
+	return web.WithServerMiddleware(NewMetrics(reg, metadataKeys...).Middleware)
+}
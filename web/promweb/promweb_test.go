@@ -0,0 +1,69 @@
+package promweb
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tuanzi-bug/component-base/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithRequestMetrics(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+
+	reg := prometheus.NewRegistry()
+	s := web.NewServer("api", addr)
+	s.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	app := web.NewApp([]*web.Server{s}, WithRequestMetrics(reg), web.WithWaitTime(0))
+	// 同一个 registry 再注册一次时复用已有的指标
+	m := NewMetrics(reg)
+	if err = app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("api", "GET /users/{id}", "GET", "201")); got != 2 {
+		t.Fatalf("期望按路由统计 2 个请求，实际 %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("api", unmatchedRoute, "GET", "404")); got != 1 {
+		t.Fatalf("没有匹配的路由期望 1 个请求，实际 %v", got)
+	}
+	if got := testutil.ToFloat64(m.inFlight.WithLabelValues("api")); got != 0 {
+		t.Fatalf("请求结束之后正在处理的请求数应该是 0，实际 %v", got)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 2 {
+		t.Fatalf("期望 2 组耗时指标，实际 %d", n)
+	}
+}
+
+func TestNewMetrics_MetadataLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg, "region")
+	s := web.NewServer("api", "localhost:0", web.WithServerMetadata(map[string]string{"region": "cn"}))
+	h := m.Middleware(s)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("api", unmatchedRoute, "GET", "200", "cn")); got != 1 {
+		t.Fatalf("元数据应该作为标签，实际 %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ProtocolServer 和 Server 共用路由、中间件、拒绝标记以及正在处理的请求数的其他协议的服务器，
+// 例如 h3web 提供的 HTTP/3。优雅退出时和 TCP listener 一起摘流量、一起关闭
+type ProtocolServer interface {
+	// Listen 在 Server 开始监听时调用，返回实际监听的地址。RestartServer 之后会再次调用
+	Listen() (net.Addr, error)
+	// Serve 处理请求直到关闭，关闭之后返回 http.ErrServerClosed
+	Serve(h http.Handler) error
+	// Shutdown 不再接受新的连接和请求，等待正在处理的请求结束，ctx 到期时强制关闭
+	Shutdown(ctx context.Context) error
+	// Close 立刻关闭
+	Close() error
+}
+
+// WithProtocolServer 服务器同时通过 p 提供服务，p 收到的请求和 TCP listener 一样经过 Server 的路由和中间件，
+// 计入 InFlight，优雅退出期间同样被拒绝。开启了 WithProtocolServer 的服务器不支持平滑升级
+func WithProtocolServer(p ProtocolServer) ServerOption {
+	return func(s *Server) {
+		s.protocols = append(s.protocols, p)
+	}
+}
+
+// ListenerInfo 服务器的一个监听
+type ListenerInfo struct {
+	// Network 监听的网络，例如 "tcp"、"unix"，HTTP/3 是 "udp"
+	Network string `json:"network"`
+	Addr    string `json:"addr"`
+}
+
+// Listeners 返回服务器实际监听的所有地址，包括 Unix socket、注入的 listener 和 WithProtocolServer。
+// 还没有开始监听时返回 nil
+func (s *Server) Listeners() []ListenerInfo {
+	if l, ok := s.listenerInfo.Load().([]ListenerInfo); ok {
+		return append([]ListenerInfo(nil), l...)
+	}
+	return nil
+}
+
+// listenProtocols 启动 WithProtocolServer 的监听，失败时关闭已经启动的
+func (s *Server) listenProtocols() ([]net.Addr, error) {
+	addrs := make([]net.Addr, 0, len(s.protocols))
+	for i, p := range s.protocols {
+		addr, err := p.Listen()
+		if err != nil {
+			for _, started := range s.protocols[:i] {
+				_ = started.Close()
+			}
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// storeListeners 记录监听的地址，供 Listeners 在其他 goroutine 中读取
+func (s *Server) storeListeners(protoAddrs []net.Addr) {
+	var res []ListenerInfo
+	if s.lis != nil {
+		res = append(res, listenerInfo(s.lis.Addr()))
+	}
+	for _, l := range s.extraLis {
+		res = append(res, listenerInfo(l.Addr()))
+	}
+	for _, addr := range protoAddrs {
+		res = append(res, listenerInfo(addr))
+	}
+	s.listenerInfo.Store(res)
+}
+
+func listenerInfo(addr net.Addr) ListenerInfo {
+	return ListenerInfo{Network: addr.Network(), Addr: addr.String()}
+}
+
+// shutdownProtocols 在后台关闭 WithProtocolServer，返回的函数等待关闭完成
+func (s *Server) shutdownProtocols(ctx context.Context) func() error {
+	errs := make(chan error, len(s.protocols))
+	for _, p := range s.protocols {
+		go func() {
+			err := p.Shutdown(ctx)
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			errs <- err
+		}()
+	}
+	return func() error {
+		res := make([]error, 0, len(s.protocols))
+		for range s.protocols {
+			res = append(res, <-errs)
+		}
+		return errors.Join(res...)
+	}
+}
+
+// closeProtocols 立刻关闭 WithProtocolServer
+func (s *Server) closeProtocols() error {
+	errs := make([]error, 0, len(s.protocols))
+	for _, p := range s.protocols {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// fakeProtocol 在单独的 TCP listener 上用另一个 http.Server 模拟其他协议
+type fakeProtocol struct {
+	lis net.Listener
+	srv *http.Server
+}
+
+func (p *fakeProtocol) Listen() (net.Addr, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p.lis, p.srv = lis, &http.Server{}
+	return lis.Addr(), nil
+}
+
+func (p *fakeProtocol) Serve(h http.Handler) error {
+	p.srv.Handler = h
+	return p.srv.Serve(p.lis)
+}
+
+func (p *fakeProtocol) Shutdown(ctx context.Context) error { return p.srv.Shutdown(ctx) }
+func (p *fakeProtocol) Close() error                       { return p.srv.Close() }
+
+func TestWithProtocolServer(t *testing.T) {
+	p := &fakeProtocol{}
+	s := NewServer("api", "127.0.0.1:0", WithProtocolServer(p))
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ls := s.Listeners()
+	if len(ls) != 2 || ls[1].Addr != p.lis.Addr().String() {
+		t.Fatalf("Listeners 应该包含 WithProtocolServer 的地址，实际 %v", ls)
+	}
+	url := "http://" + p.lis.Addr().String() + "/"
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	s.rejectReq()
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || s.RejectedCount() != 1 {
+		t.Fatalf("优雅退出期间其他协议的请求也应该被拒绝，实际 %d", resp.StatusCode)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatal("关闭服务器时应该同时关闭 WithProtocolServer")
+	}
+}
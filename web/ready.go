@@ -0,0 +1,36 @@
+package web
+
+import (
+	"context"
+	"net"
+)
+
+// WaitReady 阻塞到 Start 返回：所有服务器都开始监听并且注册成功时返回 nil，
+// Start 失败时返回同样的错误，ctx 先结束时返回 ctx.Err()。
+// 用于在另一个 goroutine 中调用 Start 或者 StartAndServe 的测试和编排代码，
+// 配合 WithStartupTimeout 可以让任何一个服务器启动失败或者超时时整个应用立刻退出
+func (a *App) WaitReady(ctx context.Context) error {
+	select {
+	case <-a.started:
+		return a.startErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// markStarted 记录 Start 的结果并通知 WaitReady
+func (a *App) markStarted(err error) {
+	a.startOnce.Do(func() {
+		a.startErr = err
+		close(a.started)
+	})
+}
+
+// ListenerAddr 返回服务器实际监听的地址，监听 ":0" 时可以拿到系统分配的端口。
+// 还没有开始监听时返回 nil
+func (s *Server) ListenerAddr() net.Addr {
+	if addr, ok := s.boundAddr.Load().(net.Addr); ok {
+		return addr
+	}
+	return nil
+}
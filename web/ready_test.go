@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApp_WaitReady(t *testing.T) {
+	s := NewServer("api", "localhost:0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	if s.ListenerAddr() != nil {
+		t.Fatal("开始监听之前应该返回 nil")
+	}
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	go func() {
+		_ = app.Run(context.Background())
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := app.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	resp, err := http.Get("http://" + s.ListenerAddr().String())
+	if err != nil {
+		t.Fatalf("WaitReady 返回之后应该可以连接: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestApp_WaitReady_StartFailed(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	ok := NewServer("ok", "localhost:0")
+	app := NewApp([]*Server{ok, NewServer("busy", lis.Addr().String())}, WithStartupTimeout(time.Second))
+	go func() {
+		_ = app.Start()
+	}()
+	if err = app.WaitReady(context.Background()); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("有服务器启动失败时应该返回启动错误，实际 %v", err)
+	}
+	if _, err = net.Dial("tcp", ok.ListenerAddr().String()); err == nil {
+		t.Fatal("有服务器启动失败时已经启动的服务器应该被关闭")
+	}
+}
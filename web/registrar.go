@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+)
+
+// Registrar 服务注册，例如 Consul、etcd。需要按服务器实例注册时使用 InstanceRegistrar
+type Registrar interface {
+	// Register 所有服务器开始监听之后调用
+	Register(ctx context.Context) error
+	// Deregister 开始优雅退出、拒绝新请求之前调用，让注册中心先停止把流量路由过来
+	Deregister(ctx context.Context) error
+}
+
+// WithRegistrar 应用启动后注册服务，优雅退出时先注销服务再等待已有请求。
+// 每次调用的超时时间和回调一致
+func WithRegistrar(rs ...Registrar) Option {
+	return func(app *App) {
+		app.registrars = append(app.registrars, rs...)
+	}
+}
+
+// register 注册服务，任意一个失败时返回错误
+func (a *App) register() error {
+	for _, r := range a.registrars {
+		ctx, cancel := context.WithTimeout(a.ctx, a.cbTimeout)
+		err := r.Register(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("web: 注册服务失败: %w", err)
+		}
+	}
+	if len(a.registrars) > 0 {
+		a.logf("服务注册完成")
+	}
+	return nil
+}
+
+// deregister 注销所有服务，失败时继续注销剩下的
+func (a *App) deregister(ctx context.Context) error {
+	if len(a.registrars) == 0 {
+		return nil
+	}
+	ctx, end := a.tracer.Start(ctx, "shutdown.deregister")
+	var errs []error
+	for _, r := range a.registrars {
+		rCtx, cancel := a.withTimeout(ctx, a.cbTimeout)
+		if err := r.Deregister(rCtx); err != nil {
+			a.logf("注销服务失败 %v", err)
+			errs = append(errs, fmt.Errorf("web: 注销服务失败: %w", err))
+		}
+		cancel()
+	}
+	err := errors.Join(errs...)
+	end(err)
+	a.logf("服务注销完成")
+	return err
+}
+
+// Instance 注册到注册中心的一个服务器实例
+type Instance struct {
+	// ID 实例的唯一标识，默认为 "服务器名称-地址"
+	ID string
+	// Name 服务器名称
+	Name string
+	// Addr 实际监听的 host:port，监听的是 0.0.0.0 这类地址时替换为本机的第一个非回环 IP
+	Addr string
+	// Scheme http 或 https，服务器配置了 TLS 时为 https
+	Scheme string
+	// Metadata 服务器的元数据，见 WithServerMetadata
+	Metadata map[string]string
+}
+
+// InstanceRegistrar 按服务器实例注册的注册中心，例如 Consul、etcd、Nacos，实现见 consulweb
+type InstanceRegistrar interface {
+	// Register 服务器开始监听之后调用，每个服务器调用一次
+	Register(ctx context.Context, inst Instance) error
+	// Deregister 开始优雅退出、拒绝新请求之前调用，让注册中心先停止把流量路由过来
+	Deregister(ctx context.Context, inst Instance) error
+}
+
+// WithInstanceRegistrar 应用启动后把每个服务器注册到 r，优雅退出时先注销再拒绝新请求。
+// 只注册能拿到监听地址的服务器（*Server 以及实现了 ListenerAddr() net.Addr 的服务器），
+// WithAdminServer 创建的管理服务器不注册。任意一个注册失败时注销已经注册的实例，启动失败
+func WithInstanceRegistrar(r InstanceRegistrar) Option {
+	return func(app *App) {
+		app.registrars = append(app.registrars, &instanceRegistrar{app: app, r: r})
+	}
+}
+
+// instanceRegistrar 把 InstanceRegistrar 适配成 Registrar
+type instanceRegistrar struct {
+	app        *App
+	r          InstanceRegistrar
+	registered []Instance
+}
+
+func (ir *instanceRegistrar) Register(ctx context.Context) error {
+	for _, inst := range ir.app.instances() {
+		if err := ir.r.Register(ctx, inst); err != nil {
+			_ = ir.Deregister(ctx)
+			return fmt.Errorf("%s: %w", inst.ID, err)
+		}
+		ir.registered = append(ir.registered, inst)
+	}
+	return nil
+}
+
+func (ir *instanceRegistrar) Deregister(ctx context.Context) error {
+	var errs []error
+	for _, inst := range ir.registered {
+		if err := ir.r.Deregister(ctx, inst); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", inst.ID, err))
+		}
+	}
+	ir.registered = nil
+	return errors.Join(errs...)
+}
+
+// instances 需要注册的服务器实例
+func (a *App) instances() []Instance {
+	var res []Instance
+	for _, s := range a.servers {
+		al, ok := s.(interface{ ListenerAddr() net.Addr })
+		if !ok || s.Name() == AdminServerName {
+			continue
+		}
+		addr := al.ListenerAddr()
+		if addr == nil || addr.Network() != "tcp" {
+			continue
+		}
+		inst := Instance{Name: s.Name(), Addr: advertiseAddr(addr.String()), Scheme: "http"}
+		inst.ID = inst.Name + "-" + inst.Addr
+		if srv, ok := s.(*Server); ok {
+			inst.Metadata = srv.Metadata()
+			if srv.tlsEnabled() {
+				inst.Scheme = "https"
+			}
+		}
+		res = append(res, inst)
+	}
+	return res
+}
+
+// advertiseAddr 监听的是未指定的地址时，替换为本机的第一个非回环 IP
+func advertiseAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return addr
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return addr
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return net.JoinHostPort(ipNet.IP.String(), port)
+		}
+	}
+	return addr
+}
+
+// MemoryRegistry 保存在内存中的 InstanceRegistrar，用于测试和本地开发
+type MemoryRegistry struct {
+	mu        sync.Mutex
+	instances map[string]Instance
+}
+
+// NewMemoryRegistry 创建空的 MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{instances: make(map[string]Instance)}
+}
+
+func (m *MemoryRegistry) Register(ctx context.Context, inst Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instances[inst.ID] = inst
+	return nil
+}
+
+func (m *MemoryRegistry) Deregister(ctx context.Context, inst Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.instances, inst.ID)
+	return nil
+}
+
+// Instances 返回已经注册的实例，按照 ID 排序
+func (m *MemoryRegistry) Instances() []Instance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]Instance, 0, len(m.instances))
+	for _, id := range slices.Sorted(maps.Keys(m.instances)) {
+		res = append(res, m.instances[id])
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeRegistrar struct {
+	registerErr error
+	events      []string
+	// 注销时服务器是否已经开始拒绝请求
+	rejecting func() bool
+}
+
+func (r *fakeRegistrar) Register(ctx context.Context) error {
+	r.events = append(r.events, "register")
+	return r.registerErr
+}
+
+func (r *fakeRegistrar) Deregister(ctx context.Context) error {
+	event := "deregister"
+	if r.rejecting() {
+		event = "deregister after reject"
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestWithRegistrar(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	reg := &fakeRegistrar{rejecting: s.mux.reject.Load}
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithRegistrar(reg))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.events) != 2 || reg.events[0] != "register" || reg.events[1] != "deregister" {
+		t.Fatalf("应该启动后注册、拒绝请求之前注销，实际 %v", reg.events)
+	}
+}
+
+func TestWithRegistrar_RegisterError(t *testing.T) {
+	errRegister := errors.New("consul unavailable")
+	s := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{s}, WithRegistrar(&fakeRegistrar{registerErr: errRegister}))
+	if err := app.Start(); !errors.Is(err, errRegister) {
+		t.Fatalf("期望注册失败的错误，实际 %v", err)
+	}
+}
+
+// rejectCheckRegistry 注销时记录服务器是否已经开始拒绝请求
+type rejectCheckRegistry struct {
+	*MemoryRegistry
+	s                    *Server
+	deregisteredRejected bool
+}
+
+func (r *rejectCheckRegistry) Deregister(ctx context.Context, inst Instance) error {
+	r.deregisteredRejected = r.deregisteredRejected || r.s.mux.reject.Load()
+	return r.MemoryRegistry.Deregister(ctx, inst)
+}
+
+func TestWithInstanceRegistrar(t *testing.T) {
+	api := NewServer("api", "localhost:0", WithServerMetadata(map[string]string{"zone": "a"}))
+	web := NewServer("web", "localhost:0")
+	reg := &rejectCheckRegistry{MemoryRegistry: NewMemoryRegistry(), s: api}
+	app := NewApp([]*Server{api, web}, WithWaitTime(0), WithAdminServer("localhost:0", ""), WithInstanceRegistrar(reg))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	insts := reg.Instances()
+	if len(insts) != 2 {
+		t.Fatalf("应该注册 2 个实例（不包括管理服务器），实际 %v", insts)
+	}
+	if insts[0].Name != "api" || insts[0].Addr != api.ListenerAddr().String() || insts[0].Scheme != "http" || insts[0].Metadata["zone"] != "a" {
+		t.Fatalf("实例信息不对 %+v", insts[0])
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reg.Instances()); n != 0 {
+		t.Fatalf("优雅退出之后应该注销所有实例，还剩 %d 个", n)
+	}
+	if reg.deregisteredRejected {
+		t.Fatal("应该在拒绝新请求之前注销")
+	}
+}
+
+func TestWithInstanceRegistrar_TLSScheme(t *testing.T) {
+	s := NewServer("tls", "127.0.0.1:0", WithTLSKeyPair(newTestCertPEM(t, "127.0.0.1")))
+	reg := NewMemoryRegistry()
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithInstanceRegistrar(reg))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Shutdown(context.Background())
+	if insts := reg.Instances(); len(insts) != 1 || insts[0].Scheme != "https" {
+		t.Fatalf("配置了 TLS 的服务器 Scheme 应该是 https，实际 %+v", insts)
+	}
+}
+
+// failingRegistry 注册第二个实例时失败
+type failingRegistry struct {
+	*MemoryRegistry
+	calls int
+}
+
+func (r *failingRegistry) Register(ctx context.Context, inst Instance) error {
+	r.calls++
+	if r.calls > 1 {
+		return errors.New("registry unavailable")
+	}
+	return r.MemoryRegistry.Register(ctx, inst)
+}
+
+func TestWithInstanceRegistrar_RegisterError(t *testing.T) {
+	reg := &failingRegistry{MemoryRegistry: NewMemoryRegistry()}
+	app := NewApp([]*Server{NewServer("a", "localhost:0"), NewServer("b", "localhost:0")}, WithInstanceRegistrar(reg))
+	if err := app.Start(); err == nil {
+		t.Fatal("注册失败时启动应该失败")
+	}
+	if n := len(reg.Instances()); n != 0 {
+		t.Fatalf("注册失败时应该注销已经注册的实例，还剩 %d 个", n)
+	}
+}
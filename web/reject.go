@@ -0,0 +1,76 @@
+package web
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rejectMsg = "服务已关闭"
+
+// defaultRetryAfter 已经超过等待时间窗口之后建议客户端重试的间隔
+const defaultRetryAfter = 5 * time.Second
+
+// retryAfter 根据等待窗口在 now 时剩余的时间计算 Retry-After，单位秒
+func retryAfter(drainEnd, now time.Time) string {
+	remaining := drainEnd.Sub(now)
+	if remaining <= 0 {
+		remaining = defaultRetryAfter
+	}
+	return strconv.Itoa(int(math.Ceil(remaining.Seconds())))
+}
+
+// defaultRejectHandler 根据 Accept 头返回 JSON、HTML 或者纯文本格式的 503
+func defaultRejectHandler(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/json"):
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"code":503,"msg":"` + rejectMsg + `"}`))
+	case strings.Contains(accept, "text/html"):
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>503</title></head>" +
+			"<body><h1>503 Service Unavailable</h1><p>" + rejectMsg + "</p></body></html>"))
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(rejectMsg))
+	}
+}
+
+// JSONRejectHandler 优雅退出期间总是返回 JSON 格式的 503，响应体为 v 序列化的结果，
+// 例如 map[string]any{"code": "SHUTTING_DOWN"}，用于和 API 其他错误的格式保持一致
+func JSONRejectHandler(v any) http.Handler {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic("web: JSONRejectHandler 序列化响应体失败: " + err.Error())
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write(body)
+	})
+}
+
+// WithDrainExemptPaths 优雅退出期间仍然正常处理以 paths 开头的请求，例如 "/healthz"、"/metrics"，
+// 这些请求同样计入正在处理的请求数。需要响应 Connection: close 时配合 WithDrainConnectionClose
+func WithDrainExemptPaths(paths ...string) ServerOption {
+	return func(s *Server) {
+		s.mux.drainExemptPaths = append(s.mux.drainExemptPaths, paths...)
+	}
+}
+
+// drainExempt 请求是否不受优雅退出影响
+func (s *serverMux) drainExempt(r *http.Request) bool {
+	for _, p := range s.drainExemptPaths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
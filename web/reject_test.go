@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDefaultRejectHandler(t *testing.T) {
+	testCases := []struct {
+		name        string
+		accept      string
+		contentType string
+	}{
+		{name: "json", accept: "application/json", contentType: "application/json"},
+		{name: "html", accept: "text/html,application/xhtml+xml", contentType: "text/html"},
+		{name: "text", accept: "*/*", contentType: "text/plain"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tc.accept)
+			rec := httptest.NewRecorder()
+			defaultRejectHandler(rec, req)
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("期望 503，实际 %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tc.contentType) {
+				t.Fatalf("期望 %s，实际 %s", tc.contentType, ct)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.setDrainWindow(time.Now(), 10*time.Second)
+	s.rejectReq()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Fatalf("期望 Retry-After 10，实际 %q", got)
+	}
+
+	s.setDrainWindow(time.Now().Add(-time.Minute), 10*time.Second)
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("超过等待窗口之后期望默认值 5，实际 %q", got)
+	}
+}
+
+func TestRejectPolicies(t *testing.T) {
+	s := NewServer("test", "localhost:0",
+		WithRejectHandler(JSONRejectHandler(map[string]string{"code": "SHUTTING_DOWN"})),
+		WithDrainExemptPaths("/healthz"),
+		WithDrainConnectionClose())
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	s.setDrainWindow(time.Now(), 10*time.Second)
+	s.rejectReq()
+
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != `{"code":"SHUTTING_DOWN"}` {
+		t.Fatalf("期望 JSON 格式的 503，实际 %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("期望 application/json，实际 %s", ct)
+	}
+	if rec.Header().Get("Retry-After") != "10" || rec.Header().Get("Connection") != "close" {
+		t.Fatalf("拒绝的响应应该带上 Retry-After 和 Connection: close，实际 %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("豁免的路径应该继续处理，实际 %d %q", rec.Code, rec.Body.String())
+	}
+	if got := s.RejectedCount(); got != 1 {
+		t.Fatalf("豁免的请求不应该计入拒绝数，实际 %d", got)
+	}
+}
+
+func TestApp_AcceptDuringDrain(t *testing.T) {
+	addr := freeAddr(t)
+	s := NewServer("test", addr)
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	app := NewApp([]*Server{s}, WithWaitTime(time.Minute), WithMinDrainTime(500*time.Millisecond))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = app.Shutdown(context.Background())
+		close(done)
+	}()
+	for !s.mux.reject.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	// 开始拒绝新请求之后、关闭服务器之前，新的连接仍然被接受并且收到 503
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatalf("关闭服务器之前应该继续接受连接: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("期望 503，实际 %d", resp.StatusCode)
+	}
+	<-done
+}
+
+// 用 go test -race 运行时可以发现请求处理和优雅退出之间的数据竞争
+func TestApp_ConcurrentTrafficDuringShutdown(t *testing.T) {
+	s := NewServer("api", "localhost:0", WithInFlightDebug(), WithConnDrain(time.Second), WithAggressiveDrain())
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(200*time.Millisecond))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.ListenerAddr().String()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var unexpected atomic.Int32
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				resp, err := http.Get(url)
+				if err != nil {
+					// 服务器关闭之后连接失败是正常的
+					continue
+				}
+				_ = resp.Body.Close()
+				if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+					unexpected.Add(1)
+				}
+			}
+		}()
+	}
+	for range 3 {
+		s.rejectReq()
+		app.EnterMaintenance(RejectResponse{})
+		app.ExitMaintenance()
+		if err := app.ResumeTraffic(); err != nil {
+			t.Fatal(err)
+		}
+		_ = s.InFlightRequests()
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	if n := unexpected.Load(); n > 0 {
+		t.Fatalf("只应该返回 200 或者 503，有 %d 个其他状态码", n)
+	}
+}
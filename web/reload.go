@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultReloadTimeout 没有设置 WithReloadTimeout 时每次重新加载的超时时间
+const defaultReloadTimeout = 10 * time.Second
+
+// ReloadFunc 重新加载配置的钩子，例如重新读取配置文件、刷新日志级别
+type ReloadFunc func(ctx context.Context) error
+
+// OnReload 注册收到 SIGHUP 或者调用 Reload 时执行的钩子，按照注册顺序依次执行。
+// 需要在 Start 之前调用。SIGHUP 只用于重新加载，不会触发优雅退出
+func (a *App) OnReload(fn ReloadFunc) {
+	a.reloads = append(a.reloads, fn)
+}
+
+// WithReloadTimeout 设置一次重新加载（所有钩子加起来）的超时时间，默认 10 秒
+func WithReloadTimeout(d time.Duration) Option {
+	return func(app *App) {
+		app.reloadTimeout = d
+	}
+}
+
+// Reload 执行所有 OnReload 注册的钩子，一个钩子失败不影响后面的钩子，返回所有失败的钩子的错误。
+// 同一时间只会执行一次重新加载，开始优雅退出之后返回 ErrStopping
+func (a *App) Reload(ctx context.Context) error {
+	if a.shutdownStarted.Load() {
+		return ErrStopping
+	}
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	timeout := a.reloadTimeout
+	if timeout <= 0 {
+		timeout = defaultReloadTimeout
+	}
+	ctx, cancel := a.withTimeout(ctx, timeout)
+	defer cancel()
+	start := a.clock.Now()
+	var errs []error
+	for i, fn := range a.reloads {
+		hookStart := a.clock.Now()
+		err := fn(ctx)
+		kv := []any{"hook", i, "duration", a.since(hookStart)}
+		if err != nil {
+			a.logEvent("reload_hook_failed", append(kv, "error", err), "重新加载钩子%d失败 %v", i, err)
+			errs = append(errs, fmt.Errorf("web: 重新加载钩子%d失败: %w", i, err))
+			continue
+		}
+		a.logEvent("reload_hook_done", kv, "重新加载钩子%d完成", i)
+	}
+	err := errors.Join(errs...)
+	a.logEvent("reload_done", []any{"duration", a.since(start), "failed", len(errs)},
+		"重新加载完成，%d个钩子失败，耗时 %v", len(errs), a.since(start))
+	return err
+}
+
+// startReloadWatch 监听 reloadSignals，收到时执行重新加载，应用关闭完成之后停止监听。
+// 优雅退出期间仍然接收这些信号，避免 SIGHUP 的默认行为直接结束进程
+func (a *App) startReloadWatch() {
+	if len(a.reloads) == 0 || len(reloadSignals) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	a.notifier.Notify(ch, reloadSignals...)
+	go func() {
+		defer a.notifier.Stop(ch)
+		for {
+			select {
+			case <-ch:
+			case <-a.done:
+				return
+			}
+			if err := a.Reload(a.ctx); errors.Is(err, ErrStopping) {
+				a.logf("正在优雅退出，忽略重新加载")
+			}
+		}
+	}()
+}
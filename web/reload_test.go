@@ -0,0 +1,33 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApp_Reload(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	var calls []int
+	errBad := errors.New("配置格式错误")
+	app.OnReload(func(ctx context.Context) error {
+		calls = append(calls, 0)
+		return errBad
+	})
+	app.OnReload(func(ctx context.Context) error {
+		calls = append(calls, 1)
+		return nil
+	})
+	if err := app.Reload(context.Background()); !errors.Is(err, errBad) {
+		t.Fatalf("应该返回失败的钩子的错误，实际 %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("一个钩子失败不应该影响后面的钩子，实际执行了 %v", calls)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Reload(context.Background()); !errors.Is(err, ErrStopping) {
+		t.Fatalf("开始优雅退出之后应该返回 ErrStopping，实际 %v", err)
+	}
+}
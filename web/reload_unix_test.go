@@ -0,0 +1,36 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestApp_ReloadOnSIGHUP(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	reloaded := make(chan struct{}, 1)
+	app.OnReload(func(ctx context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到 SIGHUP 应该执行重新加载")
+	}
+	if app.IsDraining() {
+		t.Fatal("SIGHUP 不应该触发优雅退出")
+	}
+}
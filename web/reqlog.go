@@ -0,0 +1,121 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type requestLoggerKey struct{}
+
+// RequestLogger 请求级别的日志，handler 通过 WithFields 追加的字段（例如用户 ID、租户）
+// 会带在这个请求之后的每一行日志里，包括 AccessLog 输出的访问日志。
+// 同一个请求里的多个 goroutine 可以并发使用
+type RequestLogger struct {
+	logger *log.Logger
+	mu     sync.Mutex
+	fields []any
+}
+
+// Printf 输出一行带上请求字段的日志
+func (l *RequestLogger) Printf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...) + l.formatFields()
+	if l.logger != nil {
+		l.logger.Print(msg)
+		return
+	}
+	log.Print(msg)
+}
+
+// Fields 返回已经追加的字段，按照 key, value, key, value 的顺序排列
+func (l *RequestLogger) Fields() []any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]any(nil), l.fields...)
+}
+
+func (l *RequestLogger) addFields(kv []any) {
+	if len(kv)%2 != 0 {
+		kv = append(kv, "")
+	}
+	l.mu.Lock()
+	l.fields = append(l.fields, kv...)
+	l.mu.Unlock()
+}
+
+func (l *RequestLogger) formatFields() string {
+	fields := l.Fields()
+	var sb strings.Builder
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", fields[i], fields[i+1])
+	}
+	return sb.String()
+}
+
+// LoggerFromContext 返回 ctx 里的请求日志，没有经过 RequestLogging 或者 AccessLog 中间件时
+// 返回一个输出到标准库 log 的新日志，追加的字段不会被其他地方看到
+func LoggerFromContext(ctx context.Context) *RequestLogger {
+	if l, ok := ctx.Value(requestLoggerKey{}).(*RequestLogger); ok {
+		return l
+	}
+	return &RequestLogger{}
+}
+
+// WithFields 给 ctx 里的请求日志追加字段，kv 按照 key, value 成对传入。
+// 字段直接追加到这个请求共享的日志上，访问日志也能看到；
+// ctx 里没有请求日志时会创建一个新的放到返回的 context 里
+func WithFields(ctx context.Context, kv ...any) context.Context {
+	if l, ok := ctx.Value(requestLoggerKey{}).(*RequestLogger); ok {
+		l.addFields(kv)
+		return ctx
+	}
+	l := &RequestLogger{}
+	l.addFields(kv)
+	return context.WithValue(ctx, requestLoggerKey{}, l)
+}
+
+// RequestLogging 给每个请求的 context 放入一个输出到 w 的请求日志，w 为 nil 时输出到标准库 log。
+// 已经有请求日志时保持不变
+func RequestLogging(w io.Writer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(rw, seedRequestLogger(r, w))
+		})
+	}
+}
+
+// AccessLog 在请求结束后输出一行访问日志，包括方法、路径、状态码、响应大小、耗时，
+// 以及 handler 通过 WithFields 追加的字段。请求里还没有请求日志时会先放入一个输出到 w 的请求日志
+func AccessLog(w io.Writer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r = seedRequestLogger(r, w)
+			resp := NewResponseWriter(rw)
+			next.ServeHTTP(resp, r)
+			status := resp.Status()
+			if status == 0 {
+				// handler 什么都没写时 net/http 会返回 200
+				status = http.StatusOK
+			}
+			LoggerFromContext(r.Context()).Printf("%s %s %d %dB %v",
+				r.Method, r.URL.RequestURI(), status, resp.Size(), time.Since(start))
+		})
+	}
+}
+
+func seedRequestLogger(r *http.Request, w io.Writer) *http.Request {
+	if _, ok := r.Context().Value(requestLoggerKey{}).(*RequestLogger); ok {
+		return r
+	}
+	l := &RequestLogger{}
+	if w != nil {
+		l.logger = log.New(w, "", log.LstdFlags)
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, l))
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAccessLog_Fields(t *testing.T) {
+	var buf bytes.Buffer
+	h := AccessLog(&buf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WithFields(r.Context(), "user", 42)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				WithFields(r.Context(), "k", "v")
+			}()
+		}
+		wg.Wait()
+		LoggerFromContext(r.Context()).Printf("处理订单")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders?id=1", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("期望两行日志，实际 %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "处理订单 user=42") {
+		t.Fatalf("handler 的日志应该带上字段: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "POST /orders?id=1 201 0B") || !strings.Contains(lines[1], "user=42") {
+		t.Fatalf("访问日志错误: %q", lines[1])
+	}
+	if n := strings.Count(lines[1], "k=v"); n != 10 {
+		t.Fatalf("期望 10 个并发追加的字段，实际 %d", n)
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	h := RequestLogging(&buf)(AccessLog(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WithFields(r.Context(), "tenant", "a")
+		_, _ = w.Write([]byte("ok"))
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := buf.String(); !strings.Contains(got, "GET / 200 2B") || !strings.Contains(got, "tenant=a") {
+		t.Fatalf("应该使用外层放入的请求日志: %q", got)
+	}
+}
+
+func TestWithFields_NoLogger(t *testing.T) {
+	ctx := WithFields(context.Background(), "user", 1, "dangling")
+	got := LoggerFromContext(ctx).Fields()
+	if len(got) != 4 || got[0] != "user" || got[1] != 1 || got[2] != "dangling" || got[3] != "" {
+		t.Fatalf("字段错误: %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter 包装 http.ResponseWriter，记录状态码和响应体大小，
+// 并且转发 http.Flusher、http.Hijacker、http.Pusher，中间件不需要各自再实现一遍
+type ResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+// NewResponseWriter 包装 w，w 已经是 *ResponseWriter 时直接返回
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	// 1xx 的响应头可以写多次，不算真正写了响应头
+	if !w.wroteHeader && code >= http.StatusOK {
+		w.wroteHeader = true
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status 响应的状态码，还没有写响应头时返回 0
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size 已经写入的响应体字节数
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written 是否已经写了响应头
+func (w *ResponseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+// Flush 底层的 ResponseWriter 不支持时什么都不做
+func (w *ResponseWriter) Flush() {
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	f.Flush()
+}
+
+// Hijack 底层的 ResponseWriter 不支持时返回 http.ErrNotSupported
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// Push 底层的 ResponseWriter 不支持时返回 http.ErrNotSupported
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Unwrap 让 http.ResponseController 可以访问底层的 ResponseWriter
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrServerNotFound 应用中没有指定名称的服务器
+var ErrServerNotFound = errors.New("web: 服务器不存在")
+
+// resetter 关闭之后需要重新创建才能再次启动的服务器
+type resetter interface {
+	reset()
+}
+
+// StopServer 只优雅关闭名为 name 的服务器，应用中其它服务器继续运行。
+// 和整个应用优雅退出一样，先拒绝新请求，等待正在执行的请求完结之后再关闭
+func (a *App) StopServer(ctx context.Context, name string) error {
+	srv, err := a.server(name)
+	if err != nil {
+		return err
+	}
+	servers := []ManagedServer{srv}
+	waitTime := a.drainTime()
+	if dw, ok := srv.(drainWindowSetter); ok {
+		dw.setDrainWindow(a.clock.Now(), waitTime)
+	}
+	rejectServer(srv)
+	a.setServerState(srv, ServerDraining)
+	if !a.waitDrained(ctx, servers, waitTime) {
+		a.logStragglers(servers)
+	}
+	if _, err := a.safeStopServer(ctx, srv); err != nil {
+		return fmt.Errorf("web: 关闭服务器%s失败: %w", name, err)
+	}
+	a.logf("服务器%s已单独关闭", name)
+	return nil
+}
+
+// StartServer 重新启动通过 StopServer 关闭的服务器，等到开始监听之后返回。
+// 应用已经开始优雅退出时返回 ErrStopping
+func (a *App) StartServer(name string) error {
+	if a.shutdownStarted.Load() {
+		return ErrStopping
+	}
+	srv, err := a.server(name)
+	if err != nil {
+		return err
+	}
+	if r, ok := srv.(resetter); ok {
+		r.reset()
+	}
+	listened := make(chan error, 1)
+	go a.runServer(srv, listened)
+	if err := <-listened; err != nil {
+		return err
+	}
+	acceptServer(srv)
+	a.logf("服务器%s重新启动", name)
+	return nil
+}
+
+// WithServerRestartPolicy 服务器异常退出（不是因为关闭）时最多重启 maxRetries 次，
+// 第 n 次重启之前等待 backoff * 2^(n-1)。重启会重新创建监听，次数用完之后 StartAndServe 开始优雅退出。
+// 默认不重启，只输出日志
+func WithServerRestartPolicy(maxRetries int, backoff time.Duration) Option {
+	return func(app *App) {
+		app.restartRetries = maxRetries
+		app.restartBackoff = backoff
+	}
+}
+
+// restartServer 等待 backoff 之后重新监听并处理请求，返回处理请求结束的原因。
+// 等待期间应用开始优雅退出时不再重启
+func (a *App) restartServer(srv ManagedServer, backoff time.Duration) error {
+	timer := a.clock.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-a.ctx.Done():
+		return nil
+	}
+	if a.shutdownStarted.Load() {
+		return nil
+	}
+	if r, ok := srv.(resetter); ok {
+		r.reset()
+	}
+	a.setServerState(srv, ServerStarting)
+	ls, ok := srv.(listenServer)
+	if !ok {
+		a.setServerState(srv, ServerRunning)
+		return srv.Start()
+	}
+	if err := ls.listen(); err != nil {
+		return fmt.Errorf("web: 服务器%s监听失败: %w", srv.Name(), err)
+	}
+	a.setServerState(srv, ServerRunning)
+	a.logf("服务器%s重新启动", srv.Name())
+	return ls.serve()
+}
+
+// Servers 返回 App 管理的所有服务器，顺序和注册顺序一致
+func (a *App) Servers() []ManagedServer {
+	return append([]ManagedServer(nil), a.servers...)
+}
+
+// server 查找名为 name 的服务器
+func (a *App) server(name string) (ManagedServer, error) {
+	for _, s := range a.servers {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServerNotFound, name)
+}
+
+// reset http.Server 关闭之后不能再次启动，按照原来的配置重新创建一个。
+// TLSNextProto 中的 HTTP/2 处理函数绑定了原来的 http.Server，启动时会重新配置
+func (s *Server) reset() {
+	old := s.srv
+	s.srv = &http.Server{
+		Addr:                         old.Addr,
+		Handler:                      old.Handler,
+		DisableGeneralOptionsHandler: old.DisableGeneralOptionsHandler,
+		TLSConfig:                    old.TLSConfig,
+		ReadTimeout:                  old.ReadTimeout,
+		ReadHeaderTimeout:            old.ReadHeaderTimeout,
+		WriteTimeout:                 old.WriteTimeout,
+		IdleTimeout:                  old.IdleTimeout,
+		MaxHeaderBytes:               old.MaxHeaderBytes,
+		ConnState:                    old.ConnState,
+		ErrorLog:                     old.ErrorLog,
+		BaseContext:                  old.BaseContext,
+		ConnContext:                  old.ConnContext,
+		Protocols:                    old.Protocols,
+	}
+	if !s.tls {
+		// 不是 HTTPS 时 TLSConfig 是上次启动时配置 HTTP/2 填充的
+		s.srv.TLSConfig = nil
+	}
+	s.lis = nil
+	s.extraLis = nil
+	// 异常退出的可能只是 TCP listener，其他协议的服务器也要关闭之后重新监听
+	_ = s.closeProtocols()
+}
+
+// HTTPServer 返回名为 name 的 *Server，不存在或者不是 *Server 时返回 nil。
+// 用于 NewAppFromConfig 这类由配置创建服务器的场景，拿到服务器之后再注册路由
+func (a *App) HTTPServer(name string) *Server {
+	s, err := a.server(name)
+	if err != nil {
+		return nil
+	}
+	srv, _ := s.(*Server)
+	return srv
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApp_StopAndStartServer(t *testing.T) {
+	api := NewServer("api", freeAddr(t))
+	admin := NewServer("admin", freeAddr(t))
+	for _, s := range []*Server{api, admin} {
+		name := s.Name()
+		s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		})
+	}
+	app := NewApp([]*Server{api, admin}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Shutdown(context.Background()) }()
+	get := func(s *Server) (string, error) {
+		resp, err := http.Get("http://" + s.Addr())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	if err := app.StopServer(context.Background(), "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(admin); err == nil {
+		t.Fatal("admin 已经关闭，不应该再处理请求")
+	}
+	if body, err := get(api); err != nil || body != "api" {
+		t.Fatalf("其它服务器应该继续运行 %q %v", body, err)
+	}
+	if err := app.StartServer("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := get(admin); err != nil || body != "admin" {
+		t.Fatalf("admin 重新启动之后应该处理请求 %q %v", body, err)
+	}
+	if err := app.StopServer(context.Background(), "unknown"); !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("期望 ErrServerNotFound，实际 %v", err)
+	}
+}
+
+// crashServer 前 crashes 次处理请求时异常退出，之后一直运行到关闭
+type crashServer struct {
+	fakeServer
+	crashes int
+
+	mu      sync.Mutex
+	listens int
+	stopped chan struct{}
+}
+
+func (s *crashServer) listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listens++
+	return nil
+}
+
+func (s *crashServer) serve() error {
+	s.mu.Lock()
+	crash := s.listens <= s.crashes
+	s.mu.Unlock()
+	if crash {
+		return errors.New("crash")
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *crashServer) Stop(ctx context.Context) error {
+	close(s.stopped)
+	return nil
+}
+
+func (s *crashServer) listenCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listens
+}
+
+func TestWithServerRestartPolicy(t *testing.T) {
+	srv := &crashServer{fakeServer: fakeServer{name: "crash"}, crashes: 2, stopped: make(chan struct{})}
+	app := NewApp(nil, WithServers(srv), WithWaitTime(0), WithServerRestartPolicy(3, 10*time.Millisecond))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.listenCount() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := srv.listenCount(); n != 3 {
+		t.Fatalf("期望重启 2 次后恢复，实际监听 %d 次", n)
+	}
+	select {
+	case <-app.triggered:
+		t.Fatal("重启成功时不应该关闭应用")
+	default:
+	}
+	_ = app.Shutdown(context.Background())
+}
+
+func TestWithServerRestartPolicy_GiveUp(t *testing.T) {
+	srv := &crashServer{fakeServer: fakeServer{name: "crash"}, crashes: 100, stopped: make(chan struct{})}
+	app := NewApp(nil, WithServers(srv), WithSignals(), WithWaitTime(0), WithServerRestartPolicy(2, 10*time.Millisecond))
+	done := make(chan struct{})
+	go func() {
+		app.StartAndServe()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("重启次数用完之后应该关闭应用")
+	}
+	if n := srv.listenCount(); n != 3 {
+		t.Fatalf("期望重启 2 次，实际监听 %d 次", n)
+	}
+}
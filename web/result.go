@@ -0,0 +1,136 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShutdownResult 一次优雅退出各个阶段的耗时，以及哪些服务器、回调超时了，
+// 可以输出到日志或者上报监控
+type ShutdownResult struct {
+	// Total 整个优雅退出的耗时
+	Total time.Duration
+	// Prepare 执行准备函数以及注销服务的耗时
+	Prepare time.Duration
+	// Drain 等待正在执行的请求完结的耗时
+	Drain time.Duration
+	// DrainTimedOut 等待超时的时候还有请求没有处理完
+	DrainTimedOut bool
+	// Stop 关闭服务器的耗时，包括不摘流量的服务器
+	Stop time.Duration
+	// Callbacks 执行自定义回调的耗时
+	Callbacks time.Duration
+	// Close 释放资源的耗时
+	Close time.Duration
+	// Servers 每个服务器的关闭情况
+	Servers []ServerStopResult
+	// CallbackResults 每个回调的执行情况，和注册顺序一致。ctx 被取消跳过回调时为空
+	CallbackResults []CallbackResult
+	// Budget 每个步骤分配到的时间和实际耗时，见 WithShutdownBudget
+	Budget []BudgetStep
+}
+
+// ServerStopResult 单个服务器的关闭情况
+type ServerStopResult struct {
+	Name     string
+	Duration time.Duration
+	// Forced 优雅关闭超时之后被强制关闭
+	Forced bool
+	// CutConns WithConnDrain 到达 deadline 之后强制断开的连接数
+	CutConns int64
+	Err      error
+}
+
+// CallbackResult 单个回调的执行情况
+type CallbackResult struct {
+	// Index 回调在 WithShutdownCallbacks 中的位置，RegisterCallback 注册的回调按注册顺序排在后面
+	Index int
+	// Name 和 Phase 是 RegisterCallback 时指定的名称和阶段
+	Name     string
+	Phase    Phase
+	Duration time.Duration
+	// TimedOut 回调执行超过了超时时间
+	TimedOut bool
+	// Err 回调返回的错误或者 panic，超时时为 nil
+	Err error
+}
+
+// Clean 优雅退出是否顺利完成：请求都处理完了，没有服务器被强制关闭或者关闭失败，回调都没有超时
+func (r *ShutdownResult) Clean() bool {
+	if r.DrainTimedOut {
+		return false
+	}
+	for _, s := range r.Servers {
+		if s.Forced || s.Err != nil {
+			return false
+		}
+	}
+	for _, cb := range r.CallbackResults {
+		if cb.TimedOut || cb.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String 一行的摘要，例如
+// "优雅退出正常 总耗时 1.2s: 准备 10ms, 等待请求 1s, 关闭服务器 150ms, 回调 30ms, 释放资源 5ms"，
+// 不正常时在后面列出超时、强制关闭、关闭失败的服务器和超时的回调
+func (r *ShutdownResult) String() string {
+	var sb strings.Builder
+	if r.Clean() {
+		sb.WriteString("优雅退出正常")
+	} else {
+		sb.WriteString("优雅退出异常")
+	}
+	fmt.Fprintf(&sb, " 总耗时 %v: 准备 %v, 等待请求 %v", r.Total, r.Prepare, r.Drain)
+	if r.DrainTimedOut {
+		sb.WriteString("(超时)")
+	}
+	fmt.Fprintf(&sb, ", 关闭服务器 %v, 回调 %v, 释放资源 %v", r.Stop, r.Callbacks, r.Close)
+	var forced, failed, timedOut, cbFailed []string
+	for _, s := range r.Servers {
+		if s.Forced {
+			forced = append(forced, s.Name)
+		}
+		if s.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s(%v)", s.Name, s.Err))
+		}
+	}
+	for _, cb := range r.CallbackResults {
+		label := cb.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", cb.Index)
+		}
+		if cb.TimedOut {
+			timedOut = append(timedOut, label)
+		}
+		if cb.Err != nil {
+			cbFailed = append(cbFailed, label)
+		}
+	}
+	if len(forced) > 0 {
+		sb.WriteString("; 强制关闭: " + strings.Join(forced, ", "))
+	}
+	if len(failed) > 0 {
+		sb.WriteString("; 关闭失败: " + strings.Join(failed, ", "))
+	}
+	if len(timedOut) > 0 {
+		sb.WriteString("; 回调超时: " + strings.Join(timedOut, ", "))
+	}
+	if len(cbFailed) > 0 {
+		sb.WriteString("; 回调失败: " + strings.Join(cbFailed, ", "))
+	}
+	return sb.String()
+}
+
+// Result 返回优雅退出的各阶段耗时，在 Done 关闭之前调用返回 nil
+func (a *App) Result() *ShutdownResult {
+	select {
+	case <-a.done:
+		return a.result
+	default:
+		return nil
+	}
+}
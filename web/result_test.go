@@ -0,0 +1,38 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownResult_String(t *testing.T) {
+	testCases := []struct {
+		name string
+		res  ShutdownResult
+		want string
+	}{
+		{
+			name: "clean",
+			res: ShutdownResult{Total: 1200 * time.Millisecond, Prepare: 10 * time.Millisecond, Drain: time.Second,
+				Stop: 150 * time.Millisecond, Callbacks: 30 * time.Millisecond, Close: 5 * time.Millisecond,
+				Servers: []ServerStopResult{{Name: "api"}}},
+			want: "优雅退出正常 总耗时 1.2s: 准备 10ms, 等待请求 1s, 关闭服务器 150ms, 回调 30ms, 释放资源 5ms",
+		},
+		{
+			name: "forced",
+			res: ShutdownResult{Total: 2 * time.Second, Drain: time.Second, DrainTimedOut: true,
+				Servers:         []ServerStopResult{{Name: "api", Forced: true}, {Name: "ws", Err: errors.New("boom")}},
+				CallbackResults: []CallbackResult{{Index: 0}, {Index: 1, TimedOut: true}}},
+			want: "优雅退出异常 总耗时 2s: 准备 0s, 等待请求 1s(超时), 关闭服务器 0s, 回调 0s, 释放资源 0s" +
+				"; 强制关闭: api; 关闭失败: ws(boom); 回调超时: #1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.res.String(); got != tc.want {
+				t.Fatalf("期望 %q，实际 %q", tc.want, got)
+			}
+		})
+	}
+}
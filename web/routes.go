@@ -0,0 +1,204 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RouteInfo 注册的路由
+type RouteInfo struct {
+	// Method 为空表示匹配所有方法
+	Method string `json:"method,omitempty"`
+	// Pattern 不带方法的路由，可能带有 host，例如 "example.com/users/{id}"
+	Pattern string `json:"pattern"`
+}
+
+// WithAutoOptions 自动响应没有注册 OPTIONS 路由的 OPTIONS 请求，
+// 返回 204 以及列出该路径所有已注册方法的 Allow 头。
+// 路径匹配到不限制方法的路由（例如 "/files/"）时不自动响应，OPTIONS 请求和其它方法一样交给这个路由处理。
+// HEAD 请求由 http.ServeMux 自动交给 GET 路由处理，并且不会返回响应体
+func WithAutoOptions() ServerOption {
+	return func(s *Server) {
+		s.mux.autoOptions = true
+	}
+}
+
+// WithNotFoundHandler 没有路由匹配请求路径时使用 h 响应，默认返回 http.NotFound
+func WithNotFoundHandler(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.mux.notFound = h
+	}
+}
+
+// WithMethodNotAllowedHandler 路径存在但是方法不匹配时使用 h 响应，调用 h 之前已经设置好 Allow 头，
+// 默认返回 405 和状态码对应的文本
+func WithMethodNotAllowedHandler(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.mux.methodNotAllowed = h
+	}
+}
+
+// GET 注册 GET 路由，同时处理 HEAD 请求。pattern 不带方法，可以带有路径参数，例如 "/users/{id}"，
+// 在 handler 中通过 PathParam 读取。和 Handle 注册的路由一样经过中间件和优雅退出的处理
+func (s *Server) GET(pattern string, handler http.HandlerFunc) *Server {
+	return s.Handle(http.MethodGet+" "+pattern, handler)
+}
+
+// POST 注册 POST 路由，见 GET
+func (s *Server) POST(pattern string, handler http.HandlerFunc) *Server {
+	return s.Handle(http.MethodPost+" "+pattern, handler)
+}
+
+// PUT 注册 PUT 路由，见 GET
+func (s *Server) PUT(pattern string, handler http.HandlerFunc) *Server {
+	return s.Handle(http.MethodPut+" "+pattern, handler)
+}
+
+// PATCH 注册 PATCH 路由，见 GET
+func (s *Server) PATCH(pattern string, handler http.HandlerFunc) *Server {
+	return s.Handle(http.MethodPatch+" "+pattern, handler)
+}
+
+// DELETE 注册 DELETE 路由，见 GET
+func (s *Server) DELETE(pattern string, handler http.HandlerFunc) *Server {
+	return s.Handle(http.MethodDelete+" "+pattern, handler)
+}
+
+// PathParam 返回路由中名为 name 的路径参数，例如路由 "/users/{id}" 中的 id，不存在时返回空字符串。
+// 等价于 r.PathValue
+func PathParam(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
+
+// Routes 返回服务器注册的所有路由，按注册顺序排列
+func (s *Server) Routes() []RouteInfo {
+	s.mux.routesMu.RLock()
+	defer s.mux.routesMu.RUnlock()
+	return append([]RouteInfo(nil), s.mux.routes...)
+}
+
+// Pattern 返回处理 r 的路由，例如 "GET /users/{id}"，没有匹配的路由时返回空字符串。
+// 用作指标、日志的标签时不会像原始路径那样产生大量不同的值
+func (s *Server) Pattern(r *http.Request) string {
+	_, pattern := s.mux.ServeMux.Handler(r)
+	return pattern
+}
+
+// RoutesHandler 以 JSON 格式返回 s 注册的所有路由，可以注册为 /debug/routes 这类管理接口
+func RoutesHandler(s *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Routes())
+	})
+}
+
+// HandleHost 注册只处理 Host 为 host 的请求的路由，host 不带端口，pattern 可以带方法，例如 "GET /users"。
+// 同一个路径同时有 host 路由和不带 host 的路由时，没有匹配到 host 的请求由不带 host 的路由处理，
+// host 为空时就注册为这样的默认路由。所有 host 的请求同样会在优雅退出时被拒绝
+func (s *Server) HandleHost(host, pattern string, handler http.Handler) *Server {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return s.Handle(host+pattern, handler)
+	}
+	return s.Handle(method+" "+host+strings.TrimLeft(path, " "), handler)
+}
+
+// Handle 注册路由并记录下来
+func (s *serverMux) Handle(pattern string, handler http.Handler) {
+	s.ServeMux.Handle(pattern, handler)
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	s.routesMu.Lock()
+	s.routes = append(s.routes, RouteInfo{Method: method, Pattern: strings.TrimLeft(path, " ")})
+	s.routesMu.Unlock()
+}
+
+// HandleFunc 注册路由并记录下来
+func (s *serverMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// route 路由请求，是中间件最里层的 handler。只查找一次路由，匹配到时仍然交给 ServeMux.ServeHTTP 处理，
+// 因为 ServeMux.Handler 不会设置 PathValue 需要的路径参数
+func (s *serverMux) route(w http.ResponseWriter, r *http.Request) {
+	_, pattern := s.ServeMux.Handler(r)
+	if s.autoOptions && r.Method == http.MethodOptions && !strings.HasPrefix(pattern, http.MethodOptions+" ") {
+		if allow := s.allowedMethods(r); len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	// 没有自定义的处理时 ServeMux 自己返回 404 或者带 Allow 头的 405
+	if pattern == "" && (s.autoOptions || s.methodNotAllowed != nil || s.notFound != nil) {
+		// 路径存在但是方法不匹配时返回 405，Allow 头和自动响应 OPTIONS 时保持一致
+		if allow := s.allowedMethods(r); len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			if s.methodNotAllowed != nil {
+				s.methodNotAllowed.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if s.notFound != nil {
+			s.notFound.ServeHTTP(w, r)
+			return
+		}
+	}
+	s.ServeMux.ServeHTTP(w, r)
+}
+
+// allowedMethods 返回能够匹配请求路径的所有方法，开启了 WithAutoOptions 时包括 OPTIONS。
+// 路径匹配到不限制方法的路由时返回 nil
+func (s *serverMux) allowedMethods(r *http.Request) []string {
+	s.routesMu.RLock()
+	methods := make(map[string]struct{}, len(s.routes))
+	for _, route := range s.routes {
+		methods[route.Method] = struct{}{}
+	}
+	s.routesMu.RUnlock()
+
+	allowed := make(map[string]struct{}, len(methods))
+	// 只改变方法，浅拷贝就够了
+	probe := new(http.Request)
+	*probe = *r
+	for method := range methods {
+		probe.Method = method
+		if method == "" {
+			// 不限制方法的路由
+			probe.Method = http.MethodGet
+		}
+		_, pattern := s.ServeMux.Handler(probe)
+		if pattern == "" {
+			continue
+		}
+		if method == "" && strings.Contains(pattern, " ") {
+			continue
+		}
+		if method == "" {
+			// 不限制方法的路由接受任何方法，列出来的方法总是不完整，所以不生成 Allow
+			return nil
+		}
+		allowed[method] = struct{}{}
+		if method == http.MethodGet {
+			allowed[http.MethodHead] = struct{}{}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	if s.autoOptions {
+		allowed[http.MethodOptions] = struct{}{}
+	}
+	res := make([]string, 0, len(allowed))
+	for m := range allowed {
+		res = append(res, m)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithAutoOptions(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithAutoOptions())
+	s.HandleFunc("GET /thing", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("thing"))
+	})
+	s.HandleFunc("POST /thing", func(w http.ResponseWriter, r *http.Request) {})
+	s.HandleFunc("OPTIONS /custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/thing", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("期望 204，实际 %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Allow"), "GET, HEAD, OPTIONS, POST"; got != want {
+		t.Fatalf("期望 Allow %q，实际 %q", want, got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/custom", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("注册了 OPTIONS 路由时应该交给它处理，实际 %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/thing", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HEAD 应该交给 GET 路由处理，实际 %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("没有注册的路径期望 404，实际 %d", rec.Code)
+	}
+
+	s.HandleFunc("/any", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method))
+	})
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/any", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != http.MethodOptions || rec.Header().Get("Allow") != "" {
+		t.Fatalf("不限制方法的路由应该自己处理 OPTIONS，实际 %d %q Allow=%q", rec.Code, rec.Body, rec.Header().Get("Allow"))
+	}
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      []ServerOption
+		wantAllow string
+	}{
+		{name: "default", wantAllow: "GET, HEAD, POST"},
+		{name: "auto options", opts: []ServerOption{WithAutoOptions()}, wantAllow: "GET, HEAD, OPTIONS, POST"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer("test", "localhost:0", tc.opts...)
+			s.HandleFunc("GET /thing", func(w http.ResponseWriter, r *http.Request) {})
+			s.HandleFunc("POST /thing", func(w http.ResponseWriter, r *http.Request) {})
+			rec := httptest.NewRecorder()
+			s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/thing", nil))
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("期望 405，实际 %d", rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != tc.wantAllow {
+				t.Fatalf("期望 Allow %q，实际 %q", tc.wantAllow, got)
+			}
+			rec = httptest.NewRecorder()
+			s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/missing", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("没有注册的路径期望 404，实际 %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestServer_Routes(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	s.Group("/admin").HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {})
+	s.Handle("/debug/routes", RoutesHandler(s))
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Pattern: "/users/{id}"},
+		{Method: http.MethodPost, Pattern: "/admin/reload"},
+		{Pattern: "/debug/routes"},
+	}
+	if got := s.Routes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("期望 %v，实际 %v", want, got)
+	}
+
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	var got []RouteInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("接口返回的路由错误 %v", got)
+	}
+}
+
+func TestServer_HandleHost(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	reply := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	s.HandleHost("example.com", "GET /", reply("example"))
+	s.HandleHost("api.example.com", "/", reply("api"))
+	s.HandleHost("", "GET /", reply("default"))
+
+	testCases := []struct {
+		host     string
+		wantBody string
+	}{
+		{host: "example.com", wantBody: "example"},
+		{host: "api.example.com:8080", wantBody: "api"},
+		{host: "other.com", wantBody: "default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tc.host
+			rec := httptest.NewRecorder()
+			s.mux.ServeHTTP(rec, req)
+			if rec.Body.String() != tc.wantBody {
+				t.Fatalf("期望 %q，实际 %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+
+	s.mux.startReject()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "api.example.com"
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("拒绝新请求时所有 host 都应该返回 503，实际 %d", rec.Code)
+	}
+}
+
+func TestServer_MethodHelpers(t *testing.T) {
+	s := NewServer("test", "localhost:0",
+		WithNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		})),
+		WithMethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"allow":"`+w.Header().Get("Allow")+`"}`, http.StatusMethodNotAllowed)
+		})))
+	s.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("get " + PathParam(r, "id")))
+	}).DELETE("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("delete " + PathParam(r, "id")))
+	})
+	testCases := []struct {
+		method, path string
+		wantCode     int
+		wantBody     string
+	}{
+		{method: http.MethodGet, path: "/users/42", wantCode: http.StatusOK, wantBody: "get 42"},
+		{method: http.MethodDelete, path: "/users/7", wantCode: http.StatusOK, wantBody: "delete 7"},
+		{method: http.MethodPost, path: "/users/7", wantCode: http.StatusMethodNotAllowed, wantBody: `{"allow":"DELETE, GET, HEAD"}` + "\n"},
+		{method: http.MethodGet, path: "/missing", wantCode: http.StatusNotFound, wantBody: `{"error":"not found"}` + "\n"},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.wantCode || rec.Body.String() != tc.wantBody {
+			t.Fatalf("%s %s 期望 %d %q，实际 %d %q", tc.method, tc.path, tc.wantCode, tc.wantBody, rec.Code, rec.Body.String())
+		}
+	}
+	if got := s.Routes(); len(got) != 2 || got[0] != (RouteInfo{Method: http.MethodGet, Pattern: "/users/{id}"}) {
+		t.Fatalf("辅助方法注册的路由应该被记录，实际 %v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+var (
+	// ErrForcedShutdown 优雅退出期间收到足够次数的信号，没有等优雅退出完成
+	ErrForcedShutdown = errors.New("web: 强制退出")
+	// ErrShutdownTimeout 优雅退出超过了整体超时时间
+	ErrShutdownTimeout = errors.New("web: 优雅退出超时")
+)
+
+// Run 启动所有服务器并等待退出信号，是 StartAndServe 不退出进程的版本，方便嵌入到更大的程序里或者测试。
+// 启动失败时直接返回错误，例如端口被占用；收到信号、ctx 被取消或者应用内部触发关闭时开始优雅退出，
+// 返回优雅退出的错误。再次收到足够次数的信号时返回 ErrForcedShutdown，
+// 超过整体超时时间时返回 ErrShutdownTimeout，这两种情况都不会调用 os.Exit
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(); err != nil {
+		return err
+	}
+	ch := make(chan os.Signal, a.forceQuitSignals+1)
+	if len(a.signals) > 0 {
+		a.notifier.Notify(ch, a.signals...)
+		defer a.notifier.Stop(ch)
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		a.logf("context 被取消")
+	case <-a.ctx.Done():
+		a.logf("应用 context 被取消")
+	case <-a.triggered:
+	}
+	shutdownCtx, cancel := context.WithCancel(context.WithoutCancel(a.ctx))
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- a.Shutdown(shutdownCtx)
+	}()
+	timer := a.clock.NewTimer(a.shutdownTimeout)
+	defer timer.Stop()
+	for received := 0; ; {
+		select {
+		case err := <-result:
+			return err
+		case <-ch:
+			received++
+			if received >= a.forceQuitSignals {
+				a.logf("强制退出")
+				return a.abortShutdown(cancel, result, ErrForcedShutdown)
+			}
+			a.logf("再收到%d次信号强制退出", a.forceQuitSignals-received)
+		case <-timer.C():
+			a.logf("超时强制退出")
+			return a.abortShutdown(cancel, result, ErrShutdownTimeout)
+		}
+	}
+}
+
+// abortShutdown 和 forceExit 一样取消优雅退出并执行最后的回调，只是返回 err 而不是退出进程
+func (a *App) abortShutdown(cancel context.CancelFunc, result <-chan error, err error) error {
+	cancel()
+	select {
+	case <-result:
+	case <-a.after(forceExitGrace):
+	}
+	a.runLastResort()
+	return err
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestApp_Run(t *testing.T) {
+	s := NewServer("test", freeAddr(t))
+	app := NewApp([]*Server{s}, WithDisableSignalHandling(), WithWaitTime(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.Run(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("正常退出应该返回 nil，实际 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx 取消之后应该退出")
+	}
+}
+
+func TestApp_RunListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	app := NewApp([]*Server{NewServer("test", lis.Addr().String())}, WithSignals())
+	if err := app.Run(context.Background()); err == nil {
+		t.Fatal("端口被占用时应该返回错误")
+	}
+}
+
+func TestApp_RunShutdownTimeout(t *testing.T) {
+	var exited bool
+	app := NewApp(nil, WithServers(&fakeServer{name: "slow", stopDelay: time.Second}), WithSignals(), WithWaitTime(0))
+	app.shutdownTimeout = 100 * time.Millisecond
+	app.exit = func(int) { exited = true }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := app.Run(ctx); !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("期望 ErrShutdownTimeout，实际 %v", err)
+	}
+	if exited {
+		t.Fatal("Run 不应该退出进程")
+	}
+}
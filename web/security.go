@@ -0,0 +1,59 @@
+package web
+
+import "net/http"
+
+// SecurityHeaderOptions 安全相关的响应头，字段为空时使用默认值，设置为 "-" 时不输出该响应头
+type SecurityHeaderOptions struct {
+	// HSTS Strict-Transport-Security，只在 TLS 连接上输出，
+	// 默认 "max-age=63072000; includeSubDomains"
+	HSTS string
+	// ContentTypeOptions X-Content-Type-Options，默认 "nosniff"
+	ContentTypeOptions string
+	// FrameOptions X-Frame-Options，默认 "DENY"
+	FrameOptions string
+	// ReferrerPolicy Referrer-Policy，默认 "strict-origin-when-cross-origin"
+	ReferrerPolicy string
+	// ContentSecurityPolicy Content-Security-Policy，默认 "default-src 'self'"
+	ContentSecurityPolicy string
+}
+
+// SecurityHeaders 给所有响应加上常用的安全响应头，handler 中可以覆盖
+func SecurityHeaders(opts SecurityHeaderOptions) Middleware {
+	hsts := headerValue(opts.HSTS, "max-age=63072000; includeSubDomains")
+	headers := make(map[string]string, 4)
+	for name, v := range map[string]string{
+		"X-Content-Type-Options":  headerValue(opts.ContentTypeOptions, "nosniff"),
+		"X-Frame-Options":         headerValue(opts.FrameOptions, "DENY"),
+		"Referrer-Policy":         headerValue(opts.ReferrerPolicy, "strict-origin-when-cross-origin"),
+		"Content-Security-Policy": headerValue(opts.ContentSecurityPolicy, "default-src 'self'"),
+	} {
+		if v != "" {
+			headers[name] = v
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for name, v := range headers {
+				h.Set(name, v)
+			}
+			// 明文连接上的 HSTS 会被浏览器忽略，而且可能被中间人篡改
+			if r.TLS != nil && hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headerValue 为空时返回默认值，"-" 表示不输出
+func headerValue(v, def string) string {
+	switch v {
+	case "":
+		return def
+	case "-":
+		return ""
+	default:
+		return v
+	}
+}
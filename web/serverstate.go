@@ -0,0 +1,62 @@
+package web
+
+// ServerState 服务器的运行状态
+type ServerState string
+
+const (
+	// ServerIdle 还没有启动
+	ServerIdle ServerState = "idle"
+	// ServerStarting 正在启动，还没有开始监听
+	ServerStarting ServerState = "starting"
+	// ServerRunning 已经开始监听，正在处理请求
+	ServerRunning ServerState = "running"
+	// ServerDraining 开始优雅退出，拒绝新请求并等待正在处理的请求结束
+	ServerDraining ServerState = "draining"
+	// ServerStopped 已经关闭
+	ServerStopped ServerState = "stopped"
+	// ServerFailed 启动失败，或者异常退出并且没有重启成功
+	ServerFailed ServerState = "failed"
+)
+
+// StateChangeFunc 服务器状态变化时的回调，name 是服务器名称
+type StateChangeFunc func(name string, old, new ServerState)
+
+// OnStateChange 注册服务器状态变化时的回调，所有服务器的状态变化按照发生的顺序依次通知。
+// 回调在状态切换的锁内同步执行，不能阻塞，也不能再调用会改变服务器状态的方法。
+// 需要在 Start 之前调用
+func (a *App) OnStateChange(fn StateChangeFunc) {
+	a.stateHooks = append(a.stateHooks, fn)
+}
+
+// ServerState 返回 srv 的运行状态，srv 不是这个 App 管理的服务器时返回 ServerIdle
+func (a *App) ServerState(srv ManagedServer) ServerState {
+	if st, ok := a.serverStates.Load(srv); ok {
+		return st.(ServerState)
+	}
+	return ServerIdle
+}
+
+// State 返回服务器的运行状态，没有交给 App 管理时一直是 ServerIdle
+func (s *Server) State() ServerState {
+	if st, ok := s.state.Load().(ServerState); ok {
+		return st
+	}
+	return ServerIdle
+}
+
+// setServerState 切换 srv 的状态，状态没有变化时不通知
+func (a *App) setServerState(srv ManagedServer, st ServerState) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	old := a.ServerState(srv)
+	if old == st {
+		return
+	}
+	a.serverStates.Store(srv, st)
+	if s, ok := srv.(*Server); ok {
+		s.state.Store(st)
+	}
+	for _, fn := range a.stateHooks {
+		fn(srv.Name(), old, st)
+	}
+}
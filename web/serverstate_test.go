@@ -0,0 +1,29 @@
+package web
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestApp_OnStateChange(t *testing.T) {
+	s := NewServer("api", "localhost:0")
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	var changes []string
+	app.OnStateChange(func(name string, old, new ServerState) {
+		changes = append(changes, name+":"+string(old)+"->"+string(new))
+	})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if s.State() != ServerRunning {
+		t.Fatalf("启动之后应该是 running，实际 %s", s.State())
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"api:idle->starting", "api:starting->running", "api:running->draining", "api:draining->stopped"}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("状态变化错误，期望 %v，实际 %v", want, changes)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build windows
+
+package web
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// RunService 以 Windows 服务的方式运行应用，收到服务控制管理器（SCM）的停止或关机事件时
+// 执行和收到信号时相同的优雅退出流程。
+// 不是以服务的方式运行时（例如在命令行中调试）退化为 StartAndServe
+func (a *App) RunService(name string) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		a.StartAndServe()
+		return a.Err()
+	}
+	return svc.Run(name, &serviceHandler{app: a})
+}
+
+// serviceHandler 把 SCM 的控制事件转换为应用的启动和优雅退出
+type serviceHandler struct {
+	app *App
+}
+
+func (h *serviceHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+	if err := h.app.Start(); err != nil {
+		h.app.logf("应用启动失败 %v", err)
+		return true, 1
+	}
+	s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				return h.shutdown(s)
+			}
+		case <-h.app.ctx.Done():
+			h.app.logf("应用 context 被取消")
+			return h.shutdown(s)
+		}
+	}
+}
+
+// shutdown 优雅退出，超时时间告诉 SCM 避免被提前结束进程
+func (h *serviceHandler) shutdown(s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StopPending, WaitHint: uint32(h.app.shutdownTimeout / time.Millisecond)}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(h.app.ctx), h.app.shutdownTimeout)
+	defer cancel()
+	if err := h.app.Shutdown(ctx); err != nil {
+		h.app.logf("优雅退出失败 %v", err)
+		return true, 2
+	}
+	return false, 0
+}
@@ -2,12 +2,22 @@ package web
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 type Option func(*App)
@@ -21,130 +31,1306 @@ func WithShutdownCallbacks(cbs ...ShutdownCallback) Option {
 	}
 }
 
+// WithWaitTime 设置优雅退出时等待已有请求处理完的时间
+func WithWaitTime(d time.Duration) Option {
+	return func(app *App) {
+		app.waitTime = d
+	}
+}
+
+// WithWaitTimeFunc 在开始优雅退出时才计算等待时间，每次优雅退出只调用一次，
+// 例如低峰期可以缩短等待。设置后优先于 WithWaitTime
+func WithWaitTimeFunc(fn func() time.Duration) Option {
+	return func(app *App) {
+		app.waitTimeFunc = fn
+	}
+}
+
+// WithShutdownConcurrency 限制优雅退出时关闭服务器、执行回调的并发 goroutine 数量。
+// n <= 0 时使用 GOMAXPROCS
+func WithShutdownConcurrency(n int) Option {
+	return func(app *App) {
+		if n <= 0 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		app.shutdownWorkers = n
+	}
+}
+
+// WithCallbacksBeforeStop 在关闭服务器之前执行回调。
+// 默认顺序：拒绝新请求 -> 等待已有请求 -> 关闭服务器 -> 执行回调 -> 释放资源；
+// 设置后变为：拒绝新请求 -> 等待已有请求 -> 执行回调 -> 关闭服务器 -> 释放资源，
+// 回调执行期间服务器仍然在运行（只拒绝新请求）
+func WithCallbacksBeforeStop() Option {
+	return func(app *App) {
+		app.callbacksBeforeStop = true
+	}
+}
+
+// WithSequentialCallbacks 按注册顺序依次执行回调，前一个回调返回（或者超时）之后才执行下一个，
+// 每个回调的超时时间不变。默认并发执行所有回调
+func WithSequentialCallbacks() Option {
+	return func(app *App) {
+		app.sequentialCallbacks = true
+	}
+}
+
+// WithShutdownJitter 开始优雅退出之前随机等待 [0, max) 的时间，
+// 避免滚动发布时所有副本同时注销、拒绝请求，对剩下的副本造成流量冲击。
+// 随机等待的时间会从优雅退出的整体超时时间中扣除，不会超出 shutdownTimeout
+func WithShutdownJitter(max time.Duration) Option {
+	return func(app *App) {
+		app.shutdownJitter = max
+	}
+}
+
+// WithStartupTimeout 限制服务器开始监听的时间，超时后 Start 返回 ErrStartupTimeout
+func WithStartupTimeout(d time.Duration) Option {
+	return func(app *App) {
+		app.startupTimeout = d
+	}
+}
+
+// WithSequentialStart 按照服务器的顺序依次启动，前一个服务器开始监听之后才启动下一个，
+// 用于服务器之间有启动依赖的场景，例如健康检查服务器要先于业务服务器启动。
+// 默认同时启动所有服务器
+func WithSequentialStart() Option {
+	return func(app *App) {
+		app.sequentialStart = true
+	}
+}
+
+// WithForceCloseGrace 等待已有请求之后，服务器还有 d 的时间优雅关闭，
+// 超时后直接关闭所有连接。只有强制关闭也失败时才依赖整体的超时强制退出
+func WithForceCloseGrace(d time.Duration) Option {
+	return func(app *App) {
+		app.forceCloseGrace = d
+	}
+}
+
+// WithSignals 设置触发优雅退出的信号，默认只有 SIGINT 和 SIGTERM。
+// 不传信号时 StartAndServe 不监听任何信号，只在根 context 被取消时退出，
+// 用于由 Coordinator 等外部统一处理信号的场景
+func WithSignals(sigs ...os.Signal) Option {
+	return func(app *App) {
+		app.signals = sigs
+	}
+}
+
+// WithDisableSignalHandling 不监听任何信号，等同于不传参数的 WithSignals。
+// 用于没有信号的环境（例如嵌入到其他程序中），通过 Shutdown 或者取消 Run 的 ctx 退出
+func WithDisableSignalHandling() Option {
+	return WithSignals()
+}
+
+// WithForceQuitSignalCount 优雅退出期间累计收到 n 次信号才强制退出，默认为 1。
+// 避免误按 Ctrl-C 打断正在正常进行的优雅退出
+func WithForceQuitSignalCount(n int) Option {
+	return func(app *App) {
+		if n > 0 {
+			app.forceQuitSignals = n
+		}
+	}
+}
+
+// WithLastResortCallback 注册强制退出（再次收到信号或者超时）时，在进程退出之前执行的回调，
+// 用于释放分布式锁、写崩溃标记这类必须尝试执行的操作。
+// fn 必须很快返回，最多等待 200 毫秒，panic 会被恢复
+func WithLastResortCallback(fn func()) Option {
+	return func(app *App) {
+		app.lastResort = fn
+	}
+}
+
+// PrepareShutdownFunc 准备关闭，返回时表示可以开始优雅退出
+type PrepareShutdownFunc func(ctx context.Context) error
+
+// WithPrepareShutdown 注册优雅退出最开始执行的准备函数，例如交接分布式锁。
+// 所有准备函数返回之后才会开始拒绝新请求，每个函数的超时时间和回调一致
+func WithPrepareShutdown(fns ...PrepareShutdownFunc) Option {
+	return func(app *App) {
+		app.prepares = append(app.prepares, fns...)
+	}
+}
+
+// BeforeStartFunc 在服务器启动之前执行，例如预热缓存、执行数据库迁移
+type BeforeStartFunc func(ctx context.Context) error
+
+// WithBeforeStart 注册启动前按顺序执行的钩子，任意一个返回错误都会中止启动，
+// 和 WithShutdownCallbacks 对应
+func WithBeforeStart(fns ...BeforeStartFunc) Option {
+	return func(app *App) {
+		app.beforeStart = append(app.beforeStart, fns...)
+	}
+}
+
+// WithContext 设置应用的根 context，其中的值（logger、配置等）对请求、
+// 优雅退出回调都可见。应用释放资源之前会取消根 context；
+// 传入的 ctx 被取消时也会触发优雅退出
+func WithContext(ctx context.Context) Option {
+	return func(app *App) {
+		app.ctx = ctx
+	}
+}
+
+// WithAppName 设置应用名称，会作为前缀输出到所有生命周期日志中
+func WithAppName(name string) Option {
+	return func(app *App) {
+		app.name = name
+	}
+}
+
+// WithVersion 设置应用版本，会作为前缀输出到所有生命周期日志中
+func WithVersion(version string) Option {
+	return func(app *App) {
+		app.version = version
+	}
+}
+
+// WithServerClosedFunc 自定义如何判断服务器 Start 返回的错误是正常关闭，
+// 例如自定义的 listener 关闭时返回的错误。默认 http.ErrServerClosed 和 net.ErrClosed 都是正常关闭
+func WithServerClosedFunc(fn func(err error) bool) Option {
+	return func(app *App) {
+		app.serverClosed = fn
+	}
+}
+
+// WithDrainProgress 等待已有请求期间，每次检查正在处理的请求数时调用 fn，
+// remaining 为剩余的请求数，elapsed 为已经等待的时间
+func WithDrainProgress(fn func(remaining int, elapsed time.Duration)) Option {
+	return func(app *App) {
+		app.drainProgress = fn
+	}
+}
+
+// WithServers 追加需要统一管理生命周期的服务器，可以是非 HTTP 的服务器
+func WithServers(servers ...ManagedServer) Option {
+	return func(app *App) {
+		app.servers = append(app.servers, servers...)
+	}
+}
+
+// ManagedServer 可以被 App 管理生命周期的服务器，
+// 例如 TCP 服务器，只要实现了启动和关闭即可复用同一套优雅退出逻辑
+type ManagedServer interface {
+	// Name 服务器名称，用于日志
+	Name() string
+	// Start 启动服务器，阻塞直到服务器关闭
+	Start() error
+	// Stop 优雅关闭服务器
+	Stop(ctx context.Context) error
+}
+
+// rejecter 支持在关闭前先拒绝新请求的服务器
+type rejecter interface {
+	rejectReq()
+	acceptReq()
+}
+
+// Rejecter 可以由其他包的 ManagedServer 实现，开始优雅退出时 App 先调用 Reject 停止接收新请求
+// （例如把 gRPC 健康检查设置成 NOT_SERVING），ResumeTraffic 或者 StartServer 时调用 Accept 恢复
+type Rejecter interface {
+	Reject()
+	Accept()
+}
+
+// rejectServer 让 s 停止接收新请求，s 不支持时什么都不做
+func rejectServer(s ManagedServer) {
+	switch r := s.(type) {
+	case rejecter:
+		r.rejectReq()
+	case Rejecter:
+		r.Reject()
+	}
+}
+
+// acceptServer 让 s 恢复接收新请求，s 不支持时什么都不做
+func acceptServer(s ManagedServer) {
+	switch r := s.(type) {
+	case rejecter:
+		r.acceptReq()
+	case Rejecter:
+		r.Accept()
+	}
+}
+
+// ErrStartupTimeout 服务器没有在 WithStartupTimeout 指定的时间内开始监听
+var ErrStartupTimeout = errors.New("web: 服务器启动超时")
+
+// listenServer 启动分为监听和处理请求两步的服务器，App 据此判断服务器是否已经启动成功
+type listenServer interface {
+	listen() error
+	serve() error
+}
+
+// inFlightCounter 可以统计正在处理的请求数的服务器
+type inFlightCounter interface {
+	InFlight() int64
+}
+
+// noDrainer 优雅退出时不摘流量的服务器
+type noDrainer interface {
+	noDrain() bool
+}
+
+// forceExitGrace 强制退出时，取消 ctx 之后等待回调停止的时间
+const forceExitGrace = 500 * time.Millisecond
+
+// lastResortTimeout 强制退出前等待 WithLastResortCallback 回调的时间
+const lastResortTimeout = 200 * time.Millisecond
+
+// noDrainStopTimeout 不摘流量的服务器关闭的超时时间
+const noDrainStopTimeout = time.Second
+
+// drainWindowSetter 需要知道等待已有请求的时间窗口的服务器
+type drainWindowSetter interface {
+	setDrainWindow(start time.Time, waitTime time.Duration)
+}
+
+// forceCloser 支持强制关闭所有连接的服务器
+type forceCloser interface {
+	forceClose() error
+}
+
+// ErrStopping 服务器已经开始关闭，无法再恢复流量
+var ErrStopping = errors.New("web: 服务器已经开始关闭")
+
 type App struct {
-	servers []*Server
+	servers []ManagedServer
+
+	// 应用名称和版本，用于日志
+	name    string
+	version string
 
 	// 优雅退出整个超时时间，默认30秒
 	shutdownTimeout time.Duration
 
 	// 优雅退出时候等待处理已有请求时间，默认10秒钟
 	waitTime time.Duration
+	// 动态计算等待时间，优先于 waitTime
+	waitTimeFunc func() time.Duration
+	// 至少等待的时间，即使已经没有正在处理的请求
+	minDrainTime time.Duration
+	// 长时间运行的请求的等待时间
+	longRunningWaitTime time.Duration
+	// 等待已有请求的进度回调
+	drainProgress func(remaining int, elapsed time.Duration)
 	// 自定义回调超时时间，默认三秒钟
 	cbTimeout time.Duration
+	// 服务器开始监听的超时时间，0 表示不限制
+	startupTimeout time.Duration
+	// 服务器优雅关闭的时间，超时后强制关闭，0 表示一直等待
+	forceCloseGrace time.Duration
 
 	cbs []ShutdownCallback
+	// 通过 RegisterCallback 注册的分阶段执行的回调
+	phased []callback
+	// 优雅退出前的准备函数
+	prepares []PrepareShutdownFunc
+	// 给每个 *Server 添加的中间件
+	serverMiddlewares []func(s *Server) Middleware
+	// 启动前必须满足的条件
+	preconditions []precondition
+	// 健康检查，以及持续失败时关闭应用的检查间隔和时间窗口
+	healthChecks    []healthCheck
+	healthInterval  time.Duration
+	unhealthyWindow time.Duration
+	// 是否给每个 *Server 注册 /healthz 和 /readyz
+	healthEndpoints bool
+	// 每个服务器的 ServerState，stateMu 保证状态切换和回调的顺序一致
+	serverStates sync.Map
+	stateMu      sync.Mutex
+	stateHooks   []StateChangeFunc
+	// WithGracefulUpgrade 开启的平滑升级，upgradeExe 和 upgradeArgs 为空时使用当前的可执行文件和参数
+	gracefulUpgrade bool
+	upgradeMu       sync.Mutex
+	upgradeExe      string
+	upgradeArgs     []string
+	// OnReload 注册的钩子，reloadMu 保证同一时间只执行一次
+	reloads       []ReloadFunc
+	reloadTimeout time.Duration
+	reloadMu      sync.Mutex
+	// 启动前执行的钩子
+	beforeStart []BeforeStartFunc
+	// 服务注册
+	registrars []Registrar
+	// 记录应用状态的文件
+	stateFile string
+	// 被创建时开始优雅退出的文件
+	triggerFile string
+	// 开始优雅退出的时间
+	shutdownStart time.Time
+	// 退出前等待满足的条件和检查间隔
+	exitBarrier     func(ctx context.Context) bool
+	barrierInterval time.Duration
+	// 应用的元数据，带在日志前缀中
+	metadata map[string]string
+	// 读取编排系统给的退出时间的环境变量
+	gracePeriodEnv string
+	// 关闭之后是否检查端口已经释放
+	portReleaseCheck bool
+
+	// 优雅退出时的并发数，0 表示不限制
+	shutdownWorkers int
+	// 是否在关闭服务器之前执行回调
+	callbacksBeforeStop bool
+	// 服务器异常退出时的重启次数和等待时间
+	restartRetries int
+	restartBackoff time.Duration
+	// 是否按顺序依次启动服务器
+	sequentialStart bool
+	// 是否按注册顺序依次执行回调
+	sequentialCallbacks bool
+	// 开始优雅退出之前随机等待的最长时间
+	shutdownJitter time.Duration
+
+	tracer Tracer
+	// 强制退出前执行的回调
+	lastResort func()
+	// 优雅退出期间收到多少次信号时强制退出
+	forceQuitSignals int
+	// 触发优雅退出的信号
+	signals []os.Signal
+
+	// 是否正在优雅退出
+	draining atomic.Bool
+	// 是否已经进入关闭服务器阶段
+	stopping atomic.Bool
+	// 是否已经开始优雅退出
+	shutdownStarted atomic.Bool
+
+	closers closers
+	// 应用关闭的最后一步执行的 Flusher
+	flushMu     sync.Mutex
+	flushers    []Flusher
+	flushWindow time.Duration
+	// 通过 Go 启动的后台 goroutine
+	goroutines goroutines
+	// 通过 TrackWorker 跟踪的 worker，workerCtx 在开始优雅退出时取消
+	workers       goroutines
+	workerCtx     context.Context
+	cancelWorkers context.CancelFunc
+	// AddWorker 添加的 worker，同样使用 workerCtx
+	workerMu       sync.Mutex
+	workerList     []*worker
+	workersStarted bool
+
+	// 应用的根 context，在 close 时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 优雅退出流程结束后关闭
+	done chan struct{}
+	// 应用内部决定关闭应用时关闭，例如服务器重启次数用完、健康检查持续失败
+	triggered   chan struct{}
+	triggerOnce sync.Once
+	// Start 返回时关闭，startErr 是 Start 的结果
+	started   chan struct{}
+	startOnce sync.Once
+	startErr  error
+	doneOnce  sync.Once
+	// 优雅退出过程中的错误
+	err error
+	// 优雅退出各阶段的耗时
+	result *ShutdownResult
+
+	// 为 nil 时使用标准库 log
+	logger *log.Logger
+	// WithLogger 设置的结构化日志，优先于 logger
+	slogger *slog.Logger
+
+	// OnPanic 注册的钩子，WithPanicShutdown 设置的次数，以及所有服务器 panic 的次数
+	panicHooks    []PanicHook
+	panicShutdown int64
+	panics        atomic.Int64
+
+	// 启动和优雅退出使用的时钟，以及接收信号的方式，测试时可以替换
+	clock    Clock
+	notifier SignalNotifier
+
+	// WithShutdownBudget 设置的各个步骤的权重
+	budgetWeights map[string]float64
+
+	// 退出进程，测试时可以替换
+	exit func(code int)
+	// 判断服务器返回的错误是否是正常关闭
+	serverClosed func(err error) bool
 }
 
 func NewApp(servers []*Server, opts ...Option) *App {
 	res := &App{
-		waitTime:        10 * time.Second,
-		cbTimeout:       3 * time.Second,
-		shutdownTimeout: 30 * time.Second,
-		servers:         servers,
+		waitTime:         10 * time.Second,
+		cbTimeout:        3 * time.Second,
+		shutdownTimeout:  30 * time.Second,
+		forceQuitSignals: 1,
+		tracer:           nopTracer{},
+		signals:          signals,
+		ctx:              context.Background(),
+		done:             make(chan struct{}),
+		triggered:        make(chan struct{}),
+		started:          make(chan struct{}),
+		result:           &ShutdownResult{},
+		exit:             os.Exit,
+		serverClosed:     IsServerClosed,
+		clock:            realClock{},
+		notifier:         osSignals{},
+	}
+	for _, s := range servers {
+		res.servers = append(res.servers, s)
 	}
 	for _, opt := range opts {
 		opt(res)
 	}
-
+	res.fitGracePeriod()
+	for _, s := range res.servers {
+		if srv, ok := s.(*Server); ok {
+			srv.inheritMetadata(res.metadata)
+		}
+	}
+	res.ctx, res.cancel = context.WithCancel(res.ctx)
+	res.workerCtx, res.cancelWorkers = context.WithCancel(res.ctx)
+	for _, fn := range res.serverMiddlewares {
+		for _, s := range res.servers {
+			if srv, ok := s.(*Server); ok {
+				srv.Use(fn(srv))
+			}
+		}
+	}
+	for _, s := range res.servers {
+		if srv, ok := s.(*Server); ok {
+			srv.mux.clock = res.clock
+		}
+		if srv, ok := s.(*Server); ok && srv.mux.onPanic == nil {
+			srv.mux.onPanic = func(r *http.Request, rec any, stack []byte) {
+				res.handlePanic(srv, r, rec, stack)
+			}
+		}
+	}
+	res.installProbes()
+	// 请求的 context 继承应用 context 中的值，但不会因为应用 context 取消而被取消
+	for _, s := range res.servers {
+		if srv, ok := s.(*Server); ok && srv.srv.BaseContext == nil {
+			srv.srv.BaseContext = func(net.Listener) context.Context {
+				return context.WithoutCancel(res.ctx)
+			}
+		}
+	}
 	return res
 }
 
+// Start 启动所有服务器，等到所有服务器都开始监听之后返回。
+// 设置了 WithStartupTimeout 时，超时还没有监听成功会关闭已经启动的服务器并返回 ErrStartupTimeout
+// 启动前会先检查 AddPrecondition 注册的条件，再执行 WithBeforeStart 注册的钩子，失败时不会启动任何服务器
+// 所有服务器开始监听之后通过 WithRegistrar 注册服务，注册失败时同样会关闭服务器并返回错误
+func (a *App) Start() (err error) {
+	defer func() {
+		a.markStarted(err)
+	}()
+	if err := a.checkPreconditions(); err != nil {
+		return err
+	}
+	for _, fn := range a.beforeStart {
+		if err := fn(a.ctx); err != nil {
+			return fmt.Errorf("web: 启动前钩子执行失败: %w", err)
+		}
+	}
+	var timeout <-chan time.Time
+	if a.startupTimeout > 0 {
+		timer := a.clock.NewTimer(a.startupTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	listened := make(chan error, len(a.servers))
+	var errs []error
+	if a.sequentialStart {
+		// 前一个服务器开始监听之后才启动下一个，启动失败时不再启动后面的服务器
+		for _, s := range a.servers {
+			go a.runServer(s, listened)
+			if errs = waitListened(listened, 1, timeout); len(errs) > 0 {
+				break
+			}
+		}
+	} else {
+		for _, s := range a.servers {
+			go a.runServer(s, listened)
+		}
+		errs = waitListened(listened, len(a.servers), timeout)
+	}
+	if len(errs) == 0 {
+		if err := a.register(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		// 关闭已经启动的服务器，避免应用处于半启动状态
+		ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		defer cancel()
+		for _, srv := range a.servers {
+			_ = srv.Stop(ctx)
+			if a.ServerState(srv) != ServerFailed {
+				a.setServerState(srv, ServerStopped)
+			}
+		}
+		return err
+	}
+	a.writeState(stateReady)
+	a.startHealthWatch()
+	a.startTriggerFileWatch()
+	a.startCertWatch()
+	a.startReloadWatch()
+	a.startUpgradeWatch()
+	a.startWorkers()
+	a.notifyUpgradeReady()
+	return nil
+}
+
+// waitListened 等待 n 个服务器开始监听或者监听失败，超时后返回 ErrStartupTimeout
+func waitListened(listened <-chan error, n int, timeout <-chan time.Time) []error {
+	var errs []error
+	for range n {
+		select {
+		case err := <-listened:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-timeout:
+			return append(errs, ErrStartupTimeout)
+		}
+	}
+	return errs
+}
+
+// IsServerClosed 判断服务器启动返回的错误是否是因为正常关闭，
+// http.ErrServerClosed 和 net.ErrClosed 都认为是正常关闭
+func IsServerClosed(err error) bool {
+	return errors.Is(err, http.ErrServerClosed) || errors.Is(err, net.ErrClosed)
+}
+
+func (a *App) isServerClosed(err error) bool {
+	return a.serverClosed(err)
+}
+
 func (a *App) StartAndServe() {
 	// 启动所有服务器
-	for _, s := range a.servers {
-		srv := s
-		go func() {
-			if err := srv.Start(); err != nil {
-				log.Printf("服务器%s已关闭", srv.name)
-			} else {
-				log.Printf("服务器%s异常退出", srv.name)
-			}
-		}()
+	if err := a.Start(); err != nil {
+		a.logf("应用启动失败 %v", err)
+		return
 	}
 	// 定义要监听的目标信号 signals []os.Signal
 	// 调用 signal
 	// 当接收到一个退出信号后，会启动后面的 goroutine以及执行 a.web()
-	// goroutine 会监听第二个信号，如果超时则强制退出，或者再次接收到信号退出
-	ch := make(chan os.Signal, 2)
-	signal.Notify(ch, signals...)
-	<-ch
-	go func() {
+	// goroutine 会继续监听信号，如果超时或者再次收到足够次数的信号则强制退出
+	ch := make(chan os.Signal, a.forceQuitSignals+1)
+	// signal.Notify 不传信号时会监听所有信号，所以没有配置信号时不注册
+	if len(a.signals) > 0 {
+		a.notifier.Notify(ch, a.signals...)
+		// 退出时取消信号监听，同一进程内再次启动新的 App 不会受影响
+		defer a.notifier.Stop(ch)
+	}
+	select {
+	case <-ch:
+	case <-a.ctx.Done():
+		a.logf("应用 context 被取消")
+	case <-a.triggered:
+	}
+	// 强制退出时先取消 ctx，让正在执行的回调有机会感知并停止
+	ctx, cancel := context.WithCancel(context.WithoutCancel(a.ctx))
+	defer cancel()
+	// 优雅退出完成后通知 goroutine 退出，避免泄露
+	done := make(chan struct{})
+	defer close(done)
+	go a.watchForceExit(ch, cancel, done)
+	// 优雅退出
+	_ = a.Shutdown(ctx)
+}
+
+// watchForceExit 优雅退出期间收到 forceQuitSignals 次信号或者超时的时候强制退出
+func (a *App) watchForceExit(ch <-chan os.Signal, cancel context.CancelFunc, done <-chan struct{}) {
+	timer := a.clock.NewTimer(a.shutdownTimeout)
+	defer timer.Stop()
+	for received := 0; ; {
 		select {
 		case <-ch:
-			log.Println("强制退出")
-			os.Exit(1)
-		case <-time.After(a.shutdownTimeout):
-			log.Println("超时强制退出")
-			os.Exit(1)
+			received++
+			if received >= a.forceQuitSignals {
+				a.logf("强制退出")
+				a.forceExit(cancel, done)
+				return
+			}
+			a.logf("再收到%d次信号强制退出", a.forceQuitSignals-received)
+		case <-timer.C():
+			a.logf("超时强制退出")
+			a.forceExit(cancel, done)
+			return
+		case <-done:
+			return
 		}
+	}
+}
+
+// forceExit 取消优雅退出的 ctx，最多等待 forceExitGrace 之后退出进程
+func (a *App) forceExit(cancel context.CancelFunc, done <-chan struct{}) {
+	cancel()
+	select {
+	case <-done:
+	case <-a.after(forceExitGrace):
+	}
+	a.runLastResort()
+	a.exit(1)
+}
+
+// runLastResort 执行强制退出前的最后一个回调，最多等待 lastResortTimeout，panic 也不影响退出
+func (a *App) runLastResort() {
+	if a.lastResort == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				a.logf("强制退出前的回调 panic: %v", r)
+			}
+		}()
+		a.lastResort()
 	}()
-	// 优雅退出
-	a.shutdown()
+	select {
+	case <-done:
+	case <-a.after(lastResortTimeout):
+		a.logf("强制退出前的回调超时")
+	}
 }
 
-func (a *App) shutdown() {
-	log.Println("开始关闭应用，停止接收新请求")
-	for _, s := range a.servers {
+func (a *App) shutdown(ctx context.Context) {
+	ctx, end := a.tracer.Start(ctx, "shutdown")
+	var errs []error
+	start := a.clock.Now()
+	a.shutdownStart = start
+	a.logEvent("shutdown_start", nil, "开始优雅退出")
+	// WithWaitTimeFunc 只调用一次，时间预算、随机等待和摘流量使用同一个等待时间
+	waitTime := a.drainTime()
+	budget, cancel := a.newShutdownBudget(ctx, waitTime)
+	defer cancel()
+	ctx = budget.ctx
+	defer func() {
+		a.result.Total = a.since(start)
+		a.result.Budget = budget.steps
+		a.logEvent("shutdown_budget", []any{"budget", a.shutdownTimeout, "used", a.result.Total, "steps", budget.String()},
+			"优雅退出时间预算 %v，已用 %v: %s", a.shutdownTimeout, a.result.Total.Round(time.Millisecond), budget)
+		err := errors.Join(errs...)
+		end(err)
+		a.finish(err)
+	}()
+	// step 在分配给 name 的时间内执行 fn
+	step := func(name string, fn func(ctx context.Context)) {
+		stepCtx, done := budget.start(name)
+		defer done()
+		fn(stepCtx)
+	}
+	drained, last := a.partitionServers()
+	for _, s := range drained {
+		if p, ok := s.(preDrainer); ok {
+			p.startPreDrain()
+		}
+	}
+	step("jitter", func(ctx context.Context) {
+		a.jitter(ctx, waitTime)
+	})
+	step("prepare", a.prepareShutdown)
+	// 注册中心停止路由流量之后再拒绝新请求
+	step("deregister", func(ctx context.Context) {
+		errs = append(errs, a.deregister(ctx))
+	})
+	a.result.Prepare = a.since(start)
+	a.logEvent("drain_start", nil, "开始关闭应用，停止接收新请求")
+	a.draining.Store(true)
+	a.writeState(stateDraining)
+	a.cancelWorkers()
+	drainStart := a.clock.Now()
+	for _, s := range drained {
+		if dw, ok := s.(drainWindowSetter); ok {
+			dw.setDrainWindow(drainStart, waitTime)
+		}
 		// 停止接收新请求
-		s.rejectReq()
+		rejectServer(s)
+		a.setServerState(s, ServerDraining)
+	}
+	a.logf("等待正在执行请求完结")
+	step("drain", func(ctx context.Context) {
+		_, endDrain := a.tracer.Start(ctx, "shutdown.drain")
+		// 请求都处理完之后立刻进入下一步，没有正在执行的请求时不需要等待
+		if a.waitDrained(ctx, drained, waitTime) {
+			a.logf("没有正在执行的请求")
+		} else {
+			a.result.DrainTimedOut = true
+			a.logStragglers(drained)
+		}
+		endDrain(nil)
+	})
+	a.result.Drain = a.since(drainStart)
+	a.logEvent("drain_done", []any{"duration", a.result.Drain, "timed_out", a.result.DrainTimedOut}, "摘流量结束，耗时 %v", a.result.Drain)
+
+	stop := func(ctx context.Context) {
+		errs = append(errs, a.stopServers(ctx, drained))
+	}
+	callbacks := func(ctx context.Context) {
+		errs = append(errs, a.runCallbacks(ctx))
+	}
+	if a.callbacksBeforeStop {
+		step("callbacks", callbacks)
+		step("stop_servers", stop)
+	} else {
+		step("stop_servers", stop)
+		step("callbacks", callbacks)
+	}
+	if len(last) > 0 {
+		// 不摘流量的服务器（例如 admin）一直服务到最后，只给很短的时间关闭
+		step("stop_last_servers", func(ctx context.Context) {
+			lastCtx, cancel := a.withTimeout(ctx, noDrainStopTimeout)
+			errs = append(errs, a.stopServers(lastCtx, last))
+			cancel()
+		})
+	}
+	step("wait_workers", func(ctx context.Context) {
+		errs = append(errs, a.waitWorkers(ctx))
+		if !a.workers.wait(ctx, a.clock, goroutineWaitTimeout) {
+			a.logf("等待 worker 退出超时")
+		}
+	})
+	a.logEvent("shutdown_done", nil, "应用关闭完成")
+	closeStart := a.clock.Now()
+	step("close", func(ctx context.Context) {
+		errs = append(errs, a.close(ctx))
+	})
+	a.writeState(stateStopped)
+	a.result.Close = a.since(closeStart)
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, context.Cause(ctx))
+	}
+}
+
+// runServer 启动 srv，开始监听或者监听失败之后把结果发送到 listened，然后一直处理请求直到关闭
+func (a *App) runServer(srv ManagedServer, listened chan<- error) {
+	serve := srv.Start
+	a.setServerState(srv, ServerStarting)
+	if ls, ok := srv.(listenServer); ok {
+		if err := ls.listen(); err != nil {
+			a.setServerState(srv, ServerFailed)
+			listened <- fmt.Errorf("web: 服务器%s监听失败: %w", srv.Name(), err)
+			return
+		}
+		serve = ls.serve
+	}
+	a.setServerState(srv, ServerRunning)
+	listened <- nil
+	err := serve()
+	for attempt := 1; err != nil && !a.isServerClosed(err) && attempt <= a.restartRetries; attempt++ {
+		backoff := a.restartBackoff << (attempt - 1)
+		a.logf("服务器%s异常退出 %v，%v 后第%d次重启", srv.Name(), err, backoff, attempt)
+		err = a.restartServer(srv, backoff)
+	}
+	if err == nil || a.isServerClosed(err) {
+		a.logEvent("server_closed", []any{"server", srv.Name()}, "服务器%s已关闭", srv.Name())
+		return
+	}
+	a.setServerState(srv, ServerFailed)
+	a.logEvent("server_failed", []any{"server", srv.Name(), "error", err}, "服务器%s异常退出 %v", srv.Name(), err)
+	if a.restartRetries > 0 {
+		a.logf("服务器%s重启%d次仍然失败，关闭应用", srv.Name(), a.restartRetries)
+		a.triggerShutdown()
+	}
+}
+
+// triggerShutdown 通知 StartAndServe 开始优雅退出
+func (a *App) triggerShutdown() {
+	a.triggerOnce.Do(func() {
+		close(a.triggered)
+	})
+}
+
+// Shutdown 优雅退出，在没有信号的环境中可以直接调用。
+// ctx 被取消时提前结束：强制关闭服务器、跳过还没有执行的回调，并返回 ctx.Err()。
+// 多次调用只会执行一次优雅退出，之后的调用等待第一次调用结束
+func (a *App) Shutdown(ctx context.Context) error {
+	if a.shutdownStarted.CompareAndSwap(false, true) {
+		a.shutdown(ctx)
+	} else {
+		select {
+		case <-a.done:
+		case <-ctx.Done():
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Err()
+}
+
+// finish 标记整个优雅退出流程结束
+func (a *App) finish(err error) {
+	a.doneOnce.Do(func() {
+		a.err = err
+		close(a.done)
+	})
+}
+
+// Done 返回的 channel 在整个优雅退出流程（包括释放资源）结束后关闭
+func (a *App) Done() <-chan struct{} {
+	return a.done
+}
+
+// Err 返回优雅退出过程中的所有错误，在 Done 关闭之前调用返回 nil
+func (a *App) Err() error {
+	select {
+	case <-a.done:
+		return a.err
+	default:
+		return nil
+	}
+}
+
+// String 列出应用管理的所有服务器，用于日志输出
+func (a *App) String() string {
+	var sb strings.Builder
+	for i, s := range a.servers {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if str, ok := s.(fmt.Stringer); ok {
+			sb.WriteString(str.String())
+		} else {
+			sb.WriteString("[" + s.Name() + "]")
+		}
+	}
+	return sb.String()
+}
+
+// ResumeTraffic 撤销拒绝新请求，所有服务器恢复正常处理请求，
+// 用于开始摘流量后又决定保留实例（例如回滚）的场景。
+// 只有在还没有开始关闭服务器之前调用才有意义，否则返回 ErrStopping
+func (a *App) ResumeTraffic() error {
+	if a.stopping.Load() {
+		return ErrStopping
+	}
+	for _, s := range a.servers {
+		acceptServer(s)
+		if a.ServerState(s) == ServerDraining {
+			a.setServerState(s, ServerRunning)
+		}
+	}
+	a.draining.Store(false)
+	a.writeState(stateReady)
+	a.logf("恢复接收新请求")
+	return nil
+}
+
+// jitter 随机等待一段时间，保证剩下的时间仍然足够等待 waitTime 和执行回调
+func (a *App) jitter(ctx context.Context, waitTime time.Duration) {
+	limit := min(a.shutdownJitter, a.shutdownTimeout-waitTime-a.cbTimeout)
+	if limit <= 0 {
+		return
+	}
+	d := rand.N(limit)
+	a.logf("随机等待 %v 之后开始优雅退出", d)
+	timer := a.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
+	}
+}
+
+// prepareShutdown 在拒绝新请求之前等待所有准备函数返回
+func (a *App) prepareShutdown(ctx context.Context) {
+	if len(a.prepares) == 0 {
+		return
+	}
+	ctx, end := a.tracer.Start(ctx, "shutdown.prepare")
+	defer end(nil)
+	a.logf("等待准备关闭")
+	tasks := make([]func(), 0, len(a.prepares))
+	for _, p := range a.prepares {
+		fn := p
+		tasks = append(tasks, func() {
+			pCtx, cancel := a.withTimeout(ctx, a.cbTimeout)
+			defer cancel()
+			if err := fn(pCtx); err != nil {
+				a.logf("准备关闭失败 %v", err)
+			}
+		})
 	}
-	log.Println("等待正在执行请求完结")
-	// 这里可以改造为实时统计正在处理的请求数量，为0 则下一步
-	time.Sleep(a.waitTime)
+	runTasks(a.shutdownWorkers, tasks)
+}
+
+// partitionServers 区分需要摘流量的服务器和不摘流量、最后才关闭的服务器
+func (a *App) partitionServers() (drained, last []ManagedServer) {
+	for _, s := range a.servers {
+		if nd, ok := s.(noDrainer); ok && nd.noDrain() {
+			last = append(last, s)
+			continue
+		}
+		drained = append(drained, s)
+	}
+	return drained, last
+}
 
-	log.Println("开始关闭服务器")
+func (a *App) stopServers(ctx context.Context, servers []ManagedServer) error {
+	a.stopping.Store(true)
+	ctx, end := a.tracer.Start(ctx, "shutdown.stop_servers")
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	defer func() {
+		end(errors.Join(errs...))
+	}()
+	a.logf("开始关闭服务器")
+	start := a.clock.Now()
+	results := make([]ServerStopResult, len(servers))
 	// 采用并发关闭所有服务器
-	var wg sync.WaitGroup
-	wg.Add(len(a.servers))
-	for _, srv := range a.servers {
-		srvCp := srv
-		go func() {
-			if err := srvCp.stop(); err != nil {
-				log.Printf("关闭服务失败%s \n", srvCp.name)
+	stops := make([]func(), 0, len(servers))
+	for i, srv := range servers {
+		idx, srvCp := i, srv
+		stops = append(stops, func() {
+			a.logf("服务器%s关闭中", srvCp.Name())
+			stopCtx, endStop := a.tracer.Start(ctx, "shutdown.stop_server."+srvCp.Name())
+			stopStart := a.clock.Now()
+			forced, err := a.safeStopServer(stopCtx, srvCp)
+			elapsed := a.since(stopStart)
+			results[idx] = ServerStopResult{Name: srvCp.Name(), Duration: elapsed, Forced: forced, Err: err}
+			if c, ok := srvCp.(connCutter); ok {
+				if results[idx].CutConns = c.CutConns(); results[idx].CutConns > 0 {
+					a.logf("服务器%s强制断开了%d个连接", srvCp.Name(), results[idx].CutConns)
+				}
 			}
-			wg.Done()
-		}()
+			if err != nil {
+				a.logEvent("server_stop_failed", []any{"server", srvCp.Name(), "duration", elapsed, "error", err},
+					"关闭服务失败%s，耗时 %v", srvCp.Name(), elapsed)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("web: 关闭服务器%s失败: %w", srvCp.Name(), err))
+				mu.Unlock()
+			} else {
+				a.logEvent("server_stopped", []any{"server", srvCp.Name(), "duration", elapsed, "forced", forced},
+					"服务器%s关闭耗时 %v", srvCp.Name(), elapsed)
+			}
+			endStop(err)
+		})
 	}
-	wg.Wait()
-
-	log.Println("开始执行自定义回调")
-	// 执行回调
-	wg.Add(len(a.cbs))
-	for _, cb := range a.cbs {
-		c := cb
-		go func() {
-			// 控制回调超时
-			ctx, cancel := context.WithTimeout(context.Background(), a.cbTimeout)
-			c(ctx)
-			cancel()
-			wg.Done()
-		}()
+	runTasks(a.shutdownWorkers, stops)
+	a.result.Stop += a.since(start)
+	a.result.Servers = append(a.result.Servers, results...)
+	return errors.Join(errs...)
+}
+
+// runCallbacks 按阶段执行所有回调，返回失败和超时的回调的错误
+func (a *App) runCallbacks(ctx context.Context) (err error) {
+	ctx, end := a.tracer.Start(ctx, "shutdown.callbacks")
+	defer func() {
+		end(err)
+	}()
+	if ctx.Err() != nil {
+		a.logf("优雅退出被取消，跳过自定义回调")
+		return nil
+	}
+	a.logf("开始执行自定义回调")
+	start := a.clock.Now()
+	cbs := a.callbacks()
+	results := make([]CallbackResult, len(cbs))
+	workers := a.shutdownWorkers
+	if a.sequentialCallbacks {
+		workers = 1
+	}
+	for i, phase := range callbackPhases(cbs) {
+		if i > 0 && ctx.Err() != nil {
+			a.logf("优雅退出被取消，跳过剩下的回调")
+			break
+		}
+		tasks := make([]func(), 0, len(phase))
+		for _, idx := range phase {
+			tasks = append(tasks, func() {
+				results[idx] = a.runCallback(ctx, idx, cbs[idx])
+			})
+		}
+		runTasks(workers, tasks)
+	}
+	a.result.Callbacks = a.since(start)
+	a.result.CallbackResults = results
+	a.logEvent("callbacks_done", []any{"duration", a.result.Callbacks, "count", len(cbs)}, "自定义回调执行完成，耗时 %v", a.result.Callbacks)
+	var errs []error
+	for i, res := range results {
+		switch {
+		case res.Err != nil:
+			errs = append(errs, res.Err)
+		case res.TimedOut:
+			errs = append(errs, fmt.Errorf("%w: %s", ErrCallbackTimeout, cbs[i].label(i)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// safeStopServer 关闭服务器，关闭过程中 panic（例如自定义 listener 的 Close）会被转换为错误，
+// 避免整个优雅退出流程崩溃
+func (a *App) safeStopServer(ctx context.Context, srv ManagedServer) (forced bool, err error) {
+	defer func() {
+		a.setServerState(srv, ServerStopped)
+		if r := recover(); r != nil {
+			a.logf("关闭服务器%s时 panic: %v\n%s", srv.Name(), r, debug.Stack())
+			err = fmt.Errorf("web: 关闭服务器%s时 panic: %v", srv.Name(), r)
+		}
+	}()
+	return a.stopServer(ctx, srv)
+}
+
+// stopServer 优雅关闭服务器，设置了 WithForceCloseGrace 时超时后强制关闭
+// ctx 被取消时同样会强制关闭，forced 表示是否强制关闭了
+func (a *App) stopServer(ctx context.Context, srv ManagedServer) (forced bool, err error) {
+	policy := a.stopPolicy(srv)
+	stopCtx := ctx
+	if policy.graceful > 0 {
+		var cancel context.CancelFunc
+		stopCtx, cancel = a.withTimeout(ctx, policy.graceful)
+		defer cancel()
+	}
+	err = srv.Stop(stopCtx)
+	fc, ok := srv.(forceCloser)
+	if ok && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)) {
+		a.logf("服务器%s优雅关闭超时，强制关闭", srv.Name())
+		err = fc.forceClose()
+		a.waitForceClosed(ctx, srv, policy.forceClose)
+		return true, err
 	}
-	wg.Wait()
-	log.Println("应用关闭完成")
-	a.close()
+	return false, err
 }
 
-func (a *App) close() {
-	// 在这里释放掉一些可能的资源
-	time.Sleep(time.Second)
-	log.Println("应用关闭")
+// inFlight 服务器正在处理的请求数之和
+func inFlight(servers []ManagedServer) int64 {
+	var n int64
+	for _, s := range uniqueCounters(servers) {
+		if c, ok := s.(inFlightCounter); ok {
+			n += c.InFlight()
+		}
+	}
+	return n
+}
+
+// uniqueCounters 去掉和前面的服务器共用计数的服务器，见 NewServerSharingHandler
+func uniqueCounters(servers []ManagedServer) []ManagedServer {
+	res := make([]ManagedServer, 0, len(servers))
+	seen := make(map[*serverMux]bool, len(servers))
+	for _, s := range servers {
+		if srv, ok := s.(*Server); ok {
+			if seen[srv.mux] {
+				continue
+			}
+			seen[srv.mux] = true
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// drainTime 本次优雅退出等待已有请求的时间
+func (a *App) drainTime() time.Duration {
+	if a.waitTimeFunc != nil {
+		return a.waitTimeFunc()
+	}
+	return a.waitTime
+}
+
+func (a *App) close(ctx context.Context) error {
+	_, end := a.tracer.Start(ctx, "shutdown.close")
+	a.checkPortsReleased()
+	a.waitExitBarrier(ctx)
+	// 先通知后台 goroutine 退出，它们可能还在使用注册的资源
+	a.cancel()
+	if !a.goroutines.wait(ctx, a.clock, goroutineWaitTimeout) {
+		a.logf("等待后台 goroutine 退出超时")
+	}
+	// 释放通过 RegisterCloser 注册的资源
+	err := a.closers.close(a.logf)
+	// 最后发送缓冲的指标、trace
+	err = errors.Join(err, a.flush(context.WithoutCancel(ctx)))
+	end(err)
+	a.logf("应用关闭")
+	return err
 }
 
 type Server struct {
 	srv  *http.Server
 	name string
 	mux  *serverMux
+	// 为 nil 时使用 net/http 内置的 HTTP/2 配置
+	http2 *http2.Server
+	lis   net.Listener
+	// NewMultiAddrServer 创建的服务器除了 srv.Addr 之外还要监听的地址
+	extraAddrs []string
+	extraLis   []net.Listener
+	// WithUnixSocket 和 WithListener 添加的 listener
+	unixSockets []unixSocket
+	injected    []net.Listener
+	// WithProtocolServer 添加的其他协议的服务器，例如 HTTP/3
+	protocols []ProtocolServer
+	// 所有监听的地址，可以在其他 goroutine 中读取
+	listenerInfo atomic.Value
+	// 开启 WithAggressiveDrain 时记录空闲的连接
+	idle *idleConns
+	// WithConnDrain 记录的连接
+	conns *connTracker
+	// 实际监听的地址，可以在其他 goroutine 中读取
+	boundAddr atomic.Value
+	// 交给 App 管理之后的 ServerState
+	state atomic.Value
+	// 创建的 TCP 和 Unix socket listener 以及配置的地址，平滑升级时传给子进程
+	rawListeners []boundListener
+	// 单独设置的关闭策略，为 nil 时使用应用的配置
+	policy *drainPolicy
+	// 服务器的元数据
+	metadata map[string]string
+	// 创建 listener 的配置，以及创建之后对 listener、接受的连接的设置
+	listenConfig  net.ListenConfig
+	listenerHooks []func(*net.TCPListener) error
+	connHooks     []func(*net.TCPConn) error
+	// 优雅退出时是否不摘流量
+	skipDrain bool
+	// 是否提供 HTTPS 服务
+	tls bool
+	// WithCertFile 配置的证书，启动后定时重新加载
+	certs *certReloader
+	// 配置项中产生的错误，例如证书解析失败，在启动时返回
+	optErr error
+}
+
+// ServerOption 服务器配置项
+type ServerOption func(*Server)
+
+// WithRequestTimeout 为每个请求的 context 设置超时时间，handler 返回后即取消
+func WithRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.mux.requestTimeout = d
+	}
+}
+
+// WithRejectHandler 自定义优雅退出期间的拒绝逻辑，
+// 例如放行缓存类的 GET 请求、返回维护页面等。默认返回 503，内置的策略见 JSONRejectHandler。
+// 调用 h 之前已经根据剩余的等待时间设置好 Retry-After 头
+func WithRejectHandler(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.mux.rejectHandler = h
+	}
+}
+
+// WithMaxBodyBytes 限制服务器所有请求的请求体大小，见 MaxBodyBytes
+func WithMaxBodyBytes(n int64) ServerOption {
+	return func(s *Server) {
+		s.mux.wrap(MaxBodyBytes(n))
+	}
+}
+
+// WithNoDrain 优雅退出时服务器不拒绝新请求、不等待已有请求，
+// 一直服务到其它服务器关闭、回调执行完之后才关闭。适合 admin、metrics 这类服务器，
+// 保证在整个优雅退出过程中都能观察应用的状态
+func WithNoDrain() ServerOption {
+	return func(s *Server) {
+		s.skipDrain = true
+	}
 }
 
 type serverMux struct {
-	reject bool
+	reject atomic.Bool
 	*http.ServeMux
+	// 单个请求的超时时间，0 表示不限制
+	requestTimeout time.Duration
+	// 优雅退出期间被拒绝（返回 503）的请求数
+	rejected atomic.Int64
+	// 拒绝请求时使用的 handler
+	rejectHandler http.Handler
+	// 优雅退出期间仍然正常处理的路径前缀
+	drainExemptPaths []string
+	// 正在处理的 HTTP/2 和 HTTP/3 stream 数量
+	streams atomic.Int64
+	// 正在处理的请求数
+	inFlight atomic.Int64
+	// 判断请求是否是长时间运行的请求，例如上传下载
+	longRunning func(r *http.Request) bool
+	// 正在处理的长时间运行的请求数，包含在 inFlight 中
+	longInFlight atomic.Int64
+	// 路由外面包装了中间件之后的 handler
+	handler http.Handler
+	// 优雅退出时响应是否带上 Connection: close
+	closeOnDrain bool
+	// 正在处理的请求，开启 WithInFlightDebug 才会记录
+	registry *inFlightRegistry
+	// 等待已有请求结束的截止时间（UnixNano），0 表示没有开始优雅退出
+	drainEnd atomic.Int64
+	// clock 计算 Retry-After 使用的时钟，由 App 设置成和 drainEnd 一样的时钟
+	clock Clock
+
+	// WithMaxConcurrentRequests 和 WithRateLimit 的配置，以及被拒绝的请求数
+	maxConcurrent      int64
+	rateLimit          *tokenBucket
+	concurrencyLimited atomic.Int64
+	rateLimited        atomic.Int64
+
+	// handler panic 的次数，onPanic 由 App 设置，为 nil 时输出到标准库 log
+	panics  atomic.Int64
+	onPanic func(r *http.Request, recovered any, stack []byte)
+
+	// WithAccessLogSink 设置的访问日志，accessServer 是服务器名称
+	accessLog    AccessLogSink
+	accessServer string
+
+	// 维护模式下返回的响应，为 nil 时不在维护模式
+	maintenance atomic.Pointer[RejectResponse]
+	// 开始优雅退出之后、拒绝新请求之前额外经过的中间件
+	preDrainMiddleware Middleware
+	preDrain           atomic.Bool
+
+	// 开始拒绝新请求时关闭
+	drainMu sync.Mutex
+	drainCh chan struct{}
+	// WithDrainCancel 开启时，开始拒绝新请求 drainGrace 之后取消 drainCtx，请求的 context 跟着取消
+	cancelOnDrain bool
+	drainGrace    time.Duration
+	drainCtx      context.Context
+	drainCancel   context.CancelCauseFunc
+	drainTimer    *time.Timer
+
+	// 注册的路由
+	routesMu sync.RWMutex
+	routes   []RouteInfo
+	// 是否自动响应 OPTIONS 请求
+	autoOptions bool
+	// 没有匹配的路由、方法不匹配时使用的 handler，为 nil 时使用 net/http 的默认响应
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+	// WithHealthEndpoints 注册的探针，按路径匹配，Start 之后不再修改
+	probes map[string]http.Handler
+}
+
+func NewServer(name string, addr string, opts ...ServerOption) *Server {
+	mux := &serverMux{
+		ServeMux:      http.NewServeMux(),
+		rejectHandler: http.HandlerFunc(defaultRejectHandler),
+		drainCh:       make(chan struct{}),
+		clock:         realClock{},
+	}
+	mux.handler = http.HandlerFunc(mux.route)
+	return newServer(name, addr, mux, opts...)
+}
+
+// NewServerSharingHandler 创建和 base 共用路由以及拒绝标记的服务器，
+// 例如同一套路由同时监听 HTTP 和 HTTPS，任意一个开始拒绝新请求，另一个也会同时拒绝。
+// 注意修改路由相关配置的 ServerOption（例如 WithRejectHandler）会同时作用于两个服务器。
+// 请求相关的状态也是共用的：InFlight、RejectedCount、PanicCount 这些计数两个服务器返回同一个值，
+// 探针和 OnPanic 收到的服务器是先交给 App 的那个，访问日志中的服务器名称是最后设置 WithAccessLogSink 的那个。
+// App 等待请求结束时只统计一次，
+// 按服务器导出指标时需要注意不要重复相加
+func NewServerSharingHandler(name, addr string, base *Server, opts ...ServerOption) *Server {
+	return newServer(name, addr, base.mux, opts...)
 }
 
-func NewServer(name string, addr string) *Server {
-	mux := &serverMux{ServeMux: http.NewServeMux()}
-	return &Server{
+func newServer(name, addr string, mux *serverMux, opts ...ServerOption) *Server {
+	res := &Server{
 		name: name,
 		mux:  mux,
 		srv: &http.Server{
@@ -152,30 +1338,225 @@ func NewServer(name string, addr string) *Server {
 			Handler: mux,
 		},
 	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	// 配置 HTTP/2 时会给 srv 填充 TLSConfig，所以只能在这里判断是否启用了 HTTPS
+	res.tls = res.srv.TLSConfig != nil
+	return res
 }
 
 func (s *serverMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if s.reject {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		_, _ = w.Write([]byte("服务已关闭"))
+	if h, ok := s.probes[r.URL.Path]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), drainKey{}, s))
+	w, r, access := s.startAccessLog(w, r)
+	defer access.finish()
+	if s.closeOnDrain {
+		w = &drainWriter{ResponseWriter: w, mux: s}
+	}
+	if m := s.maintenance.Load(); m != nil && !m.exempt(r) {
+		access.reject("maintenance")
+		m.ServeHTTP(w, r)
+		return
+	}
+	if s.reject.Load() && !s.drainExempt(r) {
+		access.reject("draining")
+		if end := s.drainEnd.Load(); end > 0 {
+			w.Header().Set("Retry-After", retryAfter(time.Unix(0, end), s.clock.Now()))
+		}
+		rw := NewResponseWriter(w)
+		s.rejectHandler.ServeHTTP(rw, r)
+		// WithRejectHandler 放行的请求（例如返回缓存）不算被拒绝
+		if rw.Status() == http.StatusServiceUnavailable {
+			s.rejected.Add(1)
+		}
 		return
 	}
-	s.ServeMux.ServeHTTP(w, r)
+	if !s.admit(w, access) {
+		return
+	}
+	defer s.inFlight.Add(-1)
+	if s.longRunning != nil && s.longRunning(r) {
+		s.longInFlight.Add(1)
+		defer s.longInFlight.Add(-1)
+	}
+	if s.registry != nil {
+		defer s.registry.add(r)()
+	}
+	r, done := s.withDrainCancel(r)
+	defer done()
+	if r.ProtoMajor >= 2 {
+		s.streams.Add(1)
+		defer s.streams.Add(-1)
+	}
+	rw := NewResponseWriter(w)
+	defer s.recoverPanic(rw, r)
+	h := s.preDrainHandler(s.handler)
+	if s.requestTimeout > 0 {
+		TimeoutMiddleware(s.requestTimeout)(h).ServeHTTP(rw, r)
+		return
+	}
+	h.ServeHTTP(rw, r)
+}
+
+// wrap 在路由外面再包一层中间件
+func (s *serverMux) wrap(m Middleware) {
+	s.handler = m(s.handler)
 }
 
-func (s *Server) Handle(pattern string, handler http.Handler) {
+// Handle 注册路由，返回 Server 本身以便链式调用
+func (s *Server) Handle(pattern string, handler http.Handler) *Server {
 	s.mux.Handle(pattern, handler)
+	return s
+}
+
+// HandleFunc 注册路由，返回 Server 本身以便链式调用
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) *Server {
+	return s.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// RejectedCount 返回优雅退出期间被拒绝（返回 503）的请求数，WithRejectHandler 返回其它状态码的请求不计入。
+// 发布过程中这个值突然升高，说明摘流量的时间需要调整
+func (s *Server) RejectedCount() int64 {
+	return s.mux.rejected.Load()
+}
+
+// InFlight 返回正在处理的请求数
+func (s *Server) InFlight() int64 {
+	return s.mux.inFlight.Load()
 }
 
+// rejectReq 开始拒绝新请求。listener 会一直 Accept 到关闭服务器（srv.Shutdown）为止，
+// 这期间新建的连接、已经在 backlog 中排队的连接都会收到 503 而不是连接被重置。
+// 没有正在处理的请求时会立刻关闭服务器，需要给这些连接留出时间时配合 WithMinDrainTime
 func (s *Server) rejectReq() {
-	s.mux.reject = true
+	s.mux.startReject()
+	if s.idle != nil {
+		s.idle.closeAll()
+	}
+	if s.conns != nil {
+		s.srv.SetKeepAlivesEnabled(false)
+		s.conns.startDeadline()
+	}
+}
+
+func (s *Server) acceptReq() {
+	s.mux.stopReject()
+	s.mux.preDrain.Store(false)
+	s.mux.drainEnd.Store(0)
+	if s.conns != nil {
+		s.srv.SetKeepAlivesEnabled(true)
+		s.conns.stopDeadline()
+	}
+}
+
+func (s *Server) setDrainWindow(start time.Time, waitTime time.Duration) {
+	s.mux.drainEnd.Store(start.Add(waitTime).UnixNano())
 }
 
 func (s *Server) Start() error {
-	return s.srv.ListenAndServe()
+	if err := s.listen(); err != nil {
+		return err
+	}
+	return s.serve()
+}
+
+func (s *Server) noDrain() bool {
+	return s.skipDrain
+}
+
+func (s *Server) forceClose() error {
+	return errors.Join(s.srv.Close(), s.closeProtocols())
 }
 
-func (s *Server) stop() error {
-	log.Printf("服务器%s关闭中", s.name)
-	return s.srv.Shutdown(context.Background())
+func (s *Server) listen() error {
+	if err := s.listenHTTP(); err != nil {
+		return err
+	}
+	addrs, err := s.listenProtocols()
+	if err != nil {
+		s.closeAll()
+		return err
+	}
+	s.storeListeners(addrs)
+	return nil
+}
+
+// listenHTTP 创建 TCP、Unix socket 和注入的 listener
+func (s *Server) listenHTTP() error {
+	if s.optErr != nil {
+		return s.optErr
+	}
+	if err := s.configureHTTP2(); err != nil {
+		return err
+	}
+	s.rawListeners = nil
+	addr := s.srv.Addr
+	if addr == "" && s.hasExtraListeners() {
+		// 只监听 Unix socket 或者注入的 listener
+		s.lis = nil
+		if err := s.listenExtra(); err != nil {
+			return err
+		}
+		s.lis, s.extraLis = s.extraLis[0], s.extraLis[1:]
+		s.boundAddr.Store(s.lis.Addr())
+		return nil
+	}
+	if addr == "" {
+		addr = ":http"
+	}
+	lis, err := s.newListener(addr)
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+	s.boundAddr.Store(lis.Addr())
+	return s.listenExtra()
+}
+
+func (s *Server) serve() error {
+	if len(s.extraLis) > 0 || len(s.protocols) > 0 {
+		return s.serveAll()
+	}
+	return s.serveListener(s.lis)
+}
+
+func (s *Server) serveListener(lis net.Listener) error {
+	if s.tlsEnabled() {
+		// 证书已经在 TLSConfig 中配置好了
+		return s.srv.ServeTLS(lis, "", "")
+	}
+	return s.srv.Serve(lis)
+}
+
+// Name 服务器名称
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Addr 服务器配置的监听地址
+func (s *Server) Addr() string {
+	return s.srv.Addr
+}
+
+// String 用于日志输出，例如 "[api] :8080"
+func (s *Server) String() string {
+	return fmt.Sprintf("[%s] %s", s.name, strings.Join(s.Addrs(), ", "))
+}
+
+// Stop 优雅关闭服务器
+func (s *Server) Stop(ctx context.Context) error {
+	waitProtocols := s.shutdownProtocols(ctx)
+	err := s.srv.Shutdown(ctx)
+	if errors.Is(err, net.ErrClosed) {
+		// 平滑升级时 listener 已经提前关闭
+		err = nil
+	}
+	if s.conns != nil {
+		s.conns.waitHijacked(ctx)
+	}
+	return errors.Join(err, waitProtocols())
 }
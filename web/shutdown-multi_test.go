@@ -0,0 +1,866 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_RejectedCount(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	s.rejectReq()
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("期望 503，实际 %d", rec.Code)
+		}
+	}
+	if n := s.RejectedCount(); n != 3 {
+		t.Fatalf("期望拒绝 3 个请求，实际 %d", n)
+	}
+}
+
+func TestWithRejectHandler(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithRejectHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("cached"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	s.rejectReq()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "cached" {
+		t.Fatalf("GET 应该被放行，实际 %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("POST 应该被拒绝，实际 %d", rec.Code)
+	}
+	if n := s.RejectedCount(); n != 1 {
+		t.Fatalf("返回 200 的请求不应该计入被拒绝的请求，实际 %d", n)
+	}
+}
+
+type tcpServer struct {
+	stopped chan struct{}
+}
+
+func (s *tcpServer) Name() string { return "tcp" }
+
+func (s *tcpServer) Start() error {
+	<-s.stopped
+	return nil
+}
+
+func (s *tcpServer) Stop(ctx context.Context) error {
+	close(s.stopped)
+	return nil
+}
+
+// fakeServer 不监听端口的服务器，记录关闭的时间，可以模拟关闭慢或者关闭失败
+type fakeServer struct {
+	name      string
+	stopDelay time.Duration
+	stopErr   error
+
+	mu         sync.Mutex
+	stopStart  time.Time
+	stopFinish time.Time
+}
+
+func (s *fakeServer) Name() string { return s.name }
+
+func (s *fakeServer) Start() error { return nil }
+
+func (s *fakeServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopStart = time.Now()
+	s.mu.Unlock()
+	time.Sleep(s.stopDelay)
+	s.mu.Lock()
+	s.stopFinish = time.Now()
+	s.mu.Unlock()
+	return s.stopErr
+}
+
+func TestApp_StopServersAggregateErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	app := NewApp(nil, WithServers(
+		&fakeServer{name: "a", stopErr: errA},
+		&fakeServer{name: "ok"},
+		&fakeServer{name: "b", stopErr: errB},
+	))
+	err := app.stopServers(context.Background(), app.servers)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("期望包含所有服务器的错误，实际 %v", err)
+	}
+}
+
+func TestApp_StopServersConcurrently(t *testing.T) {
+	a := &fakeServer{name: "a", stopDelay: 100 * time.Millisecond}
+	b := &fakeServer{name: "b", stopDelay: 100 * time.Millisecond}
+	app := NewApp(nil, WithServers(a, b))
+	if err := app.stopServers(context.Background(), app.servers); err != nil {
+		t.Fatal(err)
+	}
+	// 两个服务器的关闭时间有重叠才说明是并发关闭的
+	if !a.stopStart.Before(b.stopFinish) || !b.stopStart.Before(a.stopFinish) {
+		t.Fatal("服务器应该并发关闭")
+	}
+}
+
+func TestApp_ManagedServer(t *testing.T) {
+	tcp := &tcpServer{stopped: make(chan struct{})}
+	app := NewApp(nil, WithServers(tcp))
+	app.waitTime = 0
+	app.shutdown(context.Background())
+	select {
+	case <-tcp.stopped:
+	default:
+		t.Fatal("非 HTTP 服务器没有被关闭")
+	}
+}
+
+func TestApp_ResumeTraffic(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	app := NewApp([]*Server{s})
+	s.rejectReq()
+	if err := app.ResumeTraffic(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("恢复后期望 200，实际 %d", rec.Code)
+	}
+	app.stopping.Store(true)
+	if err := app.ResumeTraffic(); err != ErrStopping {
+		t.Fatalf("期望 ErrStopping，实际 %v", err)
+	}
+}
+
+type slowServer struct {
+	tcpServer
+	release chan struct{}
+}
+
+func (s *slowServer) listen() error {
+	<-s.release
+	return nil
+}
+
+func (s *slowServer) serve() error {
+	return s.Start()
+}
+
+func TestApp_StartupTimeout(t *testing.T) {
+	slow := &slowServer{tcpServer: tcpServer{stopped: make(chan struct{})}, release: make(chan struct{})}
+	defer close(slow.release)
+	app := NewApp(nil, WithServers(slow), WithStartupTimeout(50*time.Millisecond))
+	if err := app.Start(); !errors.Is(err, ErrStartupTimeout) {
+		t.Fatalf("期望 ErrStartupTimeout，实际 %v", err)
+	}
+	select {
+	case <-slow.stopped:
+	default:
+		t.Fatal("启动超时后没有关闭服务器")
+	}
+}
+
+func TestApp_StartListenError(t *testing.T) {
+	s1 := NewServer("s1", "localhost:0")
+	app := NewApp([]*Server{s1, NewServer("bad", "256.0.0.1:80")})
+	if err := app.Start(); err == nil {
+		t.Fatal("期望监听失败")
+	}
+}
+
+// orderedServer 记录开始监听的顺序
+type orderedServer struct {
+	fakeServer
+	delay   time.Duration
+	err     error
+	mu      *sync.Mutex
+	started *[]string
+}
+
+func (s *orderedServer) listen() error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	*s.started = append(*s.started, s.name)
+	s.mu.Unlock()
+	return s.err
+}
+
+func (s *orderedServer) serve() error { return nil }
+
+func TestWithSequentialStart(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		started []string
+	)
+	newServer := func(name string, delay time.Duration, err error) *orderedServer {
+		return &orderedServer{fakeServer: fakeServer{name: name}, delay: delay, err: err, mu: &mu, started: &started}
+	}
+	app := NewApp(nil, WithSequentialStart(), WithServers(
+		newServer("health", 100*time.Millisecond, nil),
+		newServer("api", 0, nil),
+	))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(started, ","); got != "health,api" {
+		t.Fatalf("应该按顺序启动，实际 %s", got)
+	}
+
+	started = nil
+	app = NewApp(nil, WithSequentialStart(), WithServers(
+		newServer("health", 0, errors.New("listen failed")),
+		newServer("api", 0, nil),
+	))
+	if err := app.Start(); err == nil {
+		t.Fatal("期望启动失败")
+	}
+	if got := strings.Join(started, ","); got != "health" {
+		t.Fatalf("前一个服务器启动失败时不应该启动后面的服务器，实际 %s", got)
+	}
+}
+
+func TestWithBeforeStart(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	errMigrate := errors.New("migrate failed")
+	var calls []int
+	app := NewApp([]*Server{s}, WithBeforeStart(func(ctx context.Context) error {
+		calls = append(calls, 1)
+		return errMigrate
+	}, func(ctx context.Context) error {
+		calls = append(calls, 2)
+		return nil
+	}))
+	if err := app.Start(); !errors.Is(err, errMigrate) {
+		t.Fatalf("期望钩子返回的错误，实际 %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("钩子失败之后不应该继续执行 %v", calls)
+	}
+	if s.lis != nil {
+		t.Fatal("钩子失败时不应该启动服务器")
+	}
+}
+
+func TestIsDraining(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var draining bool
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		draining = IsDraining(r.Context())
+	})
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if draining {
+		t.Fatal("没有开始优雅退出")
+	}
+	s.mux.reject.Store(true)
+	s.mux.rejectHandler = s.mux.ServeMux
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !draining {
+		t.Fatal("期望正在优雅退出")
+	}
+	if IsDraining(context.Background()) {
+		t.Fatal("非请求 context 不应该处于优雅退出状态")
+	}
+}
+
+func TestApp_ForceCloseGrace(t *testing.T) {
+	s := NewServer("slow", "localhost:0")
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	app := NewApp([]*Server{s}, WithForceCloseGrace(50*time.Millisecond))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.Get("http://" + s.lis.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	forced, err := app.stopServer(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !forced {
+		t.Fatal("优雅关闭超时后应该强制关闭")
+	}
+}
+
+func TestApp_Result(t *testing.T) {
+	tcp := &tcpServer{stopped: make(chan struct{})}
+	app := NewApp(nil, WithServers(tcp), WithWaitTime(0), WithShutdownCallbacks(func(ctx context.Context) {
+	}, func(ctx context.Context) {
+		<-ctx.Done()
+	}))
+	app.cbTimeout = 50 * time.Millisecond
+	if app.Result() != nil {
+		t.Fatal("优雅退出结束之前不应该返回结果")
+	}
+	if err := app.Shutdown(context.Background()); !errors.Is(err, ErrCallbackTimeout) {
+		t.Fatalf("回调超时应该返回 ErrCallbackTimeout，实际 %v", err)
+	}
+	res := app.Result()
+	if len(res.Servers) != 1 || res.Servers[0].Name != "tcp" || res.Servers[0].Err != nil || res.Servers[0].Forced {
+		t.Fatalf("服务器关闭结果错误 %+v", res.Servers)
+	}
+	if len(res.CallbackResults) != 2 || res.CallbackResults[0].TimedOut || !res.CallbackResults[1].TimedOut {
+		t.Fatalf("回调结果错误 %+v", res.CallbackResults)
+	}
+	if res.Callbacks < app.cbTimeout || res.Total < res.Callbacks+res.Close {
+		t.Fatalf("阶段耗时错误 %+v", res)
+	}
+	if res.Close >= time.Second {
+		t.Fatalf("没有注册资源时释放资源不应该等待，实际 %v", res.Close)
+	}
+}
+
+func TestApp_PrepareShutdown(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var rejectedBeforePrepared bool
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithPrepareShutdown(func(ctx context.Context) error {
+		rejectedBeforePrepared = s.mux.reject.Load()
+		return nil
+	}))
+	app.shutdown(context.Background())
+	if rejectedBeforePrepared {
+		t.Fatal("准备函数应该在拒绝新请求之前执行")
+	}
+}
+
+func TestApp_String(t *testing.T) {
+	tcp := &tcpServer{stopped: make(chan struct{})}
+	app := NewApp([]*Server{NewServer("api", ":8080"), NewServer("admin", ":8081")}, WithServers(tcp))
+	if got, want := app.String(), "[api] :8080, [admin] :8081, [tcp]"; got != want {
+		t.Fatalf("期望 %q，实际 %q", want, got)
+	}
+}
+
+func TestApp_SkipDrainWhenIdle(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{s}, WithWaitTime(time.Minute))
+	start := time.Now()
+	app.shutdown(context.Background())
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("没有请求时不应该等待 waitTime")
+	}
+}
+
+func TestWithMinDrainTime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		minDrain time.Duration
+		waitTime time.Duration
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "min drain", minDrain: 300 * time.Millisecond, waitTime: time.Minute, wantMin: 300 * time.Millisecond, wantMax: 10 * time.Second},
+		{name: "capped by wait time", minDrain: time.Minute, waitTime: 200 * time.Millisecond, wantMin: 200 * time.Millisecond, wantMax: 10 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer("test", "localhost:0")
+			app := NewApp([]*Server{s}, WithWaitTime(tc.waitTime), WithMinDrainTime(tc.minDrain))
+			start := time.Now()
+			app.shutdown(context.Background())
+			if d := time.Since(start); d < tc.wantMin || d > tc.wantMax {
+				t.Fatalf("期望等待 %v 到 %v，实际 %v", tc.wantMin, tc.wantMax, d)
+			}
+		})
+	}
+}
+
+type ctxKey struct{}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "v"))
+	addr := freeAddr(t)
+	s := NewServer("test", addr)
+	var got any
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context().Value(ctxKey{})
+	})
+	var cbGot any
+	app := NewApp([]*Server{s}, WithContext(ctx), WithWaitTime(0),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			cbGot = ctx.Value(ctxKey{})
+		}))
+	done := make(chan struct{})
+	go func() {
+		app.StartAndServe()
+		close(done)
+	}()
+	var resp *http.Response
+	var err error
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Get("http://" + addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	cancel()
+	<-done
+	if got != "v" || cbGot != "v" {
+		t.Fatalf("context 中的值没有传递: %v %v", got, cbGot)
+	}
+	if app.ctx.Err() == nil {
+		t.Fatal("应用关闭后根 context 应该被取消")
+	}
+}
+
+// freeAddr 返回一个当前没有被占用的本地地址
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func TestNewServerSharingHandler(t *testing.T) {
+	base := NewServer("http", ":8080")
+	base.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	shared := NewServerSharingHandler("https", ":8443", base)
+	base.rejectReq()
+	rec := httptest.NewRecorder()
+	shared.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("共享的服务器也应该拒绝请求，实际 %d", rec.Code)
+	}
+}
+
+func TestNewServerSharingHandler_InFlight(t *testing.T) {
+	base := NewServer("http", "localhost:0")
+	started, release := make(chan struct{}), make(chan struct{})
+	base.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	shared := NewServerSharingHandler("https", "localhost:0", base)
+	go base.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+	defer close(release)
+	if n := inFlight([]ManagedServer{base, shared}); n != 1 {
+		t.Fatalf("共用路由的服务器只应该统计一次正在处理的请求，实际 %d", n)
+	}
+}
+
+func TestWithNoDrain(t *testing.T) {
+	public := NewServer("public", "localhost:0")
+	admin := NewServer("admin", "localhost:0", WithNoDrain())
+	var adminRejected bool
+	app := NewApp([]*Server{public, admin}, WithWaitTime(0), WithShutdownCallbacks(func(ctx context.Context) {
+		adminRejected = admin.mux.reject.Load()
+	}))
+	app.shutdown(context.Background())
+	if !public.mux.reject.Load() {
+		t.Fatal("public 服务器应该拒绝新请求")
+	}
+	if adminRejected {
+		t.Fatal("admin 服务器不应该拒绝新请求")
+	}
+}
+
+func TestApp_ForceExitCancelsCallbacks(t *testing.T) {
+	cbStarted := make(chan struct{})
+	cbCancelled := make(chan struct{})
+	exited := make(chan int, 1)
+	app := NewApp(nil, WithWaitTime(0), WithShutdownCallbacks(func(ctx context.Context) {
+		close(cbStarted)
+		<-ctx.Done()
+		close(cbCancelled)
+	}))
+	app.cbTimeout = time.Minute
+	app.exit = func(code int) {
+		exited <- code
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go app.shutdown(ctx)
+	<-cbStarted
+	go app.forceExit(cancel, done)
+	select {
+	case <-cbCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("强制退出时回调没有感知到 ctx 取消")
+	}
+	if code := <-exited; code != 1 {
+		t.Fatalf("期望退出码 1，实际 %d", code)
+	}
+}
+
+func TestWithForceQuitSignalCount(t *testing.T) {
+	exited := make(chan int, 1)
+	app := NewApp(nil, WithForceQuitSignalCount(3))
+	app.exit = func(code int) {
+		exited <- code
+	}
+	ch := make(chan os.Signal, 3)
+	done := make(chan struct{})
+	defer close(done)
+	go app.watchForceExit(ch, func() {}, done)
+	ch <- os.Interrupt
+	ch <- os.Interrupt
+	select {
+	case <-exited:
+		t.Fatal("没有达到次数不应该强制退出")
+	case <-time.After(50 * time.Millisecond):
+	}
+	ch <- os.Interrupt
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("收到 3 次信号之后应该强制退出")
+	}
+}
+
+func TestWithLastResortCallback(t *testing.T) {
+	exited := make(chan int, 1)
+	var called bool
+	app := NewApp(nil, WithLastResortCallback(func() {
+		called = true
+		panic("release lock panic")
+	}))
+	app.exit = func(code int) {
+		exited <- code
+	}
+	done := make(chan struct{})
+	close(done)
+	app.forceExit(func() {}, done)
+	if !called {
+		t.Fatal("强制退出前应该执行回调")
+	}
+	if code := <-exited; code != 1 {
+		t.Fatalf("回调 panic 之后仍然应该退出，退出码 %d", code)
+	}
+}
+
+func TestWithSequentialCallbacks(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		order   []int
+		running int
+		overlap bool
+	)
+	cbs := make([]ShutdownCallback, 0, 3)
+	for i := 0; i < 3; i++ {
+		idx := i
+		cbs = append(cbs, func(ctx context.Context) {
+			mu.Lock()
+			running++
+			overlap = overlap || running > 1
+			order = append(order, idx)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+	}
+	app := NewApp(nil, WithSequentialCallbacks(), WithShutdownCallbacks(cbs...))
+	app.runCallbacks(context.Background())
+	if overlap {
+		t.Fatal("回调不应该并发执行")
+	}
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("应该按注册顺序执行，实际 %v", order)
+	}
+}
+
+func TestApp_StuckCallback(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	app := NewApp(nil, WithShutdownCallbacks(func(ctx context.Context) {
+		// 不理会 ctx 的回调
+		<-release
+	}))
+	app.cbTimeout = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		app.runCallbacks(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("回调超时之后不应该继续等待")
+	}
+	if !app.result.CallbackResults[0].TimedOut {
+		t.Fatal("应该记录回调超时")
+	}
+}
+
+func TestWithShutdownJitter(t *testing.T) {
+	app := NewApp(nil, WithShutdownJitter(time.Hour), WithWaitTime(0))
+	app.shutdownTimeout = 200 * time.Millisecond
+	app.cbTimeout = 100 * time.Millisecond
+	start := time.Now()
+	app.jitter(context.Background(), app.drainTime())
+	// 随机等待的时间不能超过整体超时时间扣除等待请求、执行回调之后剩下的时间
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("随机等待超出了超时时间，实际 %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.shutdownTimeout = time.Hour
+	start = time.Now()
+	app.jitter(ctx, app.drainTime())
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("ctx 取消之后应该立刻结束等待，实际 %v", elapsed)
+	}
+}
+
+func TestWithShutdownJitter_WaitTimeFuncOnce(t *testing.T) {
+	var calls atomic.Int32
+	app := NewApp([]*Server{NewServer("test", "127.0.0.1:0")}, WithShutdownJitter(time.Millisecond),
+		WithWaitTimeFunc(func() time.Duration {
+			calls.Add(1)
+			return 0
+		}))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("每次优雅退出应该只调用一次 WithWaitTimeFunc，实际 %d 次", n)
+	}
+}
+
+func TestWithDrainConnectionClose(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithDrainConnectionClose())
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("drain") != "" {
+			s.rejectReq()
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Connection") != "" {
+		t.Fatal("没有优雅退出时不应该设置 Connection")
+	}
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?drain=1", nil))
+	if rec.Header().Get("Connection") != "close" {
+		t.Fatal("正在处理的请求在优雅退出后应该设置 Connection: close")
+	}
+}
+
+func TestApp_ShutdownContextCancelled(t *testing.T) {
+	s := NewServer("slow", "localhost:0")
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	var cbCalled bool
+	app := NewApp([]*Server{s}, WithWaitTime(time.Minute), WithShutdownCallbacks(func(ctx context.Context) {
+		cbCalled = true
+	}))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.Get("http://" + s.lis.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := app.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望 context.DeadlineExceeded，实际 %v", err)
+	}
+	if cbCalled {
+		t.Fatal("ctx 取消之后不应该再执行回调")
+	}
+}
+
+func TestWithDrainProgress(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	go s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+	var remaining []int
+	app := NewApp([]*Server{s}, WithDrainProgress(func(n int, elapsed time.Duration) {
+		remaining = append(remaining, n)
+		if len(remaining) == 2 {
+			close(release)
+		}
+	}))
+	if !app.waitDrained(context.Background(), app.servers, time.Minute) {
+		t.Fatal("请求处理完之后应该立刻返回")
+	}
+	if len(remaining) < 3 || remaining[0] != 1 || remaining[len(remaining)-1] != 0 {
+		t.Fatalf("进度回调错误 %v", remaining)
+	}
+}
+
+type panicServer struct {
+	tcpServer
+}
+
+func (s *panicServer) Stop(ctx context.Context) error {
+	panic("listener close panic")
+}
+
+func TestApp_StopServerPanic(t *testing.T) {
+	tcp := &tcpServer{stopped: make(chan struct{})}
+	app := NewApp(nil, WithServers(&panicServer{}, tcp))
+	if err := app.stopServers(context.Background(), app.servers); err == nil {
+		t.Fatal("期望返回 panic 转换的错误")
+	}
+	select {
+	case <-tcp.stopped:
+	default:
+		t.Fatal("其它服务器应该正常关闭")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	var (
+		deadline time.Time
+		ok       bool
+	)
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("drain") != "" {
+			s.setDrainWindow(time.Now(), time.Minute)
+			s.rejectReq()
+		}
+		deadline, ok = ShutdownDeadline(r.Context())
+	})
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if ok {
+		t.Fatal("没有开始优雅退出时不应该有截止时间")
+	}
+	s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?drain=1", nil))
+	if remaining := time.Until(deadline); !ok || remaining <= 50*time.Second || remaining > time.Minute {
+		t.Fatalf("截止时间错误 %v %v", deadline, ok)
+	}
+}
+
+func TestDrainSignal(t *testing.T) {
+	s := NewServer("test", "localhost:0")
+	s.HandleFunc("/events", SSEHandler)
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.serve() }()
+	defer s.forceClose()
+
+	resp, err := http.Get("http://" + s.lis.Addr().String() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(resp.Body)
+		body <- b
+	}()
+	// 等 handler 开始推送之后再开始优雅退出
+	for s.InFlight() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.rejectReq()
+	select {
+	case b := <-body:
+		if !strings.Contains(string(b), "event: close") {
+			t.Fatalf("期望收到关闭事件，实际 %q", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("开始优雅退出之后流式响应应该结束")
+	}
+	s.acceptReq()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), drainKey{}, s.mux))
+	select {
+	case <-DrainSignal(r):
+		t.Fatal("恢复处理请求之后不应该再收到信号")
+	default:
+	}
+}
+
+func TestWithDrainCancel(t *testing.T) {
+	s := NewServer("test", "localhost:0", WithDrainCancel(50*time.Millisecond))
+	cause := make(chan error, 1)
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if ShutdownChannel(r.Context()) == nil {
+			t.Error("请求的 context 应该能拿到 ShutdownChannel")
+		}
+		<-r.Context().Done()
+		cause <- context.Cause(r.Context())
+	})
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.serve() }()
+	defer s.forceClose()
+
+	go func() {
+		if resp, err := http.Get("http://" + s.lis.Addr().String()); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	for s.InFlight() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	start := time.Now()
+	s.rejectReq()
+	select {
+	case err := <-cause:
+		if !errors.Is(err, ErrDraining) {
+			t.Fatalf("取消原因应该是 ErrDraining，实际 %v", err)
+		}
+		if d := time.Since(start); d < 50*time.Millisecond {
+			t.Fatalf("应该在 grace 之后才取消，实际 %v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("开始优雅退出之后请求的 context 应该被取消")
+	}
+	s.acceptReq()
+	r, done := s.mux.withDrainCancel(httptest.NewRequest(http.MethodGet, "/", nil))
+	defer done()
+	if r.Context().Err() != nil {
+		t.Fatal("恢复处理请求之后新请求的 context 不应该被取消")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package web
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals 默认触发优雅退出的信号，只有中断和终止。
+// 不包含 SIGHUP，避免终端断开或者 logrotate 发送的信号意外关闭服务
+var signals = []os.Signal{
+	syscall.SIGINT, syscall.SIGTERM,
+}
+
+// reloadSignals 触发 OnReload 注册的重新加载钩子的信号
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -5,8 +5,10 @@ import (
 	"syscall"
 )
 
+// signals 默认触发优雅退出的信号，只有中断和终止
 var signals = []os.Signal{
-	os.Interrupt, os.Kill, syscall.SIGKILL,
-	syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGILL, syscall.SIGTRAP,
-	syscall.SIGABRT, syscall.SIGTERM,
+	os.Interrupt, syscall.SIGTERM,
 }
+
+// reloadSignals 触发 OnReload 注册的重新加载钩子的信号，Windows 上没有 SIGHUP，只能调用 Reload
+var reloadSignals []os.Signal
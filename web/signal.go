@@ -0,0 +1,26 @@
+package web
+
+import (
+	"os"
+	"os/signal"
+)
+
+// SignalNotifier 注册和取消接收信号，默认使用 signal.Notify 和 signal.Stop。
+// 测试时可以通过 WithSignalNotifier 换成 webtest.Signals，发送模拟的信号，不影响测试进程本身
+type SignalNotifier interface {
+	Notify(c chan<- os.Signal, sig ...os.Signal)
+	Stop(c chan<- os.Signal)
+}
+
+// WithSignalNotifier 使用 n 接收退出、重新加载和平滑升级的信号
+func WithSignalNotifier(n SignalNotifier) Option {
+	return func(app *App) {
+		app.notifier = n
+	}
+}
+
+type osSignals struct{}
+
+func (osSignals) Notify(c chan<- os.Signal, sig ...os.Signal) { signal.Notify(c, sig...) }
+
+func (osSignals) Stop(c chan<- os.Signal) { signal.Stop(c) }
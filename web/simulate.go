@@ -0,0 +1,183 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShutdownPlan SimulateShutdown 根据当前配置推算出的优雅退出过程，不会真正拒绝请求、关闭服务器或者执行回调
+type ShutdownPlan struct {
+	// Steps 按执行顺序排列的各个步骤
+	Steps []PlanStep
+	// Drained 需要摘流量的服务器，Last 不摘流量、最后才关闭的服务器（例如 admin）
+	Drained []string
+	Last    []string
+	// Callbacks 回调的执行顺序，同一个 Phase 内的回调并发执行（设置了 WithSequentialCallbacks 时除外）
+	Callbacks []PlannedCallback
+	// Resources 通过 RegisterCloser 注册的资源数
+	Resources int
+	// MaxDuration 有明确上限的步骤的最长耗时之和，不超过 shutdownTimeout
+	MaxDuration time.Duration
+	// ShutdownTimeout 整个优雅退出的超时时间
+	ShutdownTimeout time.Duration
+	// Conflicts 互相冲突的配置，例如等待请求的时间超过了整个优雅退出的超时时间
+	Conflicts []error
+}
+
+// PlanStep 优雅退出的一个步骤
+type PlanStep struct {
+	Name string
+	// Max 这一步最长的耗时，0 表示只受 shutdownTimeout 限制
+	Max time.Duration
+}
+
+// PlannedCallback 计划执行的回调
+type PlannedCallback struct {
+	Label string
+	Phase Phase
+}
+
+// SimulateShutdown 推算优雅退出的步骤、回调顺序和各步骤最长的耗时，并检查配置冲突，
+// 不影响正在运行的应用，可以在测试或者发布前检查超时配置。
+// 返回的错误是 Conflicts 合并之后的结果，没有冲突时为 nil
+func (a *App) SimulateShutdown() (*ShutdownPlan, error) {
+	plan := &ShutdownPlan{ShutdownTimeout: a.shutdownTimeout}
+	if err := a.Validate(); err != nil {
+		plan.Conflicts = append(plan.Conflicts, err)
+	}
+	drained, last := a.partitionServers()
+	for _, s := range drained {
+		plan.Drained = append(plan.Drained, s.Name())
+	}
+	for _, s := range last {
+		plan.Last = append(plan.Last, s.Name())
+	}
+	cbs := a.callbacks()
+	for _, phase := range callbackPhases(cbs) {
+		for _, idx := range phase {
+			plan.Callbacks = append(plan.Callbacks, PlannedCallback{Label: cbs[idx].label(idx), Phase: cbs[idx].phase})
+		}
+	}
+	cbMax := a.callbacksMax(cbs)
+	a.closers.mu.Lock()
+	plan.Resources = len(a.closers.resources) + len(a.closers.logs)
+	a.closers.mu.Unlock()
+
+	waitTime := a.drainTime()
+	plan.Steps = a.shutdownSteps(waitTime)
+	for _, step := range plan.Steps {
+		plan.MaxDuration += step.Max
+	}
+	plan.MaxDuration = min(plan.MaxDuration, a.shutdownTimeout)
+
+	if a.minDrainTime > waitTime {
+		plan.Conflicts = append(plan.Conflicts, fmt.Errorf("web: minDrainTime %v 大于等待请求时间 %v，只会等待 %v",
+			a.minDrainTime, waitTime, waitTime))
+	}
+	if a.longRunningWaitTime > 0 && a.longRunningWaitTime+cbMax > a.shutdownTimeout {
+		plan.Conflicts = append(plan.Conflicts, fmt.Errorf("web: shutdownTimeout %v 小于长时间运行请求的等待时间 %v 加上回调的最长耗时 %v",
+			a.shutdownTimeout, a.longRunningWaitTime, cbMax))
+	}
+	// 只有一个阶段时和 Validate 的检查重复
+	if a.waitTimeFunc == nil && cbMax > a.cbTimeout && waitTime+cbMax > a.shutdownTimeout {
+		plan.Conflicts = append(plan.Conflicts, fmt.Errorf("web: shutdownTimeout %v 小于等待请求时间 %v 加上所有阶段回调的最长耗时 %v",
+			a.shutdownTimeout, waitTime, cbMax))
+	}
+	return plan, errors.Join(plan.Conflicts...)
+}
+
+// shutdownSteps 按执行顺序返回等待请求 waitTime 时优雅退出的步骤和各自最长的耗时，SimulateShutdown 和 WithShutdownBudget 共用
+func (a *App) shutdownSteps(waitTime time.Duration) []PlanStep {
+	var steps []PlanStep
+	add := func(name string, d time.Duration) {
+		steps = append(steps, PlanStep{Name: name, Max: d})
+	}
+	_, last := a.partitionServers()
+	cbs := a.callbacks()
+	if jitter := min(a.shutdownJitter, a.shutdownTimeout-waitTime-a.cbTimeout); jitter > 0 {
+		add("jitter", jitter)
+	}
+	if len(a.prepares) > 0 {
+		add("prepare", a.cbTimeout)
+	}
+	if len(a.registrars) > 0 {
+		add("deregister", time.Duration(len(a.registrars))*a.cbTimeout)
+	}
+	add("drain", max(waitTime, a.longRunningWaitTime))
+	stop := func() { add("stop_servers", 0) }
+	callbacks := func() {
+		if len(cbs) > 0 {
+			add("callbacks", a.callbacksMax(cbs))
+		}
+	}
+	if a.callbacksBeforeStop {
+		callbacks()
+		stop()
+	} else {
+		stop()
+		callbacks()
+	}
+	if len(last) > 0 {
+		add("stop_last_servers", noDrainStopTimeout)
+	}
+	if wt := a.maxWorkerStopTimeout(); wt > 0 {
+		add("wait_workers", wt)
+	}
+	add("close", 0)
+	return steps
+}
+
+// callbacksMax 执行完所有回调最长的耗时
+func (a *App) callbacksMax(cbs []callback) time.Duration {
+	var res time.Duration
+	for _, phase := range callbackPhases(cbs) {
+		if a.sequentialCallbacks {
+			res += time.Duration(len(phase)) * a.cbTimeout
+		} else {
+			res += a.cbTimeout
+		}
+	}
+	return res
+}
+
+// maxWorkerStopTimeout AddWorker 添加的 worker 中最长的退出等待时间
+func (a *App) maxWorkerStopTimeout() time.Duration {
+	a.workerMu.Lock()
+	defer a.workerMu.Unlock()
+	var res time.Duration
+	for _, w := range a.workerList {
+		res = max(res, w.stopTimeout)
+	}
+	return res
+}
+
+// String 多行的报告，依次列出步骤、服务器、回调顺序和配置冲突
+func (p *ShutdownPlan) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "优雅退出计划 最长 %v（超时时间 %v）\n", p.MaxDuration, p.ShutdownTimeout)
+	for i, step := range p.Steps {
+		if step.Max > 0 {
+			fmt.Fprintf(&sb, "%d. %s 最长 %v\n", i+1, step.Name, step.Max)
+		} else {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, step.Name)
+		}
+	}
+	fmt.Fprintf(&sb, "摘流量的服务器: %s\n", strings.Join(p.Drained, ", "))
+	if len(p.Last) > 0 {
+		fmt.Fprintf(&sb, "最后关闭的服务器: %s\n", strings.Join(p.Last, ", "))
+	}
+	if len(p.Callbacks) > 0 {
+		labels := make([]string, 0, len(p.Callbacks))
+		for _, cb := range p.Callbacks {
+			labels = append(labels, fmt.Sprintf("%s(阶段%d)", cb.Label, cb.Phase))
+		}
+		fmt.Fprintf(&sb, "回调顺序: %s\n", strings.Join(labels, " -> "))
+	}
+	fmt.Fprintf(&sb, "资源: %d 个\n", p.Resources)
+	for _, err := range p.Conflicts {
+		fmt.Fprintf(&sb, "配置冲突: %v\n", err)
+	}
+	return sb.String()
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApp_SimulateShutdown(t *testing.T) {
+	api := NewServer("api", "localhost:0")
+	app := NewApp([]*Server{api}, WithWaitTime(2*time.Second), WithShutdownTimeout(10*time.Second),
+		WithCallbackTimeout(time.Second), WithAdminServer("localhost:0", ""))
+	ran := false
+	app.RegisterShutdownFunc("close-db", PhaseResources, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	app.RegisterShutdownFunc("flush-logs", PhaseFlush, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	plan, err := app.SimulateShutdown()
+	if err != nil {
+		t.Fatalf("配置没有冲突，实际 %v", err)
+	}
+	if ran || app.IsDraining() {
+		t.Fatal("模拟不应该执行回调或者开始优雅退出")
+	}
+	var steps []string
+	for _, s := range plan.Steps {
+		steps = append(steps, s.Name)
+	}
+	if want := []string{"drain", "stop_servers", "callbacks", "stop_last_servers", "close"}; !reflect.DeepEqual(steps, want) {
+		t.Fatalf("期望步骤 %v，实际 %v", want, steps)
+	}
+	if plan.Callbacks[0].Label != "flush-logs" || plan.Callbacks[1].Label != "close-db" {
+		t.Fatalf("回调应该按阶段排序，实际 %v", plan.Callbacks)
+	}
+	if !reflect.DeepEqual(plan.Drained, []string{"api"}) || !reflect.DeepEqual(plan.Last, []string{AdminServerName}) {
+		t.Fatalf("服务器分组不对 %v %v", plan.Drained, plan.Last)
+	}
+	// 等待请求 2s + 两个阶段的回调 2s + 最后关闭的服务器 1s
+	if plan.MaxDuration != 5*time.Second {
+		t.Fatalf("期望最长 5s，实际 %v", plan.MaxDuration)
+	}
+	if !strings.Contains(plan.String(), "flush-logs(阶段100) -> close-db(阶段300)") {
+		t.Fatalf("报告中应该列出回调顺序，实际\n%s", plan)
+	}
+}
+
+func TestApp_SimulateShutdown_Conflicts(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(4*time.Second), WithShutdownTimeout(5*time.Second),
+		WithCallbackTimeout(time.Second), WithMinDrainTime(10*time.Second))
+	app.RegisterShutdownFunc("a", PhaseFlush, func(ctx context.Context) error { return nil })
+	app.RegisterShutdownFunc("b", PhaseResources, func(ctx context.Context) error { return nil })
+	plan, err := app.SimulateShutdown()
+	if err == nil || len(plan.Conflicts) != 2 {
+		t.Fatalf("应该发现 minDrainTime 和回调总耗时两个冲突，实际 %v", plan.Conflicts)
+	}
+	if !strings.Contains(err.Error(), "minDrainTime") {
+		t.Fatalf("期望 minDrainTime 冲突，实际 %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// 写入状态文件的应用状态
+const (
+	stateReady    = "ready"
+	stateDraining = "draining"
+	stateStopped  = "stopped"
+)
+
+// WithStateFile 在 path 中记录应用的状态，供通过文件而不是接口判断应用状态的外部程序使用：
+// 启动成功后写入 "ready"，开始优雅退出时写入 "draining"，关闭完成后写入 "stopped"。
+// 先写临时文件再重命名，读取方不会读到写了一半的内容
+func WithStateFile(path string) Option {
+	return func(app *App) {
+		app.stateFile = path
+	}
+}
+
+// writeState 把 state 写入状态文件，失败时只输出日志
+func (a *App) writeState(state string) {
+	if a.stateFile == "" {
+		return
+	}
+	if err := writeFileAtomic(a.stateFile, []byte(state+"\n")); err != nil {
+		a.logf("写入状态文件失败 %v", err)
+	}
+}
+
+// writeFileAtomic 在同一个目录下写临时文件之后重命名为 path
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
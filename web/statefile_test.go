@@ -0,0 +1,43 @@
+package web
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithStateFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.state")
+	readState := func() string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	s := NewServer("test", "localhost:0")
+	var draining string
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithStateFile(path), WithShutdownCallbacks(func(ctx context.Context) {
+		draining = readState()
+	}))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readState(); got != stateReady {
+		t.Fatalf("启动之后期望 %s，实际 %s", stateReady, got)
+	}
+	_ = app.Shutdown(context.Background())
+	if draining != stateDraining {
+		t.Fatalf("优雅退出期间期望 %s，实际 %s", stateDraining, draining)
+	}
+	if got := readState(); got != stateStopped {
+		t.Fatalf("关闭之后期望 %s，实际 %s", stateStopped, got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("不应该留下临时文件 %v", entries)
+	}
+}
@@ -0,0 +1,591 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultRestartBackoff 组件第一次重启之前等待的时间
+	defaultRestartBackoff = time.Second
+	// defaultMaxRestartBackoff 组件重启之前最多等待的时间
+	defaultMaxRestartBackoff = 30 * time.Second
+	// defaultComponentStopTimeout Runnable 组件关闭的超时时间
+	defaultComponentStopTimeout = 30 * time.Second
+)
+
+// errSupervisorRunning Supervisor 只能运行一次
+var errSupervisorRunning = errors.New("web: Supervisor 已经运行过了")
+
+// RestartPolicy 组件退出之后是否重启
+type RestartPolicy string
+
+const (
+	// RestartNever 不重启，默认的策略
+	RestartNever RestartPolicy = "never"
+	// RestartOnFailure 返回错误（包括 panic）时重启，正常返回时不重启
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartAlways 不管是否返回错误都重启
+	RestartAlways RestartPolicy = "always"
+)
+
+// ComponentState 组件的运行状态
+type ComponentState string
+
+const (
+	// ComponentIdle 还没有启动
+	ComponentIdle ComponentState = "idle"
+	// ComponentStarting 正在启动
+	ComponentStarting ComponentState = "starting"
+	// ComponentRunning 正在运行
+	ComponentRunning ComponentState = "running"
+	// ComponentRestarting 退出之后等待重启
+	ComponentRestarting ComponentState = "restarting"
+	// ComponentStopping 正在关闭
+	ComponentStopping ComponentState = "stopping"
+	// ComponentStopped 已经关闭，或者正常退出并且不再重启
+	ComponentStopped ComponentState = "stopped"
+	// ComponentFailed 启动失败，或者异常退出并且不再重启
+	ComponentFailed ComponentState = "failed"
+)
+
+// Runnable Supervisor 管理的组件，例如消费者、定时任务。ctx 被取消时应该尽快返回
+type Runnable interface {
+	Run(ctx context.Context) error
+}
+
+// RunnableFunc 把函数转换为 Runnable
+type RunnableFunc func(ctx context.Context) error
+
+func (f RunnableFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// SupervisorOption 配置 Supervisor
+type SupervisorOption func(*Supervisor)
+
+// WithSupervisorSignals 设置 Supervisor 监听的信号，默认和 App 一样
+func WithSupervisorSignals(sigs ...os.Signal) SupervisorOption {
+	return func(s *Supervisor) {
+		s.signals = sigs
+	}
+}
+
+// WithSupervisorNotifier 和 WithSignalNotifier 一样，测试时可以换成 webtest.Signals
+func WithSupervisorNotifier(n SignalNotifier) SupervisorOption {
+	return func(s *Supervisor) {
+		s.notifier = n
+	}
+}
+
+// WithSupervisorClock 和 WithClock 一样，影响重启的退避时间和关闭的超时时间
+func WithSupervisorClock(c Clock) SupervisorOption {
+	return func(s *Supervisor) {
+		s.clock = c
+	}
+}
+
+// WithSupervisorLogOutput 设置 Supervisor 的日志输出，默认使用标准库 log
+func WithSupervisorLogOutput(w io.Writer) SupervisorOption {
+	return func(s *Supervisor) {
+		s.logger = log.New(w, "", log.LstdFlags)
+	}
+}
+
+// ComponentOption 配置 Supervisor 中的组件
+type ComponentOption func(*component)
+
+// WithRestartPolicy 设置组件退出之后的重启策略，默认 RestartNever
+func WithRestartPolicy(p RestartPolicy) ComponentOption {
+	return func(c *component) {
+		c.policy = p
+	}
+}
+
+// WithRestartBackoff 第一次重启之前等待 initial，之后每次翻倍，最多等待 maxBackoff；
+// 组件连续运行超过 maxBackoff 之后重新从 initial 开始。默认 1s 和 30s
+func WithRestartBackoff(initial, maxBackoff time.Duration) ComponentOption {
+	return func(c *component) {
+		c.backoff = initial
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithMaxRestarts 组件最多重启 n 次，之后按照最后一次退出的结果变成 ComponentStopped 或者 ComponentFailed。
+// 默认不限制次数
+func WithMaxRestarts(n int) ComponentOption {
+	return func(c *component) {
+		c.maxRestarts = n
+	}
+}
+
+// WithComponentHealthCheck 组件在运行时额外执行的健康检查，结果汇总到 Supervisor.CheckHealth 中
+func WithComponentHealthCheck(check HealthChecker) ComponentOption {
+	return func(c *component) {
+		c.health = check
+	}
+}
+
+// WithComponentStopTimeout 关闭时最多等待组件 d，Runnable 默认 30s；
+// App 默认不限制，由 App 自己的 WithShutdownTimeout 控制
+func WithComponentStopTimeout(d time.Duration) ComponentOption {
+	return func(c *component) {
+		c.stopTimeout = d
+	}
+}
+
+// Supervisor 在同一个进程中管理多个 App 和 Runnable，例如 API 服务器、指标服务器和 worker 池。
+// 和 Coordinator 不同，每个组件可以单独设置重启策略，健康状态汇总到 CheckHealth 和 HealthHandler 中。
+// 按照添加的顺序启动组件，App 启动（开始监听）之后才启动下一个组件；
+// 统一监听信号，收到信号、ctx 被取消或者不再重启的组件异常退出时，按照启动的相反顺序依次关闭所有组件，
+// 关闭期间再次收到信号时不再等待，返回 ErrForcedShutdown
+type Supervisor struct {
+	components []*component
+	signals    []os.Signal
+	notifier   SignalNotifier
+	clock      Clock
+	logger     *log.Logger
+	running    atomic.Bool
+
+	// forceCtx 强制退出时取消，App 组件用它执行优雅退出
+	forceCtx context.Context
+	force    context.CancelFunc
+}
+
+// NewSupervisor 创建 Supervisor
+func NewSupervisor(opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{signals: signals, notifier: osSignals{}, clock: realClock{}}
+	s.forceCtx, s.force = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add 添加名为 name 的 Runnable，需要在 Run 之前调用。Run 返回时组件就是启动完成了，
+// panic 当成返回错误处理
+func (s *Supervisor) Add(name string, r Runnable, opts ...ComponentOption) {
+	s.add(name, runnableStarter{r}, opts)
+}
+
+// AddApp 添加名为 name 的 App，需要在 Run 之前调用。每次启动（包括重启）都调用 newApp 创建新的 App，
+// 因为优雅退出之后的 App 不能再次启动。App 自己开始优雅退出时（例如 WithSelfShutdownOnUnhealthy、
+// WithPanicShutdown）当成异常退出，App 不需要设置信号，Supervisor 统一处理
+func (s *Supervisor) AddApp(name string, newApp func() *App, opts ...ComponentOption) {
+	s.add(name, &appStarter{newApp: newApp, forceCtx: s.forceCtx}, opts)
+}
+
+func (s *Supervisor) add(name string, run starter, opts []ComponentOption) {
+	c := &component{name: name, run: run, policy: RestartNever, backoff: defaultRestartBackoff,
+		maxBackoff: defaultMaxRestartBackoff, state: ComponentIdle}
+	if _, ok := run.(runnableStarter); ok {
+		c.stopTimeout = defaultComponentStopTimeout
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	s.components = append(s.components, c)
+}
+
+// Run 依次启动所有组件并等待退出，见 Supervisor。只能调用一次。
+// 组件的 ctx 继承 ctx 中的值（例如 trace ID、logger），ctx 被取消时按顺序关闭组件，而不是同时取消。
+// 返回启动失败、不再重启的组件异常退出、组件关闭失败或者超时的所有错误
+func (s *Supervisor) Run(ctx context.Context) error {
+	if !s.running.CompareAndSwap(false, true) {
+		return errSupervisorRunning
+	}
+	defer s.force()
+	ch := make(chan os.Signal, 2)
+	if len(s.signals) > 0 {
+		s.notifier.Notify(ch, s.signals...)
+		defer s.notifier.Stop(ch)
+	}
+	exits := make(chan componentExit, len(s.components))
+	for i, c := range s.components {
+		if err := s.start(ctx, c, exits); err != nil {
+			s.logf("组件%s启动失败 %v", c.name, err)
+			return errors.Join(fmt.Errorf("web: 组件%s启动失败: %w", c.name, err), s.shutdown(ch, s.components[:i]))
+		}
+	}
+	err := s.wait(ctx, ch, exits)
+	return errors.Join(err, s.shutdown(ch, s.components))
+}
+
+// componentExit 不再重启的组件退出的结果
+type componentExit struct {
+	c   *component
+	err error
+}
+
+// wait 等待信号、ctx 被取消、不再重启的组件异常退出或者所有的组件都已经退出，组件异常退出时返回它的错误
+func (s *Supervisor) wait(ctx context.Context, ch <-chan os.Signal, exits <-chan componentExit) error {
+	for remaining := len(s.components); remaining > 0; remaining-- {
+		select {
+		case <-ch:
+			s.logf("收到信号，关闭所有组件")
+			return nil
+		case <-ctx.Done():
+			s.logf("context 被取消，关闭所有组件")
+			return nil
+		case e := <-exits:
+			if e.err != nil {
+				s.logf("组件%s异常退出，关闭所有组件 %v", e.c.name, e.err)
+				return fmt.Errorf("web: 组件%s异常退出: %w", e.c.name, e.err)
+			}
+			s.logf("组件%s已退出", e.c.name)
+		}
+	}
+	s.logf("所有组件都已退出")
+	return nil
+}
+
+// shutdown 按照启动的相反顺序依次关闭 components，再次收到信号时取消剩下的等待
+func (s *Supervisor) shutdown(ch <-chan os.Signal, components []*component) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ch:
+			s.logf("再次收到信号，强制退出")
+			s.force()
+		case <-done:
+		}
+	}()
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		if err := s.stop(components[i]); err != nil {
+			errs = append(errs, err)
+		}
+		if s.forceCtx.Err() != nil {
+			for _, c := range components[:i] {
+				c.cancel()
+			}
+			return errors.Join(append(errs, ErrForcedShutdown)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stop 取消组件的 ctx 并等待它退出，返回组件关闭时返回的错误
+func (s *Supervisor) stop(c *component) error {
+	c.setState(ComponentStopping)
+	c.cancel()
+	var timeout <-chan time.Time
+	if c.stopTimeout > 0 {
+		timer := s.clock.NewTimer(c.stopTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	select {
+	case <-c.done:
+	case <-timeout:
+		s.logf("组件%s在 %v 内没有退出", c.name, c.stopTimeout)
+		return fmt.Errorf("web: 组件%s在 %v 内没有退出", c.name, c.stopTimeout)
+	case <-s.forceCtx.Done():
+		return nil
+	}
+	s.logf("组件%s已关闭", c.name)
+	if err := c.stopErr; err != nil {
+		return fmt.Errorf("web: 关闭组件%s失败: %w", c.name, err)
+	}
+	return nil
+}
+
+// start 第一次启动组件，启动成功之后在单独的 goroutine 中监督组件。
+// 组件的 ctx（包括重启之后）保留 parent 中的值，但只由 Supervisor 取消
+func (s *Supervisor) start(parent context.Context, c *component, exits chan<- componentExit) error {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	c.cancel, c.done = cancel, make(chan struct{})
+	c.setState(ComponentStarting)
+	wait, err := c.run.start(ctx)
+	if err != nil {
+		c.exited(err, ComponentFailed)
+		cancel()
+		close(c.done)
+		return err
+	}
+	c.setState(ComponentRunning)
+	s.logf("组件%s已启动", c.name)
+	go s.supervise(ctx, c, wait, exits)
+	return nil
+}
+
+// supervise 等待组件退出，按照重启策略重启，不再重启时通过 exits 通知 Run
+func (s *Supervisor) supervise(ctx context.Context, c *component, wait func() error, exits chan<- componentExit) {
+	defer close(c.done)
+	backoff := c.backoff
+	for {
+		started := s.clock.Now()
+		err := wait()
+		if ctx.Err() != nil {
+			if err != nil && !errors.Is(err, context.Canceled) {
+				c.stopErr = err
+			}
+			c.exited(err, ComponentStopped)
+			return
+		}
+		if !c.shouldRestart(err) {
+			st := ComponentStopped
+			if err != nil {
+				st = ComponentFailed
+			}
+			c.exited(err, st)
+			exits <- componentExit{c: c, err: err}
+			return
+		}
+		// 稳定运行过一段时间之后重新开始计算退避时间
+		if s.clock.Now().Sub(started) >= c.maxBackoff {
+			backoff = c.backoff
+		}
+		restarts := c.restarting(err)
+		s.logf("组件%s退出 %v，%v 之后第%d次重启", c.name, err, backoff, restarts)
+		timer := s.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			c.exited(nil, ComponentStopped)
+			return
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+		c.setState(ComponentStarting)
+		if wait, err = c.run.start(ctx); err != nil {
+			// 启动失败也按照重启策略处理
+			wait = func() error { return err }
+			continue
+		}
+		c.setState(ComponentRunning)
+		s.logf("组件%s重新启动", c.name)
+	}
+}
+
+// ComponentStatus 组件的运行状态
+type ComponentStatus struct {
+	Name     string         `json:"name"`
+	State    ComponentState `json:"state"`
+	Restarts int            `json:"restarts"`
+	// LastError 最后一次退出时返回的错误
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Status 返回所有组件的运行状态，顺序和添加顺序一致
+func (s *Supervisor) Status() []ComponentStatus {
+	res := make([]ComponentStatus, 0, len(s.components))
+	for _, c := range s.components {
+		c.mu.Lock()
+		st := ComponentStatus{Name: c.name, State: c.state, Restarts: c.restarts}
+		if c.lastErr != nil {
+			st.LastError = c.lastErr.Error()
+		}
+		c.mu.Unlock()
+		res = append(res, st)
+	}
+	return res
+}
+
+// CheckHealth 所有组件都在运行并且健康检查（包括 App 的 AddHealthCheck）都通过时返回 nil，
+// 返回的错误包含所有不健康的组件
+func (s *Supervisor) CheckHealth(ctx context.Context) error {
+	var errs []error
+	for _, c := range s.components {
+		if err := c.checkHealth(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("web: 组件%s不健康: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HealthHandler 和 ReadyzPath 一样返回 JSON，checks 中是每个组件的结果，有组件不健康时返回 503
+func (s *Supervisor) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), probeCheckTimeout)
+		defer cancel()
+		res := probeResult{Status: "ok", Checks: make(map[string]string, len(s.components))}
+		for _, c := range s.components {
+			if err := c.checkHealth(ctx); err != nil {
+				res.Checks[c.name] = err.Error()
+				res.Status, res.Reason = "unavailable", "component unhealthy"
+				continue
+			}
+			res.Checks[c.name] = "ok"
+		}
+		code := http.StatusOK
+		if res.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		writeProbe(w, code, res)
+	})
+}
+
+func (s *Supervisor) logf(format string, args ...any) {
+	if s.logger != nil {
+		s.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// component Supervisor 中的一个组件
+type component struct {
+	name        string
+	run         starter
+	policy      RestartPolicy
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxRestarts int
+	stopTimeout time.Duration
+	health      HealthChecker
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	// stopErr 关闭时组件返回的错误，done 关闭之后才能读取
+	stopErr error
+
+	mu       sync.Mutex
+	state    ComponentState
+	restarts int
+	lastErr  error
+}
+
+func (c *component) setState(st ComponentState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 已经退出的组件关闭时保持原来的状态
+	if st == ComponentStopping && (c.state == ComponentStopped || c.state == ComponentFailed) {
+		return
+	}
+	c.state = st
+}
+
+// exited 记录组件退出的结果
+func (c *component) exited(err error, st ComponentState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.lastErr = err
+	}
+	c.state = st
+}
+
+// restarting 记录退出的结果并增加重启次数，返回这是第几次重启
+func (c *component) restarting(err error) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.lastErr = err
+	}
+	c.restarts++
+	c.state = ComponentRestarting
+	return c.restarts
+}
+
+// shouldRestart 按照重启策略和次数判断以 err 退出之后是否重启
+func (c *component) shouldRestart(err error) bool {
+	c.mu.Lock()
+	restarts := c.restarts
+	c.mu.Unlock()
+	if c.maxRestarts > 0 && restarts >= c.maxRestarts {
+		return false
+	}
+	switch c.policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+func (c *component) checkHealth(ctx context.Context) error {
+	c.mu.Lock()
+	st := c.state
+	c.mu.Unlock()
+	if st != ComponentRunning {
+		return fmt.Errorf("状态是%s", st)
+	}
+	if c.health != nil {
+		if err := c.health(ctx); err != nil {
+			return err
+		}
+	}
+	if a, ok := c.run.(*appStarter); ok {
+		return a.checkHealth(ctx)
+	}
+	return nil
+}
+
+// starter 启动组件，返回的 wait 阻塞到组件退出。App 开始监听之后 start 才返回
+type starter interface {
+	start(ctx context.Context) (wait func() error, err error)
+}
+
+type runnableStarter struct {
+	r Runnable
+}
+
+func (r runnableStarter) start(ctx context.Context) (func() error, error) {
+	return func() (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("web: panic: %v", rec)
+			}
+		}()
+		return r.r.Run(ctx)
+	}, nil
+}
+
+// appStarter 每次启动创建一个新的 App
+type appStarter struct {
+	newApp   func() *App
+	forceCtx context.Context
+
+	mu  sync.Mutex
+	app *App
+}
+
+func (a *appStarter) start(ctx context.Context) (func() error, error) {
+	app := a.newApp()
+	if err := app.Start(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.app = app
+	a.mu.Unlock()
+	return func() error {
+		self := false
+		select {
+		case <-ctx.Done():
+		case <-app.ctx.Done():
+			self = true
+		case <-app.triggered:
+			self = true
+		}
+		err := app.Shutdown(a.forceCtx)
+		if self {
+			return errors.Join(errors.New("web: 应用自己开始了优雅退出"), err)
+		}
+		return err
+	}, nil
+}
+
+func (a *appStarter) checkHealth(ctx context.Context) error {
+	a.mu.Lock()
+	app := a.app
+	a.mu.Unlock()
+	if app == nil {
+		return nil
+	}
+	if app.IsDraining() {
+		return errors.New("正在优雅退出")
+	}
+	return app.CheckHealth(ctx)
+}
@@ -0,0 +1,256 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testNotifier 测试用的 SignalNotifier，send 给注册的 channel 发送信号
+type testNotifier struct {
+	mu sync.Mutex
+	ch chan<- os.Signal
+}
+
+func (n *testNotifier) Notify(c chan<- os.Signal, _ ...os.Signal) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ch = c
+}
+
+func (n *testNotifier) Stop(chan<- os.Signal) {}
+
+func (n *testNotifier) send(sig os.Signal) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ch <- sig
+}
+
+// stopRecorder 记录组件关闭的顺序
+type stopRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *stopRecorder) runnable(name string) Runnable {
+	return RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.order = append(r.order, name)
+		return nil
+	})
+}
+
+func waitComponent(t *testing.T, s *Supervisor, name string, st ComponentState) ComponentStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, cs := range s.Status() {
+			if cs.Name == name && cs.State == st {
+				return cs
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("组件%s的状态应该变成%s，实际 %+v", name, st, s.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSupervisor_ReverseStopOrder(t *testing.T) {
+	n := &testNotifier{}
+	rec := &stopRecorder{}
+	s := NewSupervisor(WithSupervisorNotifier(n), WithSupervisorLogOutput(io.Discard))
+	s.Add("worker", rec.runnable("worker"))
+	s.AddApp("api", func() *App {
+		app := NewApp([]*Server{NewServer("api", "127.0.0.1:0")}, WithWaitTime(0), WithLogOutput(io.Discard))
+		app.RegisterCallback("record", PhaseDefault, func(context.Context) {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			rec.order = append(rec.order, "api")
+		})
+		return app
+	})
+	s.Add("metrics", rec.runnable("metrics"))
+
+	run := make(chan error, 1)
+	go func() { run <- s.Run(context.Background()) }()
+	waitComponent(t, s, "metrics", ComponentRunning)
+	if err := s.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("所有组件运行时应该是健康的，实际 %v", err)
+	}
+	n.send(syscall.SIGTERM)
+	if err := <-run; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"metrics", "api", "worker"}; !slices.Equal(rec.order, want) {
+		t.Fatalf("应该按照启动的相反顺序关闭 %v，实际 %v", want, rec.order)
+	}
+	for _, cs := range s.Status() {
+		if cs.State != ComponentStopped {
+			t.Fatalf("关闭之后组件%s的状态应该是 stopped，实际 %s", cs.Name, cs.State)
+		}
+	}
+	if err := s.Run(context.Background()); !errors.Is(err, errSupervisorRunning) {
+		t.Fatalf("再次运行应该返回 errSupervisorRunning，实际 %v", err)
+	}
+}
+
+func TestSupervisor_RestartOnFailure(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	s := NewSupervisor(WithSupervisorSignals(), WithSupervisorLogOutput(io.Discard))
+	s.Add("consumer", RunnableFunc(func(ctx context.Context) error {
+		mu.Lock()
+		runs++
+		n := runs
+		mu.Unlock()
+		if n <= 2 {
+			return errors.New("连接断开")
+		}
+		<-ctx.Done()
+		return nil
+	}), WithRestartPolicy(RestartOnFailure), WithRestartBackoff(10*time.Millisecond, 20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := make(chan error, 1)
+	go func() { run <- s.Run(ctx) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Status()[0].Restarts < 2 || s.Status()[0].State != ComponentRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("组件应该重启两次之后正常运行，实际 %+v", s.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if st := s.Status()[0]; st.LastError != "连接断开" {
+		t.Fatalf("应该记录最后一次退出的错误，实际 %q", st.LastError)
+	}
+	w := httptest.NewRecorder()
+	s.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("重启成功之后应该是健康的，实际 %d %s", w.Code, w.Body)
+	}
+	cancel()
+	if err := <-run; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisor_ContextValues(t *testing.T) {
+	type key struct{}
+	got := make(chan any, 2)
+	var runs atomic.Int32
+	s := NewSupervisor(WithSupervisorSignals(), WithSupervisorLogOutput(io.Discard))
+	s.Add("worker", RunnableFunc(func(ctx context.Context) error {
+		got <- ctx.Value(key{})
+		if runs.Add(1) == 1 {
+			return errors.New("重启")
+		}
+		<-ctx.Done()
+		return nil
+	}), WithRestartPolicy(RestartOnFailure), WithRestartBackoff(time.Millisecond, time.Millisecond))
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "trace"))
+	run := make(chan error, 1)
+	go func() { run <- s.Run(ctx) }()
+	for range 2 {
+		if v := <-got; v != "trace" {
+			t.Fatalf("组件的 ctx 应该保留 Run 传入的值（包括重启之后），实际 %v", v)
+		}
+	}
+	cancel()
+	if err := <-run; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisor_FailureStopsAll(t *testing.T) {
+	rec := &stopRecorder{}
+	fail := make(chan struct{})
+	s := NewSupervisor(WithSupervisorSignals(), WithSupervisorLogOutput(io.Discard))
+	s.Add("worker", rec.runnable("worker"))
+	s.Add("job", RunnableFunc(func(ctx context.Context) error {
+		<-fail
+		panic("崩溃")
+	}))
+	run := make(chan error, 1)
+	go func() { run <- s.Run(context.Background()) }()
+	waitComponent(t, s, "job", ComponentRunning)
+
+	close(fail)
+	err := <-run
+	if err == nil || s.Status()[1].State != ComponentFailed {
+		t.Fatalf("不重启的组件异常退出时应该关闭所有组件并返回错误，实际 %v %+v", err, s.Status())
+	}
+	if !slices.Equal(rec.order, []string{"worker"}) {
+		t.Fatalf("其他组件应该被关闭，实际 %v", rec.order)
+	}
+	w := httptest.NewRecorder()
+	s.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("组件异常退出之后应该返回 503，实际 %d", w.Code)
+	}
+}
+
+func TestSupervisor_MaxRestarts(t *testing.T) {
+	s := NewSupervisor(WithSupervisorSignals(), WithSupervisorLogOutput(io.Discard))
+	s.Add("tick", RunnableFunc(func(ctx context.Context) error { return nil }),
+		WithRestartPolicy(RestartAlways), WithRestartBackoff(time.Millisecond, time.Millisecond), WithMaxRestarts(3))
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("所有组件都退出之后 Run 应该返回")
+	}
+	if st := s.Status()[0]; st.Restarts != 3 || st.State != ComponentStopped {
+		t.Fatalf("应该重启3次之后停止，实际 %+v", st)
+	}
+}
+
+func TestSupervisor_StartFailure(t *testing.T) {
+	rec := &stopRecorder{}
+	s := NewSupervisor(WithSupervisorSignals(), WithSupervisorLogOutput(io.Discard))
+	s.Add("worker", rec.runnable("worker"))
+	s.AddApp("api", func() *App {
+		return NewApp([]*Server{NewServer("api", "256.0.0.1:0")}, WithLogOutput(io.Discard))
+	})
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatal("App 启动失败时应该返回错误")
+	}
+	if !slices.Equal(rec.order, []string{"worker"}) {
+		t.Fatalf("已经启动的组件应该被关闭，实际 %v", rec.order)
+	}
+}
+
+func TestSupervisor_ForcedShutdown(t *testing.T) {
+	n := &testNotifier{}
+	release := make(chan struct{})
+	defer close(release)
+	s := NewSupervisor(WithSupervisorNotifier(n), WithSupervisorLogOutput(io.Discard))
+	s.Add("stuck", RunnableFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+	run := make(chan error, 1)
+	go func() { run <- s.Run(context.Background()) }()
+	waitComponent(t, s, "stuck", ComponentRunning)
+	n.send(syscall.SIGTERM)
+	waitComponent(t, s, "stuck", ComponentStopping)
+	n.send(syscall.SIGTERM)
+	if err := <-run; !errors.Is(err, ErrForcedShutdown) {
+		t.Fatalf("再次收到信号时应该返回 ErrForcedShutdown，实际 %v", err)
+	}
+}
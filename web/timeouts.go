@@ -0,0 +1,54 @@
+package web
+
+import "time"
+
+// WithShutdownTimeout 设置整个优雅退出的超时时间，默认 30 秒，超时之后强制退出
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(app *App) {
+		app.shutdownTimeout = d
+	}
+}
+
+// WithCallbackTimeout 设置每个自定义回调的超时时间，默认 3 秒，超时之后不再等待这个回调
+func WithCallbackTimeout(d time.Duration) Option {
+	return func(app *App) {
+		app.cbTimeout = d
+	}
+}
+
+// WithReadTimeout 设置 http.Server.ReadTimeout，读取整个请求（包括请求体）的超时时间
+func WithReadTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.srv.ReadTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout 设置 http.Server.ReadHeaderTimeout，读取请求头的超时时间，
+// 防止慢速客户端长时间占用连接
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.srv.ReadHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout 设置 http.Server.WriteTimeout，从读完请求头到写完响应的超时时间。
+// 流式响应和长时间运行的请求需要设置得足够长，或者使用 http.ResponseController 单独调整
+func WithWriteTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.srv.WriteTimeout = d
+	}
+}
+
+// WithIdleTimeout 设置 http.Server.IdleTimeout，keep-alive 连接的空闲超时时间
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.srv.IdleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes 设置 http.Server.MaxHeaderBytes，请求头的最大字节数
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(s *Server) {
+		s.srv.MaxHeaderBytes = n
+	}
+}
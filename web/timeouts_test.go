@@ -0,0 +1,20 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutOptions(t *testing.T) {
+	app := NewApp(nil, WithShutdownTimeout(time.Minute), WithCallbackTimeout(5*time.Second))
+	if app.shutdownTimeout != time.Minute || app.cbTimeout != 5*time.Second {
+		t.Fatalf("超时时间设置错误 %v %v", app.shutdownTimeout, app.cbTimeout)
+	}
+	s := NewServer("api", "localhost:0", WithReadTimeout(time.Second), WithReadHeaderTimeout(2*time.Second),
+		WithWriteTimeout(3*time.Second), WithIdleTimeout(4*time.Second), WithMaxHeaderBytes(1<<10))
+	s.reset()
+	if s.srv.ReadTimeout != time.Second || s.srv.ReadHeaderTimeout != 2*time.Second || s.srv.WriteTimeout != 3*time.Second ||
+		s.srv.IdleTimeout != 4*time.Second || s.srv.MaxHeaderBytes != 1<<10 {
+		t.Fatalf("重启之后应该保留服务器的超时配置 %+v", s.srv)
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// WithTLSConfig 使用 conf 提供 HTTPS 服务。
+// 可以通过 Certificates 配置多个证书，或者通过 GetCertificate 根据 SNI 动态选择证书，
+// conf 中的 MinVersion、CipherSuites 等配置都会生效
+func WithTLSConfig(conf *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.srv.TLSConfig = conf
+	}
+}
+
+// WithTLSKeyPair 使用内存中 PEM 格式的证书和私钥提供 HTTPS 服务，
+// 例如从环境变量或者 Vault 中读取的证书，不需要先写到磁盘上。
+// 可以和 WithTLSConfig 一起使用，证书会追加到 Certificates 中，注意 WithTLSConfig 要放在前面。
+// 证书解析失败时服务器启动会返回错误
+func WithTLSKeyPair(certPEM, keyPEM []byte) ServerOption {
+	return func(s *Server) {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			s.optErr = fmt.Errorf("web: 解析服务器%s的证书失败: %w", s.name, err)
+			return
+		}
+		if s.srv.TLSConfig == nil {
+			s.srv.TLSConfig = &tls.Config{}
+		}
+		s.srv.TLSConfig.Certificates = append(s.srv.TLSConfig.Certificates, cert)
+	}
+}
+
+// tlsEnabled 是否提供 HTTPS 服务
+func (s *Server) tlsEnabled() bool {
+	return s.tls
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newTestCertPEM 生成自签名证书，返回 PEM 格式的证书和私钥
+func newTestCertPEM(t *testing.T, hosts ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func newTestCert(t *testing.T, hosts ...string) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(newTestCertPEM(t, hosts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// startTLSServer 启动服务器，返回监听的地址
+func startTLSServer(t *testing.T, s *Server) string {
+	t.Helper()
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.serve()
+	}()
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+	return s.lis.Addr().String()
+}
+
+func TestWithTLSConfig_SNI(t *testing.T) {
+	certs := map[string]tls.Certificate{
+		"a.example.com": newTestCert(t, "a.example.com"),
+		"b.example.com": newTestCert(t, "b.example.com"),
+	}
+	s := NewServer("tls", "localhost:0", WithTLSConfig(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert := certs[hello.ServerName]
+			return &cert, nil
+		},
+	}))
+	addr := startTLSServer(t, s)
+	for host := range certs {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := conn.ConnectionState()
+		_ = conn.Close()
+		if got := state.PeerCertificates[0].DNSNames[0]; got != host {
+			t.Fatalf("SNI %s 返回了 %s 的证书", host, got)
+		}
+	}
+	_, err := tls.Dial("tcp", addr, &tls.Config{
+		ServerName:         "a.example.com",
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS11,
+	})
+	if err == nil {
+		t.Fatal("应该拒绝低于 MinVersion 的连接")
+	}
+}
+
+func TestWithTLSKeyPair(t *testing.T) {
+	certPEM, keyPEM := newTestCertPEM(t, "localhost")
+	s := NewServer("tls", "localhost:0", WithTLSKeyPair(certPEM, keyPEM))
+	addr := startTLSServer(t, s)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("期望 200，实际 %d", resp.StatusCode)
+	}
+
+	bad := NewServer("bad", "localhost:0", WithTLSKeyPair(certPEM, []byte("invalid")))
+	if err := bad.listen(); err == nil {
+		t.Fatal("证书无效时启动应该返回错误")
+	}
+}
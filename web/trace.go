@@ -0,0 +1,46 @@
+package web
+
+import (
+	"context"
+)
+
+// Tracer 追踪优雅退出的各个阶段，可以用来接入 OpenTelemetry 等链路追踪系统。
+// Start 开始一个步骤，返回的函数在步骤结束时调用，err 为该步骤的错误
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// WithTracer 设置优雅退出的 Tracer，可以多次使用，例如同时接入链路追踪和指标，
+// 每个步骤会按照设置的顺序依次通知所有 Tracer
+func WithTracer(t Tracer) Option {
+	return func(app *App) {
+		if _, ok := app.tracer.(nopTracer); ok || app.tracer == nil {
+			app.tracer = t
+			return
+		}
+		app.tracer = multiTracer{app.tracer, t}
+	}
+}
+
+// multiTracer 把每个步骤转发给多个 Tracer，步骤结束时按照相反的顺序通知
+type multiTracer []Tracer
+
+func (m multiTracer) Start(ctx context.Context, name string) (context.Context, func(err error)) {
+	ends := make([]func(error), 0, len(m))
+	for _, t := range m {
+		var end func(error)
+		ctx, end = t.Start(ctx, name)
+		ends = append(ends, end)
+	}
+	return ctx, func(err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
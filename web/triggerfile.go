@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// triggerFilePollInterval 检查触发文件是否存在的间隔
+const triggerFilePollInterval = 200 * time.Millisecond
+
+// WithShutdownTriggerFile 启动之后定时检查 path，文件被创建时 StartAndServe 开始优雅退出，
+// 用于不方便发送信号的环境，例如部分 CI、测试工具和容器运行时。
+// 启动时文件已经存在视为上次留下的，需要删除后重新创建才会触发。开始优雅退出后停止检查
+func WithShutdownTriggerFile(path string) Option {
+	return func(app *App) {
+		app.triggerFile = path
+	}
+}
+
+// startTriggerFileWatch 启动检查触发文件的 worker
+func (a *App) startTriggerFileWatch() {
+	if a.triggerFile == "" {
+		return
+	}
+	ctx, done := a.TrackWorker()
+	go func() {
+		defer done()
+		a.watchTriggerFile(ctx)
+	}()
+}
+
+func (a *App) watchTriggerFile(ctx context.Context) {
+	armed := !fileExists(a.triggerFile)
+	if !armed {
+		a.logf("触发文件%s已经存在，删除后重新创建才会开始优雅退出", a.triggerFile)
+	}
+	ticker := a.clock.NewTicker(triggerFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return
+		}
+		exists := fileExists(a.triggerFile)
+		if !armed {
+			armed = !exists
+			continue
+		}
+		if exists {
+			a.logf("检测到触发文件%s，开始优雅退出", a.triggerFile)
+			a.triggerShutdown()
+			return
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
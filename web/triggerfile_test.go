@@ -0,0 +1,41 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithShutdownTriggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shutdown")
+	// 启动前就存在的文件不会触发
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{s}, WithSignals(), WithWaitTime(0), WithShutdownTriggerFile(path))
+	done := make(chan struct{})
+	go func() {
+		app.StartAndServe()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("启动前已经存在的触发文件不应该触发优雅退出")
+	case <-time.After(3 * triggerFilePollInterval):
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * triggerFilePollInterval)
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("创建触发文件之后应该开始优雅退出")
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+)
+
+// unixSocket WithUnixSocket 配置的 Unix socket
+type unixSocket struct {
+	path string
+	mode os.FileMode
+}
+
+// WithUnixSocket 服务器同时监听 Unix socket path，和 TCP 地址共用 handler，优雅退出时一起摘流量、一起关闭。
+// mode 不为 0 时监听之后把 socket 文件的权限改成 mode。启动时 path 上残留的没有进程监听的 socket 文件会被删除，
+// 关闭服务器时删除 socket 文件。NewServer 的地址为空时只监听 Unix socket
+func WithUnixSocket(path string, mode os.FileMode) ServerOption {
+	return func(s *Server) {
+		s.unixSockets = append(s.unixSockets, unixSocket{path: path, mode: mode})
+	}
+}
+
+// WithListener 服务器同时在已经创建好的 lis 上处理请求，例如 systemd socket activation 传进来的 listener。
+// lis 的生命周期交给服务器管理，关闭服务器时会被关闭，所以 RestartServer 之后不能再使用。
+// NewServer 的地址为空时只使用注入的 listener
+func WithListener(lis net.Listener) ServerOption {
+	return func(s *Server) {
+		s.injected = append(s.injected, lis)
+	}
+}
+
+// hasExtraListeners 是否配置了 TCP 地址之外的 listener
+func (s *Server) hasExtraListeners() bool {
+	return len(s.unixSockets) > 0 || len(s.injected) > 0
+}
+
+func (s *Server) newUnixListener(u unixSocket) (net.Listener, error) {
+	addr := "unix:" + u.path
+	lis, err := inheritedListener(s.name, addr)
+	if lis == nil && err == nil {
+		removeStaleSocket(u.path)
+		lis, err = s.listenConfig.Listen(context.Background(), "unix", u.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ul := lis.(*net.UnixListener)
+	// 从父进程继承的 listener 默认关闭时不删除文件
+	ul.SetUnlinkOnClose(true)
+	if u.mode != 0 {
+		if err = os.Chmod(u.path, u.mode); err != nil {
+			_ = lis.Close()
+			return nil, fmt.Errorf("web: 修改 Unix socket %s 的权限失败: %w", u.path, err)
+		}
+	}
+	s.rawListeners = append(s.rawListeners, boundListener{addr: addr, lis: ul})
+	return s.wrapListener(lis), nil
+}
+
+// removeStaleSocket 删除上次异常退出残留的 socket 文件，还有进程在监听或者不是 socket 时保留
+func removeStaleSocket(path string) {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		_ = c.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func getBody(t *testing.T, c *http.Client, url string) string {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestServer_MultipleListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "web.sock")
+	injected, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := freeAddr(t)
+	s := NewServer("api", addr, WithUnixSocket(sock, 0o600), WithListener(injected))
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err = app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Fatalf("socket 文件的权限应该是 0600，实际 %v", fi.Mode().Perm())
+	}
+	if body := getBody(t, http.DefaultClient, "http://"+addr); body != "ok" {
+		t.Fatalf("TCP 期望 ok，实际 %q", body)
+	}
+	if body := getBody(t, unixClient(sock), "http://unix/"); body != "ok" {
+		t.Fatalf("Unix socket 期望 ok，实际 %q", body)
+	}
+	if body := getBody(t, http.DefaultClient, "http://"+injected.Addr().String()); body != "ok" {
+		t.Fatalf("注入的 listener 期望 ok，实际 %q", body)
+	}
+	if err = app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatalf("关闭之后应该删除 socket 文件，实际 %v", err)
+	}
+	if _, err = net.Dial("tcp", injected.Addr().String()); err == nil {
+		t.Fatal("关闭之后注入的 listener 应该被关闭")
+	}
+}
+
+func TestServer_UnixSocketOnly(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "web.sock")
+	// 模拟上次异常退出残留的 socket 文件
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	s := NewServer("api", "", WithUnixSocket(sock, 0))
+	if addrs := s.Addrs(); !slices.Equal(addrs, []string{"unix:" + sock}) {
+		t.Fatalf("只监听 Unix socket 时 Addrs 不应该包括空的 TCP 地址，实际 %q", addrs)
+	}
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err = app.Start(); err != nil {
+		t.Fatalf("残留的 socket 文件应该被删除后重新监听: %v", err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	if s.ListenerAddr().Network() != "unix" {
+		t.Fatalf("地址为空时应该只监听 Unix socket，实际 %v", s.ListenerAddr())
+	}
+	if body := getBody(t, unixClient(sock), "http://unix/"); body != "ok" {
+		t.Fatalf("期望 ok，实际 %q", body)
+	}
+}
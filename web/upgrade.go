@@ -0,0 +1,69 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// upgradeListenersEnv 平滑升级时父进程传给子进程的 listener，JSON 格式的 []inheritedFD
+	upgradeListenersEnv = "WEB_UPGRADE_LISTENERS"
+	// upgradeReadyEnv 子进程启动完成之后写入的管道的 fd
+	upgradeReadyEnv = "WEB_UPGRADE_READY_FD"
+	// defaultUpgradeTimeout 没有设置 WithStartupTimeout 时等待子进程启动完成的时间
+	defaultUpgradeTimeout = 30 * time.Second
+)
+
+// ErrUpgradeNotSupported 当前平台不支持平滑升级
+var ErrUpgradeNotSupported = errors.New("web: 当前平台不支持平滑升级")
+
+// WithGracefulUpgrade 开启平滑升级：收到 SIGUSR2 或者调用 Upgrade 时，用同样的参数启动新的可执行文件，
+// 把所有 *Server 的 TCP 和 Unix socket listener 通过 fd 继承传给子进程。子进程所有服务器开始监听之后通知父进程，
+// 父进程随即关闭自己的 listener（不再接受新连接，也就不会对新连接返回 503），再走正常的优雅退出流程处理完已有请求。
+// 子进程启动失败或者超过 WithStartupTimeout（默认 30 秒）没有就绪时父进程继续正常服务。
+// 子进程按照服务器名称和配置的地址找到对应的 listener，所以新旧版本的服务器名称和地址要保持一致。
+// 只支持类 Unix 系统
+func WithGracefulUpgrade() Option {
+	return func(app *App) {
+		app.gracefulUpgrade = true
+	}
+}
+
+// inheritedFD 子进程继承的一个 listener
+type inheritedFD struct {
+	Server string `json:"server"`
+	Addr   string `json:"addr"`
+	FD     int    `json:"fd"`
+}
+
+// fileListener 可以取出 fd 的 listener，*net.TCPListener 和 *net.UnixListener 都满足
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// boundListener 服务器创建的 listener，Unix socket 的 addr 带有 "unix:" 前缀
+type boundListener struct {
+	addr string
+	lis  fileListener
+}
+
+// closeListeners 关闭服务器的所有 listener，不影响已经建立的连接。
+// Unix socket 文件已经交给子进程，关闭时不删除
+func (s *Server) closeListeners() {
+	for _, bl := range s.rawListeners {
+		if ul, ok := bl.lis.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	if s.lis != nil {
+		_ = s.lis.Close()
+	}
+	for _, l := range s.extraLis {
+		_ = l.Close()
+	}
+}
@@ -0,0 +1,222 @@
+//go:build !windows
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	inheritOnce sync.Once
+	// inherited 从父进程继承的 listener，key 是服务器名称和地址，使用之后删除
+	inherited   map[string]*os.File
+	inheritedMu sync.Mutex
+)
+
+func inheritKey(server, addr string) string {
+	return server + "\x00" + addr
+}
+
+// inheritedListener 返回从父进程继承的 server 在 addr 上的 listener，没有时返回 nil
+func inheritedListener(server, addr string) (net.Listener, error) {
+	inheritOnce.Do(loadInherited)
+	inheritedMu.Lock()
+	f, ok := inherited[inheritKey(server, addr)]
+	delete(inherited, inheritKey(server, addr))
+	inheritedMu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	defer f.Close()
+	lis, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("web: 使用父进程的 listener %s 失败: %w", addr, err)
+	}
+	return lis, nil
+}
+
+func loadInherited() {
+	inherited = make(map[string]*os.File)
+	v := os.Getenv(upgradeListenersEnv)
+	if v == "" {
+		return
+	}
+	// 子进程再升级时不能把这些 fd 当成继承来的
+	_ = os.Unsetenv(upgradeListenersEnv)
+	var fds []inheritedFD
+	if err := json.Unmarshal([]byte(v), &fds); err != nil {
+		return
+	}
+	for _, fd := range fds {
+		inherited[inheritKey(fd.Server, fd.Addr)] = os.NewFile(uintptr(fd.FD), fd.Addr)
+	}
+}
+
+// notifyUpgradeReady 子进程启动完成之后通知父进程
+func (a *App) notifyUpgradeReady() {
+	v := os.Getenv(upgradeReadyEnv)
+	if v == "" {
+		return
+	}
+	_ = os.Unsetenv(upgradeReadyEnv)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+	a.logf("平滑升级完成，已经通知父进程")
+}
+
+// startUpgradeWatch 收到 SIGUSR2 时平滑升级，应用关闭完成之后停止监听
+func (a *App) startUpgradeWatch() {
+	if !a.gracefulUpgrade {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	a.notifier.Notify(ch, syscall.SIGUSR2)
+	go func() {
+		defer a.notifier.Stop(ch)
+		for {
+			select {
+			case <-ch:
+			case <-a.done:
+				return
+			}
+			if err := a.Upgrade(); err != nil {
+				a.logf("平滑升级失败，继续服务: %v", err)
+			}
+		}
+	}()
+}
+
+// Upgrade 启动新的进程接管所有 listener，新进程就绪之后开始优雅退出，见 WithGracefulUpgrade。
+// 新进程启动失败时返回错误，当前进程继续正常服务
+func (a *App) Upgrade() error {
+	if a.shutdownStarted.Load() {
+		return ErrStopping
+	}
+	a.upgradeMu.Lock()
+	defer a.upgradeMu.Unlock()
+	var (
+		files []*os.File
+		fds   []inheritedFD
+	)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, s := range a.servers {
+		srv, ok := s.(*Server)
+		if !ok {
+			continue
+		}
+		if len(srv.protocols) > 0 {
+			// QUIC 连接的状态在当前进程里，UDP socket 交给新进程也没法接着处理已有的连接
+			return fmt.Errorf("web: 服务器%s配置了 WithProtocolServer，不支持平滑升级", srv.Name())
+		}
+		for _, bl := range srv.rawListeners {
+			f, err := bl.lis.File()
+			if err != nil {
+				return fmt.Errorf("web: 获取服务器%s的 listener 失败: %w", srv.Name(), err)
+			}
+			// ExtraFiles 中的第 i 个文件在子进程中是 fd 3+i
+			fds = append(fds, inheritedFD{Server: srv.Name(), Addr: bl.addr, FD: 3 + len(files)})
+			files = append(files, f)
+		}
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	listeners, err := json.Marshal(fds)
+	if err != nil {
+		_ = readyW.Close()
+		return err
+	}
+	exe, args := a.upgradeExe, a.upgradeArgs
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			_ = readyW.Close()
+			return err
+		}
+		args = os.Args[1:]
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(upgradeEnv(os.Environ()),
+		upgradeListenersEnv+"="+string(listeners),
+		upgradeReadyEnv+"="+strconv.Itoa(3+len(files)))
+	cmd.ExtraFiles = append(files, readyW)
+	err = cmd.Start()
+	// 子进程已经有了自己的副本，父进程关闭写端，子进程退出时读端才能读到 EOF
+	_ = readyW.Close()
+	a.restoreNonblock()
+	if err != nil {
+		return fmt.Errorf("web: 启动新进程失败: %w", err)
+	}
+	a.logf("平滑升级: 新进程 %d 已启动，等待就绪", cmd.Process.Pid)
+	timeout := a.startupTimeout
+	if timeout <= 0 {
+		timeout = defaultUpgradeTimeout
+	}
+	_ = readyR.SetReadDeadline(time.Now().Add(timeout))
+	if n, err := readyR.Read(make([]byte, 1)); n != 1 {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("web: 新进程没有就绪: %w", err)
+	}
+	_ = cmd.Process.Release()
+	a.logf("平滑升级: 新进程 %d 已就绪，停止接受新连接并开始优雅退出", cmd.Process.Pid)
+	for _, s := range a.servers {
+		if srv, ok := s.(*Server); ok {
+			srv.closeListeners()
+		}
+	}
+	a.triggerShutdown()
+	return nil
+}
+
+// restoreNonblock exec 传递 fd 时会把它们改成阻塞模式，而 dup 出来的 fd 和原来的 listener
+// 共享这个状态，不改回来的话 Accept 会阻塞在系统调用上，关闭 listener 时也无法返回
+func (a *App) restoreNonblock() {
+	for _, s := range a.servers {
+		srv, ok := s.(*Server)
+		if !ok {
+			continue
+		}
+		for _, bl := range srv.rawListeners {
+			rc, err := bl.lis.SyscallConn()
+			if err != nil {
+				continue
+			}
+			_ = rc.Control(func(fd uintptr) {
+				_ = syscall.SetNonblock(int(fd), true)
+			})
+		}
+	}
+}
+
+// upgradeEnv 去掉上一次升级留下的环境变量
+func upgradeEnv(env []string) []string {
+	res := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, upgradeListenersEnv+"=") || strings.HasPrefix(kv, upgradeReadyEnv+"=") {
+			continue
+		}
+		res = append(res, kv)
+	}
+	return res
+}
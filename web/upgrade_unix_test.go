@@ -0,0 +1,96 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// upgradeChildEnv 设置时 TestUpgradeChild 作为平滑升级的子进程运行
+const upgradeChildEnv = "WEB_UPGRADE_TEST_CHILD"
+
+// TestUpgradeChild 不是真正的测试，是 TestApp_Upgrade 启动的子进程
+func TestUpgradeChild(t *testing.T) {
+	if os.Getenv(upgradeChildEnv) == "" {
+		t.Skip("只在平滑升级测试的子进程中运行")
+	}
+	served := make(chan struct{}, 1)
+	s := NewServer("api", "localhost:0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("child"))
+		served <- struct{}{}
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0))
+	if err := app.Start(); err != nil {
+		os.Exit(1)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+	}
+	_ = app.Shutdown(context.Background())
+	os.Exit(0)
+}
+
+func TestApp_Upgrade(t *testing.T) {
+	t.Setenv(upgradeChildEnv, "1")
+	s := NewServer("api", "localhost:0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("parent"))
+	})
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithGracefulUpgrade())
+	app.upgradeExe, app.upgradeArgs = os.Args[0], []string{"-test.run=^TestUpgradeChild$"}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	addr := s.ListenerAddr().String()
+	if err := app.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-app.triggered:
+	default:
+		t.Fatal("子进程就绪之后父进程应该开始优雅退出")
+	}
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatalf("升级之后原来的地址应该由子进程继续服务: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "child" {
+		t.Fatalf("升级之后的新连接应该由子进程处理，实际 %q", body)
+	}
+}
+
+func TestApp_UpgradeChildFailed(t *testing.T) {
+	s := NewServer("api", "localhost:0")
+	app := NewApp([]*Server{s}, WithWaitTime(0), WithGracefulUpgrade())
+	// 子进程没有设置 upgradeChildEnv，直接跳过测试退出，不会通知父进程
+	app.upgradeExe, app.upgradeArgs = os.Args[0], []string{"-test.run=^TestUpgradeChild$"}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	if err := app.Upgrade(); err == nil {
+		t.Fatal("子进程没有就绪时应该返回错误")
+	}
+	if app.IsDraining() {
+		t.Fatal("升级失败时父进程应该继续服务")
+	}
+	resp, err := http.Get("http://" + s.ListenerAddr().String())
+	if err != nil {
+		t.Fatalf("升级失败时父进程的 listener 应该保持打开: %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,20 @@
+package web
+
+import "net"
+
+func inheritedListener(server, addr string) (net.Listener, error) {
+	return nil, nil
+}
+
+func (a *App) notifyUpgradeReady() {}
+
+func (a *App) startUpgradeWatch() {
+	if a.gracefulUpgrade {
+		a.logf("Windows 不支持平滑升级，忽略 WithGracefulUpgrade")
+	}
+}
+
+// Upgrade Windows 不支持平滑升级，返回 ErrUpgradeNotSupported
+func (a *App) Upgrade() error {
+	return ErrUpgradeNotSupported
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Validate 检查应用的配置，返回所有不一致的配置，可以在测试或者启动时调用。
+// 不影响运行的问题（例如多个资源优先级相同）只输出警告日志
+func (a *App) Validate() error {
+	var errs []error
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"shutdownTimeout", a.shutdownTimeout},
+		{"waitTime", a.waitTime},
+		{"cbTimeout", a.cbTimeout},
+		{"startupTimeout", a.startupTimeout},
+		{"forceCloseGrace", a.forceCloseGrace},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			errs = append(errs, fmt.Errorf("web: %s 不能为负数", t.name))
+		}
+	}
+	// 等待已有请求和执行回调都在整个优雅退出的超时时间之内
+	if a.waitTimeFunc == nil && a.waitTime+a.cbTimeout > a.shutdownTimeout {
+		errs = append(errs, fmt.Errorf("web: shutdownTimeout %v 小于等待请求时间 %v 加上回调超时时间 %v",
+			a.shutdownTimeout, a.waitTime, a.cbTimeout))
+	}
+	errs = append(errs, a.validateBudget()...)
+	if a.shutdownWorkers < 0 {
+		errs = append(errs, errors.New("web: 优雅退出的并发数不能为负数"))
+	}
+
+	names := make(map[string]struct{}, len(a.servers))
+	for i, s := range a.servers {
+		if s == nil {
+			errs = append(errs, fmt.Errorf("web: 第%d个服务器为 nil", i))
+			continue
+		}
+		if _, ok := names[s.Name()]; ok {
+			errs = append(errs, fmt.Errorf("web: 服务器名称%s重复", s.Name()))
+		}
+		names[s.Name()] = struct{}{}
+	}
+	for i, cb := range a.cbs {
+		if cb == nil {
+			errs = append(errs, fmt.Errorf("web: 第%d个回调为 nil", i))
+		}
+	}
+	for _, cb := range a.phased {
+		if cb.fn == nil {
+			errs = append(errs, fmt.Errorf("web: 回调%s为 nil", cb.name))
+		}
+	}
+
+	a.closers.mu.Lock()
+	priorities := make(map[int]int, len(a.closers.resources))
+	for _, r := range a.closers.resources {
+		priorities[r.priority]++
+	}
+	a.closers.mu.Unlock()
+	for p, n := range priorities {
+		// 默认优先级 0 相同是正常的，按注册顺序的逆序关闭
+		if p != 0 && n > 1 {
+			a.logf("警告：%d 个资源的优先级都是 %d，它们之间按注册顺序的逆序关闭", n, p)
+		}
+	}
+	return errors.Join(errs...)
+}
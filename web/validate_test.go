@@ -0,0 +1,57 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApp_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		app     func() *App
+		wantErr string
+	}{
+		{
+			name: "default",
+			app: func() *App {
+				return NewApp([]*Server{NewServer("api", ":8080"), NewServer("admin", ":8081")})
+			},
+		},
+		{
+			name: "negative timeout",
+			app: func() *App {
+				return NewApp(nil, WithWaitTime(-time.Second))
+			},
+			wantErr: "waitTime 不能为负数",
+		},
+		{
+			name: "shutdown timeout too small",
+			app: func() *App {
+				return NewApp(nil, WithWaitTime(time.Minute))
+			},
+			wantErr: "shutdownTimeout",
+		},
+		{
+			name: "duplicate server name",
+			app: func() *App {
+				return NewApp([]*Server{NewServer("api", ":8080"), NewServer("api", ":8081")})
+			},
+			wantErr: "服务器名称api重复",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.app().Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("期望包含 %q 的错误，实际 %v", tc.wantErr, err)
+			}
+		})
+	}
+}
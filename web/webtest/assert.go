@@ -0,0 +1,73 @@
+package webtest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+// Recorder 记录回调执行的顺序
+type Recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// Callback 执行时记录 name 的回调，传给 web.WithShutdownCallbacks
+func (r *Recorder) Callback(name string) web.ShutdownCallback {
+	return func(context.Context) { r.Record(name) }
+}
+
+// Func 执行时记录 name 的回调，传给 App.RegisterShutdownFunc
+func (r *Recorder) Func(name string) web.ShutdownFunc {
+	return func(context.Context) error {
+		r.Record(name)
+		return nil
+	}
+}
+
+// Record 记录 name，可以在 PrepareShutdown、OnPanic 等其它钩子中调用
+func (r *Recorder) Record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, name)
+}
+
+// Calls 到目前为止记录的名称
+func (r *Recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// AssertOrder 检查记录的名称和 names 完全一致
+func (r *Recorder) AssertOrder(t testing.TB, names ...string) {
+	t.Helper()
+	if got := r.Calls(); !slices.Equal(got, names) {
+		t.Fatalf("回调的执行顺序应该是 %v，实际 %v", names, got)
+	}
+}
+
+// AssertBefore 检查 first 在 second 之前执行过，适合同一个阶段并发执行、顺序不固定的回调
+func (r *Recorder) AssertBefore(t testing.TB, first, second string) {
+	t.Helper()
+	got := r.Calls()
+	i, j := slices.Index(got, first), slices.Index(got, second)
+	if i < 0 || j < 0 || i > j {
+		t.Fatalf("%s 应该在 %s 之前执行，实际 %v", first, second, got)
+	}
+}
+
+// AssertDrain 检查摘流量的耗时在 [min, max] 之间，使用 FakeClock 时耗时就是推进的时间
+func AssertDrain(t testing.TB, res *web.ShutdownResult, min, max time.Duration) {
+	t.Helper()
+	if res == nil {
+		t.Fatal("优雅退出还没有结束")
+	}
+	if res.Drain < min || res.Drain > max {
+		t.Fatalf("摘流量的耗时应该在 %v 和 %v 之间，实际 %v", min, max, res.Drain)
+	}
+}
@@ -0,0 +1,184 @@
+// Package webtest 测试 web.App 生命周期的工具：可以手动推进的时钟、模拟的信号、
+// 基于 net.Pipe 的内存 listener，以及检查回调顺序和摘流量耗时的断言，
+// 整个优雅退出在毫秒内跑完，不占用端口，也不会给测试进程发送真的信号
+package webtest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+var _ web.Clock = (*FakeClock)(nil)
+
+// FakeClock 只有调用 Advance 时才会前进的时钟，通过 web.WithClock 传给 App。
+// AfterFunc 的函数在 Advance 所在的 goroutine 中执行，所以 Advance 返回时超时的 context 已经被取消；
+// channel 上的通知和 time.Timer 一样不会阻塞，接收方需要一点时间才能处理
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock 创建从 start 开始的时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) web.Timer {
+	return c.add(d, 0, nil)
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) web.Ticker {
+	if d <= 0 {
+		panic("webtest: NewTicker 的间隔必须大于 0")
+	}
+	return fakeTicker{c.add(d, d, nil)}
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) web.Timer {
+	return c.add(d, 0, f)
+}
+
+// Advance 把时间推进 d，按到期的先后顺序触发期间到期的定时器
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		t := c.next(target)
+		if t == nil {
+			break
+		}
+		c.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			c.remove(t)
+		}
+		now := c.now
+		c.mu.Unlock()
+		if t.f != nil {
+			t.f()
+		} else {
+			select {
+			case t.c <- now:
+			default:
+			}
+		}
+		c.mu.Lock()
+	}
+	if target.After(c.now) {
+		c.now = target
+	}
+	c.mu.Unlock()
+}
+
+// BlockUntil 等待至少有 n 个还没有到期的定时器，用来确定 App 已经走到了等待的地方
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// BlockUntilTimer 等待有一个时长为 d 的定时器，例如 WithWaitTime 对应的定时器说明已经开始摘流量
+func (c *FakeClock) BlockUntilTimer(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !slices.ContainsFunc(c.timers, func(t *fakeTimer) bool { return t.d == d }) {
+		c.cond.Wait()
+	}
+}
+
+// Timers 还没有到期的定时器的数量
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) add(d, period time.Duration, f func()) *fakeTimer {
+	t := &fakeTimer{clock: c, d: d, period: period, f: f}
+	if f == nil {
+		t.c = make(chan time.Time, 1)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(t, d)
+	return t
+}
+
+// schedule 在 d 之后触发 t，调用方持有锁
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	t.d = d
+	t.when = c.now.Add(d)
+	if !slices.Contains(c.timers, t) {
+		c.timers = append(c.timers, t)
+	}
+	c.cond.Broadcast()
+}
+
+// next 返回 target 之前最早到期的定时器，同时到期时先创建的先触发
+func (c *FakeClock) next(target time.Time) *fakeTimer {
+	var first *fakeTimer
+	for _, t := range c.timers {
+		if !t.when.After(target) && (first == nil || t.when.Before(first.when)) {
+			first = t
+		}
+	}
+	return first
+}
+
+// remove 删除 t，返回 t 是否还没有到期，调用方持有锁
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	c.cond.Broadcast()
+	return true
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	d      time.Duration
+	period time.Duration
+	when   time.Time
+	c      chan time.Time
+	f      func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := slices.Contains(t.clock.timers, t)
+	t.clock.schedule(t, d)
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.c }
+
+func (t fakeTicker) Stop() { t.t.Stop() }
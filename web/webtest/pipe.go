@@ -0,0 +1,78 @@
+package webtest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// PipeListener 基于 net.Pipe 的内存 listener，不占用端口，通过 web.WithListener 注入服务器：
+//
+//	lis := webtest.NewPipeListener("api")
+//	srv := web.NewServer("api", "", web.WithListener(lis))
+//	resp, err := lis.Client().Get("http://api/hello")
+type PipeListener struct {
+	addr   pipeAddr
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+// NewPipeListener 创建地址为 name 的 listener
+func NewPipeListener(name string) *PipeListener {
+	return &PipeListener{addr: pipeAddr(name), conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *PipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *PipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *PipeListener) Addr() net.Addr { return l.addr }
+
+// Dial 建立一个连接，listener 关闭之后返回 net.ErrClosed
+func (l *PipeListener) Dial() (net.Conn, error) {
+	return l.DialContext(context.Background())
+}
+
+// DialContext 和 Dial 一样，ctx 被取消时返回 ctx.Err()
+func (l *PipeListener) DialContext(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+	case <-ctx.Done():
+	}
+	_ = server.Close()
+	_ = client.Close()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, &net.OpError{Op: "dial", Net: l.addr.Network(), Addr: l.addr, Err: net.ErrClosed}
+}
+
+// Client 所有请求都通过 l 发送的 http.Client，URL 中的 host 不影响连接
+func (l *PipeListener) Client() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		},
+	}}
+}
+
+type pipeAddr string
+
+func (pipeAddr) Network() string { return "pipe" }
+
+func (a pipeAddr) String() string { return string(a) }
@@ -0,0 +1,71 @@
+package webtest
+
+import (
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+var _ web.SignalNotifier = (*Signals)(nil)
+
+// Signals 模拟的信号，通过 Option 传给 App 之后，Send 发送的信号和真的信号一样触发优雅退出、重新加载等，
+// 不会影响测试进程本身
+type Signals struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	subs []subscription
+}
+
+type subscription struct {
+	c    chan<- os.Signal
+	sigs []os.Signal
+}
+
+// NewSignals 创建模拟的信号
+func NewSignals() *Signals {
+	s := &Signals{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Option 让 App 通过 s 接收信号
+func (s *Signals) Option() web.Option {
+	return web.WithSignalNotifier(s)
+}
+
+func (s *Signals) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, subscription{c: c, sigs: sig})
+	s.cond.Broadcast()
+}
+
+func (s *Signals) Stop(c chan<- os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = slices.DeleteFunc(s.subs, func(sub subscription) bool { return sub.c == c })
+}
+
+// Send 等待有人监听 sig 之后发送 sig，和 signal.Notify 一样 channel 满了时丢弃
+func (s *Signals) Send(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.listening(sig) {
+		s.cond.Wait()
+	}
+	for _, sub := range s.subs {
+		if slices.Contains(sub.sigs, sig) {
+			select {
+			case sub.c <- sig:
+			default:
+			}
+		}
+	}
+}
+
+// listening 是否有人监听 sig，调用方持有锁
+func (s *Signals) listening(sig os.Signal) bool {
+	return slices.ContainsFunc(s.subs, func(sub subscription) bool { return slices.Contains(sub.sigs, sig) })
+}
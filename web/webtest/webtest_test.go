@@ -0,0 +1,257 @@
+package webtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Tuanzi-bug/component-base/web"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	timer := c.NewTimer(time.Second)
+	ticker := c.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+	fired := 0
+	c.AfterFunc(500*time.Millisecond, func() { fired++ })
+
+	c.Advance(400 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("定时器不应该提前触发")
+	default:
+	}
+	if got := <-ticker.C(); !got.Equal(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("ticker 触发的时间应该是 300ms，实际 %v", got.Sub(start))
+	}
+	c.Advance(600 * time.Millisecond)
+	if fired != 1 {
+		t.Fatalf("Advance 返回时 AfterFunc 应该已经执行，实际执行了%d次", fired)
+	}
+	if got := <-timer.C(); !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("定时器触发的时间应该是 1s，实际 %v", got.Sub(start))
+	}
+	if !c.Now().Equal(start.Add(time.Second)) {
+		t.Fatalf("当前时间应该是 1s，实际 %v", c.Now().Sub(start))
+	}
+	if timer.Reset(time.Second) {
+		t.Fatal("已经触发的定时器 Reset 应该返回 false")
+	}
+	if !timer.Stop() {
+		t.Fatal("还没有触发的定时器 Stop 应该返回 true")
+	}
+	if n := c.Timers(); n != 1 {
+		t.Fatalf("应该只剩下 ticker，实际 %d 个定时器", n)
+	}
+}
+
+func TestShutdownWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	signals := NewSignals()
+	rec := &Recorder{}
+	lis := NewPipeListener("api")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := web.NewServer("api", "", web.WithListener(lis))
+	srv.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+	app := web.NewApp([]*web.Server{srv},
+		web.WithClock(clock), signals.Option(),
+		web.WithWaitTime(10*time.Second),
+		web.WithShutdownCallbacks(rec.Callback("cache")),
+		web.WithLogOutput(io.Discard))
+	app.RegisterShutdownFunc("flush", web.PhaseFlush, rec.Func("flush"))
+	app.RegisterCallback("db", web.PhaseResources, rec.Callback("db"))
+
+	run := make(chan error, 1)
+	go func() { run <- app.Run(context.Background()) }()
+	if err := app.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client := lis.Client()
+	body := make(chan string, 1)
+	go func() {
+		resp, err := client.Get("http://api/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+
+	begin := time.Now()
+	signals.Send(syscall.SIGTERM)
+	// 开始等待正在处理的请求
+	clock.BlockUntilTimer(10 * time.Second)
+	clock.Advance(3 * time.Second)
+	close(release)
+	if got := <-body; got != "done" {
+		t.Fatalf("正在处理的请求应该正常结束，实际 %q", got)
+	}
+	for srv.InFlight() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := <-run; err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(begin); d > 5*time.Second {
+		t.Fatalf("使用 FakeClock 时不应该真的等待，实际耗时 %v", d)
+	}
+	AssertDrain(t, app.Result(), 3*time.Second, 3100*time.Millisecond)
+	rec.AssertOrder(t, "cache", "flush", "db")
+	if _, err := lis.Dial(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("关闭之后 PipeListener 应该拒绝连接，实际 %v", err)
+	}
+}
+
+// blockingProtocol Listen 阻塞到 release 关闭
+type blockingProtocol struct{ release chan struct{} }
+
+func (p blockingProtocol) Listen() (net.Addr, error) {
+	<-p.release
+	return nil, errors.New("监听失败")
+}
+
+func (blockingProtocol) Serve(http.Handler) error { return http.ErrServerClosed }
+
+func (blockingProtocol) Shutdown(context.Context) error { return nil }
+
+func (blockingProtocol) Close() error { return nil }
+
+func TestStartupTimeoutWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	p := blockingProtocol{release: make(chan struct{})}
+	defer close(p.release)
+	srv := web.NewServer("api", "", web.WithListener(NewPipeListener("api")), web.WithProtocolServer(p))
+	app := web.NewApp([]*web.Server{srv}, web.WithClock(clock), web.WithStartupTimeout(10*time.Second),
+		web.WithLogOutput(io.Discard))
+	started := make(chan error, 1)
+	go func() { started <- app.Start() }()
+	clock.BlockUntilTimer(10 * time.Second)
+	clock.Advance(9 * time.Second)
+	select {
+	case err := <-started:
+		t.Fatalf("没有到启动超时时间 Start 不应该返回，实际 %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if err := <-started; !errors.Is(err, web.ErrStartupTimeout) {
+		t.Fatalf("应该返回 ErrStartupTimeout，实际 %v", err)
+	}
+}
+
+// flakyServer 第一次启动时异常退出，之后一直运行到 Stop
+type flakyServer struct {
+	starts  atomic.Int32
+	stopped chan struct{}
+}
+
+func (s *flakyServer) Name() string { return "flaky" }
+
+func (s *flakyServer) Start() error {
+	if s.starts.Add(1) == 1 {
+		return errors.New("崩溃")
+	}
+	<-s.stopped
+	return http.ErrServerClosed
+}
+
+func (s *flakyServer) Stop(context.Context) error {
+	close(s.stopped)
+	return nil
+}
+
+func TestRestartBackoffWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	srv := &flakyServer{stopped: make(chan struct{})}
+	app := web.NewApp(nil, web.WithServers(srv), web.WithClock(clock), web.WithWaitTime(0),
+		web.WithServerRestartPolicy(3, 5*time.Second), web.WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntilTimer(5 * time.Second)
+	clock.Advance(4 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if n := srv.starts.Load(); n != 1 {
+		t.Fatalf("退避时间没到不应该重启，实际启动了%d次", n)
+	}
+	clock.Advance(time.Second)
+	for srv.starts.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimitWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	lis := NewPipeListener("api")
+	srv := web.NewServer("api", "", web.WithListener(lis), web.WithRateLimit(1, 1))
+	app := web.NewApp([]*web.Server{srv}, web.WithClock(clock), web.WithWaitTime(0), web.WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Shutdown(context.Background()) }()
+	get := func() int {
+		resp, err := lis.Client().Get("http://api/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := get(); got == http.StatusTooManyRequests {
+		t.Fatal("第一个请求不应该被限流")
+	}
+	if got := get(); got != http.StatusTooManyRequests {
+		t.Fatalf("令牌用完之后应该返回 429，实际 %d", got)
+	}
+	clock.Advance(time.Second)
+	if got := get(); got == http.StatusTooManyRequests {
+		t.Fatal("FakeClock 前进 1 秒之后应该补充令牌")
+	}
+}
+
+func TestRetryAfterWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	lis := NewPipeListener("api")
+	srv := web.NewServer("api", "", web.WithListener(lis))
+	// 没有正在处理的请求时 WithMinDrainTime 让服务器在等待窗口内继续返回 503
+	app := web.NewApp([]*web.Server{srv}, web.WithClock(clock), web.WithWaitTime(30*time.Second),
+		web.WithMinDrainTime(30*time.Second), web.WithShutdownTimeout(time.Minute), web.WithLogOutput(io.Discard))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- app.Shutdown(context.Background()) }()
+	clock.BlockUntilTimer(30 * time.Second)
+	clock.Advance(10 * time.Second)
+	resp, err := lis.Client().Get("http://api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if got := resp.Header.Get("Retry-After"); got != "20" {
+		t.Fatalf("Retry-After 应该按照 FakeClock 计算剩余的 20 秒，实际 %q", got)
+	}
+	clock.Advance(20 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
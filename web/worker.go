@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WorkerFunc 后台 worker，例如消息消费者、定时任务、轮询。
+// ctx 在开始优雅退出时被取消，worker 应该在 ctx 取消之后尽快返回
+type WorkerFunc func(ctx context.Context) error
+
+// WorkerOption 配置 AddWorker 添加的 worker
+type WorkerOption func(*worker)
+
+// WithWorkerFatal worker 在优雅退出之前返回错误（包括 panic）时关闭整个应用，类似 errgroup
+func WithWorkerFatal() WorkerOption {
+	return func(w *worker) {
+		w.fatal = true
+	}
+}
+
+// WithWorkerStopTimeout 开始等待 worker 退出之后最多等待 d，默认 5 秒，超时时优雅退出返回错误
+func WithWorkerStopTimeout(d time.Duration) WorkerOption {
+	return func(w *worker) {
+		w.stopTimeout = d
+	}
+}
+
+// worker AddWorker 添加的 worker
+type worker struct {
+	name        string
+	run         WorkerFunc
+	fatal       bool
+	stopTimeout time.Duration
+	done        chan struct{}
+	err         error
+}
+
+// AddWorker 添加和服务器一起运行的 worker：Start 成功之后启动，已经启动之后添加的立即启动。
+// 开始优雅退出（拒绝新请求）时取消 ctx，关闭服务器之后、释放资源之前等待 worker 返回。
+// worker 返回的错误（context.Canceled 除外）和等待超时会包含在 Shutdown 返回的错误中。
+// 应用已经开始关闭之后添加的 worker 不会运行
+func (a *App) AddWorker(name string, run WorkerFunc, opts ...WorkerOption) {
+	w := &worker{name: name, run: run, stopTimeout: goroutineWaitTimeout, done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(w)
+	}
+	a.workerMu.Lock()
+	defer a.workerMu.Unlock()
+	if a.shutdownStarted.Load() {
+		return
+	}
+	a.workerList = append(a.workerList, w)
+	if a.workersStarted {
+		go a.runWorker(w)
+	}
+}
+
+// startWorkers 启动 Start 之前添加的 worker
+func (a *App) startWorkers() {
+	a.workerMu.Lock()
+	defer a.workerMu.Unlock()
+	a.workersStarted = true
+	for _, w := range a.workerList {
+		go a.runWorker(w)
+	}
+}
+
+func (a *App) runWorker(w *worker) {
+	defer close(w.done)
+	w.err = w.call(a.workerCtx)
+	if w.err == nil || errors.Is(w.err, context.Canceled) {
+		w.err = nil
+		return
+	}
+	w.err = fmt.Errorf("web: worker %s 退出: %w", w.name, w.err)
+	a.logEvent("worker_failed", []any{"worker", w.name, "error", w.err}, "worker %s 异常退出 %v", w.name, w.err)
+	if w.fatal && !a.shutdownStarted.Load() {
+		a.logf("worker %s 异常退出，关闭应用", w.name)
+		a.triggerShutdown()
+	}
+}
+
+// call 执行 worker，panic 转换成错误
+func (w *worker) call(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.run(ctx)
+}
+
+// waitWorkers 等待所有 worker 退出，每个 worker 最多等待各自的超时时间
+func (a *App) waitWorkers(ctx context.Context) error {
+	a.workerMu.Lock()
+	workers := a.workerList
+	started := a.workersStarted
+	a.workerMu.Unlock()
+	if !started || len(workers) == 0 {
+		return nil
+	}
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timer := a.clock.NewTimer(w.stopTimeout)
+			defer timer.Stop()
+			select {
+			case <-w.done:
+				errs[i] = w.err
+				return
+			case <-timer.C():
+			case <-ctx.Done():
+			}
+			a.logf("等待 worker %s 退出超时", w.name)
+			errs[i] = fmt.Errorf("web: 等待 worker %s 退出超时", w.name)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApp_AddWorker(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	errConsumer := errors.New("commit offset failed")
+	started := make(chan struct{})
+	app.AddWorker("consumer", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return errConsumer
+	})
+	app.AddWorker("ticker", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Start 之后应该启动 worker")
+	}
+	err := app.Shutdown(context.Background())
+	if !errors.Is(err, errConsumer) {
+		t.Fatalf("worker 返回的错误应该包含在 Shutdown 的错误中，实际 %v", err)
+	}
+	if strings.Contains(err.Error(), "ticker") {
+		t.Fatalf("worker 因为 ctx 取消返回不应该算错误，实际 %v", err)
+	}
+}
+
+func TestApp_AddWorker_Fatal(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	app.AddWorker("poller", func(ctx context.Context) error {
+		panic("nil map")
+	}, WithWorkerFatal())
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-app.triggered:
+	case <-time.After(time.Second):
+		t.Fatal("WithWorkerFatal 的 worker 异常退出时应该关闭应用")
+	}
+	if err := app.Shutdown(context.Background()); err == nil || !strings.Contains(err.Error(), "panic: nil map") {
+		t.Fatalf("worker panic 应该转换成错误，实际 %v", err)
+	}
+}
+
+func TestApp_AddWorker_StopTimeout(t *testing.T) {
+	app := NewApp(nil, WithWaitTime(0))
+	block := make(chan struct{})
+	defer close(block)
+	app.AddWorker("stuck", func(ctx context.Context) error {
+		<-block
+		return nil
+	}, WithWorkerStopTimeout(50*time.Millisecond))
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err := app.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("worker 没有退出时应该返回超时错误，实际 %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("应该只等待 worker 自己的超时时间，实际 %v", d)
+	}
+}